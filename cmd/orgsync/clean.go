@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runClean removes what interrupted runs and stray directories left in the sync root,
+// asking before it touches anything that may hold work
+func runClean(args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Only list what would be cleaned")
+	yes := fs.Bool("yes", false, "Do not ask: clone broken clones again from their origin and delete everything else, except clones holding work on no remote")
+	force := fs.Bool("force", false, "With --yes, also delete or clone again the clones with local changes or work on no remote")
+	logs := fs.Bool("logs", false, "Also remove the command logs saved with --save-logs")
+	configPath := fs.String("config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s clean [OPTIONS] [org]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nFind what does not belong in this directory: partial clones and temporary files\n")
+		fmt.Fprintf(os.Stderr, "of interrupted runs, broken clones without a valid HEAD, empty directories and\n")
+		fmt.Fprintf(os.Stderr, "clones of repositories outside org (by default the owner of the last run).\n")
+		fmt.Fprintf(os.Stderr, "Temporary leftovers are removed; for the rest you are asked whether to delete\n")
+		fmt.Fprintf(os.Stderr, "them or, for broken clones, clone them again. Pinned directories are left alone.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *force && !*yes {
+		log.Fatalf("Error: --force only applies together with --yes")
+	}
+
+	// A running sync is still using its staging directories
+	release, err := sync.AcquireLock(".")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer release()
+
+	opts := cleanTarget(fs.Arg(0))
+	opts.Keep = loadConfig(*configPath).Keep
+	leftovers, err := sync.FindLeftovers(".", opts, *logs)
+	if err != nil {
+		release()
+		log.Fatalf("Error: %v", err)
+	}
+	if len(leftovers) == 0 {
+		fmt.Println("Nothing to clean")
+		return
+	}
+	if *dryRun {
+		for _, l := range leftovers {
+			fmt.Println(l)
+		}
+		return
+	}
+
+	// Questions need someone to answer them
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
+	input := bufio.NewReader(os.Stdin)
+	cleaned, skipped, spared, failed := 0, 0, 0, 0
+	for _, l := range leftovers {
+		// What only exists in a clone is never lost without someone asking for it
+		work := l.HoldsWork(context.Background())
+		action := "d"
+		switch {
+		case l.Kind == sync.LeftoverStaging || l.Kind == sync.LeftoverTemp || l.Kind == sync.LeftoverLogs:
+		case *yes && work && !*force:
+			fmt.Printf("Kept %s: it has local changes or work on no remote\n", l)
+			spared++
+			skipped++
+			continue
+		case *yes && l.Kind == sync.LeftoverBroken && l.Origin != "":
+			action = "r"
+		case *yes:
+		case !interactive:
+			action = "s"
+		default:
+			if work {
+				fmt.Printf("%s has local changes or work on no remote\n", l.Path)
+			}
+			action = ask(input, l)
+		}
+
+		var err error
+		switch action {
+		case "d":
+			err = l.Remove()
+			if err == nil {
+				fmt.Printf("Deleted %s\n", l)
+			}
+		case "r":
+			fmt.Printf("Cloning %s again from %s...\n", l.Path, l.Origin)
+			err = l.Reclone(context.Background())
+			if err == nil {
+				fmt.Printf("Cloned %s again\n", l.Path)
+			}
+		default:
+			fmt.Printf("Kept %s\n", l)
+			skipped++
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed++
+			continue
+		}
+		cleaned++
+	}
+
+	fmt.Printf("\nCleaned %d, kept %d", cleaned, skipped)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	if skipped > 0 && !interactive && !*yes {
+		fmt.Printf("Run %s clean in a terminal to choose what to do with them, or with --yes\n", os.Args[0])
+	}
+	if spared > 0 {
+		fmt.Printf("Run %s clean --yes --force to delete the clones with work on no remote too\n", os.Args[0])
+	}
+	if failed > 0 {
+		release()
+		os.Exit(1)
+	}
+}
+
+// cleanTarget returns whose clones belong in the sync root: org, or else the target of
+// the last run. Without either, clones of other owners cannot be told apart.
+func cleanTarget(org string) sync.Options {
+	if org != "" {
+		return sync.Options{Owner: org, Target: sync.TargetOrg, Host: ghHost()}
+	}
+	run, err := sync.LoadLastRun(sync.LastRunFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Warn("cannot tell which owner's clones belong here", "error", err)
+		}
+		return sync.Options{}
+	}
+	if run.Options.Host == "" {
+		run.Options.Host = ghHost()
+	}
+	return run.Options
+}
+
+// ask asks what to do with a leftover until it gets an answer: "d" to delete it, "r"
+// to clone a broken clone again, or "s" to skip it
+func ask(input *bufio.Reader, l sync.Leftover) string {
+	choices := "[d]elete, [s]kip"
+	if l.Kind == sync.LeftoverBroken && l.Origin != "" {
+		choices = "[d]elete, [r]e-clone, [s]kip"
+	}
+	for {
+		fmt.Printf("%s: %s? ", l, choices)
+		line, err := input.ReadString('\n')
+		if err != nil {
+			return "s"
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(line)); answer {
+		case "d", "s":
+			return answer
+		case "r":
+			if strings.Contains(choices, "[r]") {
+				return answer
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/jdmcgrath/orgsync/sync/tui"
+)
+
+// runDoctor checks that the tools, login, config and sync root a sync needs are in order
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s doctor [OPTIONS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nCheck that git and gh are installed, gh is logged in, the config file is valid\n")
+		fmt.Fprintf(os.Stderr, "and this directory is ready to sync into. Exits with status 1 if anything is wrong.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	checks := sync.Diagnose(".", ghHost())
+	checks = append(checks, checkConfig(*configPath))
+
+	failed := 0
+	for _, check := range checks {
+		if check.Err != nil {
+			failed++
+			fmt.Printf("%s %s: %v\n", check.Mark(), check.Name, check.Err)
+			continue
+		}
+		fmt.Printf("%s %s: %s\n", check.Mark(), check.Name, check.Detail)
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(checks))
+		os.Exit(1)
+	}
+}
+
+// checkConfig loads the config file like a sync would, without exiting on errors
+func checkConfig(path string) sync.Check {
+	check := sync.Check{Name: "config"}
+	required := path != ""
+	if path == "" {
+		path = sync.DefaultConfigPath()
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !required {
+		check.Detail = "none, using the defaults"
+		return check
+	}
+	config, err := sync.LoadConfig(path, required)
+	if err == nil {
+		_, err = sync.LookupTheme(config.Theme, config.Colors)
+	}
+	if err == nil {
+		if _, keysErr := tui.LookupKeys(config.Keys); keysErr != nil {
+			err = fmt.Errorf("invalid keys in %s: %w", path, keysErr)
+		}
+	}
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	check.Detail = path
+	return check
+}
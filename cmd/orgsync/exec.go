@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/jdmcgrath/orgsync/sync/tui"
+)
+
+// runExec runs a command in every clone recorded in the manifest of the sync root,
+// with the worker pool and terminal UI of a sync
+func runExec(args []string) {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+	only := fs.String("only", "", "Only run in the repositories in this comma-separated `list`")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "Run in at most `n` repositories at once (0 for no limit)")
+	failFast := fs.Bool("fail-fast", false, "Cancel the remaining repositories after the first one the command fails in")
+	reportFormat := fs.String("output", "", "Write a report with the output and exit status of every repository in this `format` ("+strings.Join(sync.FormatterNames(), ", ")+")")
+	fs.StringVar(reportFormat, "report", "", "Alias for --output")
+	reportFile := fs.String("report-file", "", "Write the report to this `file` instead of standard output")
+	plain := fs.Bool("plain", false, "Print the output of each repository as it finishes instead of drawing the terminal UI")
+	quiet := fs.Bool("quiet", false, "Print nothing unless the command fails somewhere, then only a summary line and the failures to stderr")
+	noColor := fs.Bool("no-color", false, "Disable colors in the terminal UI (also set by NO_COLOR)")
+	ascii := fs.Bool("ascii", false, "Draw the terminal UI with ASCII characters only (default: detected from TERM and the locale)")
+	logFile := fs.String("log-file", "", "Append structured JSON logs of every command to `file`")
+	logLevel := fs.String("log-level", "info", "Log records of this `level` and above: debug, info, warn or error")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s exec [OPTIONS] -- command [args]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nRun a command in every clone listed in the manifest of this directory, several at once,\nand show its output and exit status per repository. The command is not run through a\nshell; use sh -c for pipes and variables. Arguments may refer to the repository as\n{{.Org}} (or {{.Owner}}), {{.Name}}, {{.DefaultBranch}} and {{.Path}}; the environment\nvariables ORGSYNC_OWNER, ORGSYNC_REPO and ORGSYNC_PATH describe it too.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  %s exec -- git status -sb\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exec --concurrency 2 --only api,web -- make test\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exec --plain -- sh -c 'git log -1 --format=%%cs'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s exec -- gh pr list -R {{.Org}}/{{.Name}}\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nExit status:\n")
+		fmt.Fprintf(os.Stderr, "  0 when the command succeeded everywhere, 1 when it failed in any repository,\n")
+		fmt.Fprintf(os.Stderr, "  2 when the run was interrupted before every repository finished.\n")
+	}
+	fs.Parse(args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *quiet && *plain {
+		log.Fatalf("Error: --quiet and --plain cannot be combined")
+	}
+	if *concurrency < 0 {
+		log.Fatalf("Error: --concurrency must not be negative")
+	}
+	if *reportFormat != "" && !sync.ValidReportFormat(*reportFormat) {
+		log.Fatalf("Error: unknown report format %q", *reportFormat)
+	}
+	if err := sync.ValidateExec(command); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	// A command given by path may only exist inside the clones, e.g. ./build.sh, and
+	// one given by a template only once it is filled in
+	if !strings.ContainsRune(command[0], os.PathSeparator) && !strings.Contains(command[0], "{{") {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if _, err := os.Stat(sync.ManifestFile); errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Error: no manifest in this directory; run %s sync first", os.Args[0])
+	}
+
+	setupLogging(*logFile, *logLevel, *quiet)
+	config := loadConfig(*configPath)
+	applyTheme(config, *noColor || *plain, *ascii || *plain)
+	applyKeys(config)
+
+	// Keep syncs out of the clones while the command runs in them
+	release, err := sync.AcquireLock(".")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	opts := sync.Options{Exec: command, Only: splitList(*only), Concurrency: *concurrency, FailFast: *failFast, Plain: *plain, Quiet: *quiet, Host: ghHost()}
+	model := tui.NewModel(opts)
+	logger.Info("starting exec", "command", strings.Join(command, " "), "run_id", model.RunID)
+	final, err := newProgram(model).Run()
+	release()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	model = final.(tui.Model)
+
+	// The terminal UI drops repositories from the table as they finish, so what the
+	// command printed is shown once it is gone, unless the report takes standard output
+	toStdout := *reportFormat != "" && *reportFile == ""
+	if !*plain && !*quiet && !toStdout {
+		for _, line := range model.ExecOutput() {
+			fmt.Println(line)
+		}
+	}
+	if *reportFormat != "" {
+		if err := writeReport(model.Report(), *reportFormat, *reportFile, false); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	logger.Info("exec completed", "command", strings.Join(command, " "), "run_id", model.RunID)
+	os.Exit(exitCode(model, 0))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/jdmcgrath/orgsync/sync/tui"
+)
+
+// runExplore browses an organization's repositories and composes a profile for --profile
+func runExplore(args []string) {
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	user := fs.Bool("user", false, "Explore repositories owned by a user instead of an organization")
+	profile := fs.String("profile", "", "Start from the rules of this saved `profile` and save back to it")
+	configPath := fs.String("config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+	noColor := fs.Bool("no-color", false, "Disable colors in the terminal UI (also set by NO_COLOR)")
+	ascii := fs.Bool("ascii", false, "Draw the terminal UI with ASCII characters only (default: detected from TERM and the locale)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s explore [OPTIONS] org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nBrowse repositories with their language, topics and last push, and compose\n")
+		fmt.Fprintf(os.Stderr, "include/exclude rules that are saved to the config as a profile for --profile.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || fs.Arg(0) == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	path := *configPath
+	if path == "" {
+		path = sync.DefaultConfigPath()
+	}
+	config := loadConfig(*configPath)
+	applyTheme(config, *noColor, *ascii)
+
+	opts := sync.Options{Owner: fs.Arg(0), Target: sync.TargetOrg, Host: ghHost(), Policy: config.Policy, Profile: *profile}
+	if *user {
+		opts.Target = sync.TargetUser
+	}
+	if *profile != "" {
+		opts.Selection = config.Profiles[*profile]
+		if err := opts.Selection.Auth.Apply(); err != nil {
+			log.Fatalf("Error: profile %s: %v", *profile, err)
+		}
+		if opts.Selection.Auth.Host != "" {
+			opts.Host = opts.Selection.Auth.Host
+		}
+	}
+	if err := opts.Policy.CheckOwner(opts.Owner); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if _, err := tea.NewProgram(tui.NewExploreModel(opts, path)).Run(); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+}
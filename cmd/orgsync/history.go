@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runHistory prints how repositories fared across the recorded runs
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	days := fs.Int("days", 30, "Look at the runs of the last `n` days")
+	all := fs.Bool("all", false, "Include the runs of every sync root, not only of this directory")
+	file := fs.String("file", "", "History `file` to read (default: "+filepath.Join("$XDG_DATA_HOME", "orgsync", sync.HistoryFile)+")")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history [OPTIONS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nShow the repositories that got slower to sync and those that keep failing, from the\nruns recorded after every sync.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *days <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --days must be positive\n")
+		os.Exit(1)
+	}
+	path := *file
+	if path == "" {
+		var err error
+		if path, err = historyPath(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var root string
+	if !*all {
+		var err error
+		if root, err = filepath.Abs("."); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	runs, err := sync.LoadHistory(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("no runs recorded yet; run %s sync first", os.Args[0])
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	since := time.Now().AddDate(0, 0, -*days)
+	if err := sync.WriteHistory(os.Stdout, sync.AnalyzeHistory(runs, root, since)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// historyPath is the history file in sync.HistoryDir
+func historyPath() (string, error) {
+	dir, err := sync.HistoryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sync.HistoryFile), nil
+}
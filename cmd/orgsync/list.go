@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runList prints the clones recorded in the manifest of the sync root
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	file := fs.String("file", sync.ManifestFile, "Manifest `file` written by orgsync sync")
+	format := fs.String("format", "text", "Output format: text or json")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s list [OPTIONS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nList the clones in this directory with their branch, HEAD and last sync.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	manifest, err := sync.LoadManifest(*file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("no manifest in this directory; run %s sync first", os.Args[0])
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := sync.FormatManifest(manifest, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(out)
+}
@@ -1,68 +1,401 @@
 package main
 
 import (
-	"flag"
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
 	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/jdmcgrath/orgsync/sync/tui"
 )
 
+// command is a subcommand of orgsync
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands are listed in this order in the usage message
+var commands = []command{
+	{"sync", "Synchronize the repositories of an organization, user or gists", runSyncCommand},
+	{"list", "List the clones in this directory", runList},
+	{"exec", "Run a command in every clone in this directory", runExec},
+	{"status", "Print the progress of a run started with --status-file", runStatus},
+	{"clean", "Remove what interrupted runs left behind", runClean},
+	{"doctor", "Check that git, gh and this directory are ready to sync", runDoctor},
+	{"rerun", "Repeat the previous run in this directory", runRerun},
+	{"history", "Show repositories that got slower or keep failing across runs", runHistory},
+	{"explore", "Browse an organization and compose a profile", runExplore},
+}
+
 func main() {
-	// Define flags
-	var (
-		help bool
-	)
-
-	// Set up flag usage
-	flag.BoolVar(&help, "help", false, "Show this help message")
-
-	// Customize usage message
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] org\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "\nSynchronize all repositories for a given GitHub organization.\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExample:\n")
-		fmt.Fprintf(os.Stderr, "  %s my-org\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "\nDependencies:\n")
-		fmt.Fprintf(os.Stderr, "  This program requires the GitHub CLI (`gh`) to be installed and authenticated.\n")
-	}
-
-	// Parse arguments
-	flag.Parse()
-
-	// Show help message if requested
-	if help {
-		flag.Usage()
-		os.Exit(0)
-	}
-
-	// Ensure organization name is provided
-	if flag.NArg() != 1 {
-		flag.Usage()
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
 		os.Exit(1)
 	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			c.run(args[1:])
+			return
+		}
+	}
+	// Anything else is an organization or a flag, which sync handles as it always has
+	runSyncCommand(args)
+}
 
-	// Retrieve the organization name
-	org := flag.Arg(0)
-	if org == "" {
-		log.Fatalf("Error: organization name must not be empty")
+// usage lists the subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [OPTIONS] [args]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [OPTIONS] org (short for sync)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the options of a command.\n", os.Args[0])
+}
 
-	// Log the start of the synchronization process
-	log.Printf("Starting synchronization for organization: %s\n", org)
+// runSync runs the TUI for the given settings, optionally restricted to the repositories
+// in only, then writes the report and records the run for `orgsync rerun`. With a record
+// path, what the TUI receives during the run is saved there for --replay.
+func runSync(run sync.LastRun, only []string, config sync.Config, record string) tui.Model {
+	name := strings.Join(run.Options.AllOwners(), ",")
+	opts := run.Options
+	opts.Only = only
+	opts.Policy = config.Policy
+	opts.Keep = config.Keep
+	opts.Branches = config.Branches
+	opts.Retry = config.Retry
+	opts.Hooks = config.Hooks
+	opts.Notify = config.Notify
+	ignore, err := sync.LoadIgnore(sync.IgnoreFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	opts.Ignore = ignore
+	if opts.Profile != "" {
+		selection, ok := config.Profiles[opts.Profile]
+		if !ok {
+			log.Fatalf("Error: no profile %q in the config file", opts.Profile)
+		}
+		opts.Selection = selection
+		if err := selection.Auth.Apply(); err != nil {
+			log.Fatalf("Error: profile %s: %v", opts.Profile, err)
+		}
+		if selection.Auth.Host != "" {
+			opts.Host = selection.Auth.Host
+		}
+	}
+
+	// Make sure the sync root can be written to before starting any work
+	if err := sync.CheckWritable("."); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Keep other orgsync processes out of the sync root until this run is over
+	release, err := sync.AcquireLock(".")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer release()
+
+	// Refuse targets the configured policy does not allow
+	if err := opts.Policy.CheckHost(opts.Host); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	for _, owner := range opts.AllOwners() {
+		if owner == "" {
+			continue
+		}
+		if err := opts.Policy.CheckOwner(owner); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	// The completion screen tells what changed since the last run in this sync root
+	opts.Baseline = loadBaseline(opts)
+
+	if metrics := serveMetrics(opts.MetricsAddr); metrics != nil {
+		opts.Subscribers = append(opts.Subscribers, metrics)
+	}
 
 	// Initialize the Bubble Tea program
-	p := tea.NewProgram(sync.NewModel(org))
+	model := tui.NewModel(opts)
+	notifier := &sync.Notifier{Notify: opts.Notify}
+	if opts.Watch > 0 {
+		// Every run of watch mode is finished as it completes, while the program keeps
+		// running; problems are shown in the UI rather than logged over it
+		model.AfterRun = func(m tui.Model) error {
+			var warnings []error
+			err := finishRun(m, run, notifier, func(err error) { warnings = append(warnings, err) })
+			return errors.Join(append([]error{err}, warnings...)...)
+		}
+	}
+	if record != "" {
+		model.Record = &sync.Recording{}
+	}
+	p := newProgram(model)
+
+	// Log the start of the synchronization process
+	logger.Info("starting synchronization", "target", name, "run_id", model.RunID)
 
 	// Run the program and handle errors
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		log.Fatalf("Error: %v\n", err)
 	}
+	model = final.(tui.Model)
+
+	// The recording is kept however the run ended, as that may be what it is for
+	if record != "" {
+		if err := model.Record.Save(record); err != nil {
+			logger.Warn("failed to save the recording", "error", err)
+		}
+	}
+
+	// Watch mode already finished every run that completed
+	if model.AfterRun == nil || !model.Done {
+		if err := finishRun(model, run, notifier, func(err error) { logger.Warn("failed to finish the run", "error", err) }); err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+	}
 
 	// Log the completion of the synchronization process
-	log.Printf("Synchronization completed for organization: %s\n", org)
+	logger.Info("synchronization completed", "target", name, "run_id", model.RunID)
+	return model
+}
+
+// finishRun writes the report of a run, updates the manifest, runs the post_run hook,
+// sends the notification and records the run for `orgsync rerun` and `orgsync history`. Failing to write the report is an error;
+// the other steps pass their failures to warn.
+func finishRun(model tui.Model, run sync.LastRun, notifier *sync.Notifier, warn func(error)) error {
+	report := model.Report()
+	if run.ReportFormat != "" {
+		if err := writeReport(report, run.ReportFormat, run.ReportFile, model.Options.Fsync); err != nil {
+			return err
+		}
+	}
+
+	// Keep the inventory of the clones on disk current for other tools
+	if err := model.WriteManifest(sync.ManifestFile); err != nil {
+		warn(err)
+	}
+
+	if err := sync.RunPostRunHook(model.Options.Hooks, report, run.ReportFile); err != nil {
+		warn(err)
+	}
+	if err := notifier.Send(report, time.Now()); err != nil {
+		warn(err)
+	}
+
+	// Remember the effective settings so the run can be repeated
+	run.RunID = model.RunID
+	run.Failed = model.Failed()
+	run.FinishedAt = time.Now()
+	if err := sync.SaveLastRun(sync.LastRunFile, run); err != nil {
+		warn(err)
+	}
+	if err := appendHistory(report); err != nil {
+		warn(err)
+	}
+	return nil
+}
+
+// appendHistory records the finished run in the history shared by every sync root
+func appendHistory(report sync.Report) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return sync.AppendHistory(path, root, report)
+}
+
+// loadBaseline reads what the history knows about earlier runs like this one. Without
+// a history there is nothing to compare with, which is not worth a warning.
+func loadBaseline(opts sync.Options) *sync.Baseline {
+	path, err := historyPath()
+	if err != nil {
+		return nil
+	}
+	runs, err := sync.LoadHistory(path)
+	if err != nil {
+		return nil
+	}
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return nil
+	}
+	return sync.NewBaseline(runs, root, opts)
+}
+
+// logger records what the command does. It writes to stderr like the log package,
+// unless setupLogging directs it to a log file.
+var logger = slog.Default()
+
+// setupLogging applies --log-level, and with a --log-file path sends the records of
+// this command and of the sync engine there as JSON, so they survive the terminal UI.
+// Fatal errors are still printed to stderr. quiet leaves out records below warnings
+// that would go to stderr.
+func setupLogging(path, level string, quiet bool) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		log.Fatalf("Error: --log-level %q must be debug, info, warn or error", level)
+	}
+	// Quiet runs print nothing to stderr unless something went wrong
+	if quiet && path == "" {
+		l = max(l, slog.LevelWarn)
+	}
+	slog.SetLogLoggerLevel(l)
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("Error: failed to open log file: %v", err)
+	}
+	logger = slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: l}))
+	sync.SetLogger(logger)
+}
+
+// serveMetrics starts serving Prometheus metrics at /metrics on addr in the background
+// and returns them, or returns nil when addr is empty
+func serveMetrics(addr string) *sync.Metrics {
+	if addr == "" {
+		return nil
+	}
+	// Listen before starting the UI so that a taken port is reported right away
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Error: failed to serve metrics: %v", err)
+	}
+	metrics := &sync.Metrics{}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go http.Serve(listener, mux)
+	return metrics
+}
+
+// exitCode reports failures to automation: 1 when more than maxFailures repositories
+// failed, 2 when the run was interrupted before every repository finished
+func exitCode(model tui.Model, maxFailures int) int {
+	report := model.Report()
+	totals := report.Totals
+	switch {
+	case len(report.Errors) > 0 || totals.Failed+totals.Conflicts > maxFailures:
+		return 1
+	case totals.Pending > 0 || totals.Cancelled > 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// resumeCompleted returns the repositories the interrupted previous run in this
+// directory already synchronized, or nothing when there is no such run to resume
+func resumeCompleted(opts sync.Options) []string {
+	state, err := sync.LoadState(sync.StateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Info("no previous run to resume, starting from scratch")
+		return nil
+	case err != nil:
+		log.Fatalf("Error: %v", err)
+	case !opts.Resumes(state):
+		log.Fatalf("Error: the last run in this directory synchronized a different target; run without --resume")
+	case state.Finished:
+		logger.Info("the last run finished, starting from scratch", "run_id", state.RunID)
+		return nil
+	}
+	logger.Info("resuming run, skipping repositories already synchronized", "run_id", state.RunID, "skipped", len(state.Completed))
+	return state.Completed
+}
+
+// loadConfig reads the config file, falling back to the default location when path is empty
+func loadConfig(path string) sync.Config {
+	required := path != ""
+	if path == "" {
+		path = sync.DefaultConfigPath()
+	}
+	config, err := sync.LoadConfig(path, required)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	return config
+}
+
+// applyTheme selects the configured color palette, or plain text when colors are disabled,
+// and ASCII-only characters when asked to or configured to
+func applyTheme(config sync.Config, noColor, ascii bool) {
+	switch {
+	case ascii:
+		tui.ApplyASCII(true)
+	case config.ASCII != nil:
+		tui.ApplyASCII(*config.ASCII)
+	}
+	theme, err := sync.LookupTheme(config.Theme, config.Colors)
+	if err != nil {
+		log.Fatalf("Error: %v (expected one of %s)", err, strings.Join(sync.ThemeNames(), ", "))
+	}
+	tui.ApplyTheme(theme, noColor || os.Getenv("NO_COLOR") != "")
+}
+
+// applyKeys sets the key bindings of the terminal UI from the config
+func applyKeys(config sync.Config) {
+	keys, err := tui.LookupKeys(config.Keys)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	tui.ApplyKeys(keys)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ghHost returns the GitHub host gh will talk to
+func ghHost() string {
+	if host := os.Getenv("GH_HOST"); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+// writeReport writes the run report to path, or to standard output when path is empty.
+// Report files are replaced atomically so that readers never see a partial report.
+func writeReport(report sync.Report, format, path string, durable bool) error {
+	if path == "" {
+		return sync.WriteReport(os.Stdout, report, format)
+	}
+
+	var buf bytes.Buffer
+	if err := sync.WriteReport(&buf, report, format); err != nil {
+		return err
+	}
+	return sync.WriteFileAtomic(path, buf.Bytes(), 0o644, durable)
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync/tui"
+)
+
+// newProgram returns the program running model. In plain and quiet mode it neither
+// draws the screen nor puts the terminal into raw mode, so Ctrl+C interrupts as usual;
+// the interrupt is passed to the model to drain the run rather than quitting right away.
+func newProgram(model tui.Model) *tea.Program {
+	if !model.Options.Plain && !model.Options.Quiet {
+		return tea.NewProgram(model)
+	}
+	p := tea.NewProgram(model, tea.WithoutRenderer(), tea.WithInput(nil), tea.WithoutSignalHandler())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range interrupts {
+			p.Send(tui.InterruptMsg{})
+		}
+	}()
+	return p
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/jdmcgrath/orgsync/sync/tui"
+)
+
+// runReplay plays a run recorded with --record back through the terminal UI at speed
+// times the recorded pace. Nothing is synchronized: neither git nor gh run, and no file
+// in the current directory is written. plain plays it back as plain text, and quiet
+// prints only what went wrong.
+func runReplay(path string, speed float64, plain, quiet bool) {
+	recording, err := sync.LoadRecording(path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	opts := recording.Options
+	opts.Replay = recording
+	opts.ReplaySpeed = speed
+	// One playback is enough, and there is nothing to report or serve
+	opts.Watch = 0
+	opts.StatusFile = ""
+	opts.MetricsAddr = ""
+	opts.Plain = plain
+	opts.Quiet = quiet
+	if _, err := newProgram(tui.NewModel(opts)).Run(); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runRerun repeats the previous run recorded in the sync root
+func runRerun(args []string) {
+	fs := flag.NewFlagSet("rerun", flag.ExitOnError)
+	failed := fs.Bool("failed", false, "Only retry the repositories that failed last time")
+	configPath := fs.String("config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+	noColor := fs.Bool("no-color", false, "Disable colors in the terminal UI (also set by NO_COLOR)")
+	ascii := fs.Bool("ascii", false, "Draw the terminal UI with ASCII characters only (default: detected from TERM and the locale)")
+	logFile := fs.String("log-file", "", "Append structured JSON logs of every command, retry and status change to `file`")
+	logLevel := fs.String("log-level", "info", "Log records of this `level` and above: debug, info, warn or error")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s rerun [OPTIONS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nRepeat the previous run in this directory with the same settings.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	run, err := sync.LoadLastRun(sync.LastRunFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	setupLogging(*logFile, *logLevel, run.Options.Quiet)
+
+	if run.Options.Host == "" {
+		run.Options.Host = ghHost()
+	}
+
+	var only []string
+	if *failed {
+		if len(run.Failed) == 0 {
+			logger.Info("no failed repositories in the last run")
+			return
+		}
+		only = run.Failed
+	}
+
+	config := loadConfig(*configPath)
+	applyTheme(config, *noColor || run.Options.Plain, *ascii || run.Options.Plain)
+	applyKeys(config)
+	os.Exit(exitCode(runSync(run, only, config, ""), run.MaxFailures))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runStatus prints the progress of a run started with --status-file
+func runStatus(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	file := fs.String("file", sync.DefaultStatusFile, "Status file written by a running orgsync")
+	format := fs.String("format", "text", "Output format: text, tmux, json or snapshot (every repository, as JSON)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s status [OPTIONS]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nPrint the progress of a run started with --status-file.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample (in ~/.tmux.conf):\n")
+		fmt.Fprintf(os.Stderr, "  set -g status-right '#(cd ~/src/my-org && orgsync status --format tmux)'\n")
+	}
+	fs.Parse(args)
+
+	snapshot, err := sync.ReadSnapshot(*file)
+	if err != nil {
+		// tmux polls continuously, so stay silent when no run is in progress
+		if *format == "tmux" {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := sync.FormatSnapshot(snapshot, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(out)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// runSyncCommand synchronizes the repositories of an organization, user or gists.
+// It is also what a bare `orgsync org` runs.
+func runSyncCommand(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+
+	// Define flags
+	var (
+		help           bool
+		user           bool
+		collaborations bool
+		gists          bool
+		statusFile     string
+		reportFormat   string
+		reportFile     string
+		configPath     string
+		failFast       bool
+		pauseAfter     int
+		maxFailures    int
+		prune          bool
+		moveArchived   bool
+		onConflict     string
+		fixRemotes     bool
+		noColor        bool
+		plain          bool
+		ascii          bool
+		quiet          bool
+		concurrency    int
+		order          string
+		protocol       string
+		resume         bool
+		profile        string
+		submodules     bool
+		fsync          bool
+		mirror         bool
+		bare           bool
+		archive        string
+		archiveDir     string
+		ci             bool
+		checkout       bool
+		stash          bool
+		fetchPrune     bool
+		fetchTags      bool
+		maintenance    bool
+		singleBranch   bool
+		register       bool
+		noTouch        bool
+		force          bool
+		yes            bool
+		layout         string
+		ignoreDisk     bool
+		team           string
+		languages      string
+		only           string
+		reposFile      string
+		stdin          bool
+		maxSize        string
+		watch          time.Duration
+		metricsAddr    string
+		logFile        string
+		logLevel       string
+		verbose        bool
+		saveLogs       bool
+		record         string
+		replay         string
+		replaySpeed    float64
+	)
+
+	// Set up flag usage
+	fs.BoolVar(&help, "help", false, "Show this help message")
+	fs.StringVar(&configPath, "config", "", "Path to the config `file` (default "+sync.DefaultConfigPath()+")")
+	fs.BoolVar(&user, "user", false, "Synchronize repositories owned by a user instead of an organization")
+	fs.BoolVar(&collaborations, "collaborations", false, "With --user, also include repositories the user collaborates on")
+	fs.StringVar(&statusFile, "status-file", "", "Write live progress to `file` for 'orgsync status' (e.g. "+sync.DefaultStatusFile+")")
+	fs.StringVar(&reportFormat, "output", "", "Write a report after the run in this `format` ("+strings.Join(sync.FormatterNames(), ", ")+")")
+	fs.StringVar(&reportFormat, "report", "", "Alias for --output")
+	fs.StringVar(&reportFile, "report-file", "", "Write the report to this `file` instead of standard output")
+	fs.IntVar(&concurrency, "concurrency", 0, "Sync at most `n` repositories at once (0 for no limit)")
+	fs.StringVar(&protocol, "protocol", "", "Clone over this `protocol`: https, ssh, or auto to fall back to the other one when authenticating fails (default: gh's git_protocol)")
+	fs.StringVar(&order, "order", "", "Start repositories in this `order`: size-asc, size-desc, name or random (default: as listed by GitHub)")
+	fs.BoolVar(&failFast, "fail-fast", false, "Cancel remaining work after the first failure")
+	fs.IntVar(&pauseAfter, "pause-after", 10, "Pause the run after `n` repositories in a row fail with network or authentication errors (0 never pauses)")
+	fs.IntVar(&maxFailures, "max-failures", 0, "Exit with status 1 when more than this many repositories fail")
+	fs.BoolVar(&prune, "prune", false, "Delete local clones of repositories that no longer exist upstream (except pinned ones and those holding work on no remote), asking first in the terminal UI")
+	fs.BoolVar(&yes, "yes", false, "Prune without asking first in the terminal UI")
+	fs.BoolVar(&moveArchived, "move-archived", false, "Move clones of archived or transferred repositories into archive/ instead of fetching them")
+	fs.StringVar(&onConflict, "on-conflict", sync.ConflictSkip, "What to do when a local directory's origin is a different repository: skip, adopt or relocate")
+	fs.BoolVar(&fixRemotes, "fix-remotes", false, "Point the origin of existing clones at the current host, and over --protocol when it is https or ssh, before fetching them")
+	fs.BoolVar(&noColor, "no-color", false, "Disable colors in the terminal UI (also set by NO_COLOR)")
+	fs.BoolVar(&ascii, "ascii", false, "Draw the terminal UI with ASCII characters only (default: detected from TERM and the locale)")
+	fs.BoolVar(&quiet, "quiet", false, "Print nothing unless the run fails, then only a summary line and the failures to stderr, e.g. for cron")
+	fs.BoolVar(&plain, "plain", false, "Print a line of plain text per repository instead of the terminal UI, for screen readers and dumb terminals")
+	fs.StringVar(&layout, "layout", sync.DefaultLayout, "Where to put each clone, as a `template` using {owner}, {org}, {language} and {repo}, e.g. {org}/{repo}")
+	fs.StringVar(&team, "team", "", "Only sync the repositories of the team with this `slug` in the organization")
+	fs.StringVar(&languages, "language", "", "Only sync repositories whose primary language is in this comma-separated `list`, e.g. go,python")
+	fs.StringVar(&only, "only", "", "Only sync the repositories in this comma-separated `list`, as repo or org/repo, without listing those of the organization")
+	fs.StringVar(&reposFile, "repos-file", "", "Like --only, with the repositories read from `file`, one per line (- for standard input)")
+	fs.BoolVar(&stdin, "stdin", false, "Like --only, with the repositories read from standard input, e.g. piped from 'gh repo list'")
+	fs.StringVar(&maxSize, "max-size", "", "Skip repositories whose GitHub-reported disk usage exceeds this `size`, e.g. 500MB")
+	fs.StringVar(&profile, "profile", "", "Only sync the repositories selected by this `profile` from the config file")
+	fs.BoolVar(&mirror, "mirror", false, "Keep <name>.git mirror clones with every ref and no working tree, e.g. for backups")
+	fs.BoolVar(&bare, "bare", false, "Keep <name>.git bare clones of the branches without working trees, e.g. for code search")
+	fs.StringVar(&archive, "archive", "", "Pack each repository that synchronized successfully into an archive of this `format` (tar.zst, tar.gz or tar), unless its HEAD has not changed since it was last packed")
+	fs.StringVar(&archiveDir, "archive-dir", sync.DefaultArchiveOutput, "Write the archives of --archive under this `directory`")
+	fs.BoolVar(&ci, "ci", false, "Record the latest GitHub Actions run on each default branch (one API call per repository)")
+	fs.BoolVar(&checkout, "checkout", false, "After fetching, check out and fast-forward the default branch of clean working trees")
+	fs.BoolVar(&noTouch, "no-touch-worktree", false, "Guarantee that existing working trees are never modified, only fetched (the default unless --checkout or --recurse-submodules)")
+	fs.BoolVar(&stash, "stash", false, "Stash local changes before --checkout or --recurse-submodules update a working tree and restore them afterwards, instead of skipping it")
+	fs.BoolVar(&force, "force", false, "Let --checkout, --recurse-submodules, --fix-remotes and --on-conflict adopt act on working trees with local changes, and --prune delete clones holding work on no remote")
+	fs.BoolVar(&fetchPrune, "fetch-prune", false, "Fetch with --prune to delete remote-tracking branches removed upstream")
+	fs.BoolVar(&fetchTags, "fetch-tags", false, "Fetch with --tags to get every tag")
+	fs.BoolVar(&singleBranch, "single-branch", false, "Clone and fetch only the default branch of each repository (or the branch pinned in the config file) instead of every branch")
+	fs.BoolVar(&maintenance, "maintenance", false, "Run 'git maintenance run --auto' (or 'git gc --auto' with git before 2.29) in each clone that was not already up to date")
+	fs.BoolVar(&register, "maintenance-register", false, "Like --maintenance, and also register every clone with 'git maintenance register' for git's background maintenance")
+	fs.BoolVar(&submodules, "recurse-submodules", false, "Clone submodules with each repository and update them on fetch")
+	fs.BoolVar(&ignoreDisk, "ignore-disk-space", false, "Start new clones even when they look too big for the free disk space")
+	fs.BoolVar(&fsync, "fsync", false, "Flush state and report files to disk before carrying on, so they survive a crash")
+	fs.DurationVar(&watch, "watch", 0, "Keep running and sync again this long after each run finishes, e.g. 30m")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "With --watch, serve Prometheus metrics on this `address` at /metrics, e.g. :9090")
+	fs.StringVar(&logFile, "log-file", "", "Append structured JSON logs of every command, retry and status change to `file`")
+	fs.StringVar(&logLevel, "log-level", "info", "Log records of this `level` and above: debug, info, warn or error")
+	fs.BoolVar(&verbose, "verbose", false, "Keep the complete output of every git and gh command and show it in the detail pane")
+	fs.BoolVar(&saveLogs, "save-logs", false, "Like --verbose, and also save each repository's command output under .orgsync/logs")
+	fs.StringVar(&record, "record", "", "Record what the terminal UI receives during the run to `file`, for --replay")
+	fs.StringVar(&replay, "replay", "", "Play back a run recorded with --record from `file` instead of synchronizing anything")
+	fs.Float64Var(&replaySpeed, "replay-speed", 1, "Play back --replay this many `times` as fast as it was recorded")
+	fs.BoolVar(&resume, "resume", false, "Skip repositories already synchronized by the last run if it was interrupted")
+	fs.BoolVar(&gists, "gists", false, "Synchronize gists into a gists/ subdirectory (defaults to the authenticated user)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s sync [OPTIONS] org...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s sync --gists [user]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [OPTIONS] org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s sync --replay run.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nSynchronize all repositories for a given GitHub organization or user, or for several\norganizations at once, each cloned into a directory of its own.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  %s my-org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync my-org other-org third-org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync --user --collaborations jdmcgrath\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync --gists jdmcgrath\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync --only api,web my-org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  gh repo list my-org --topic backend | %s sync --stdin my-org\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nExit status:\n")
+		fmt.Fprintf(os.Stderr, "  0 on success, 1 on errors or when more than --max-failures repositories failed,\n")
+		fmt.Fprintf(os.Stderr, "  2 when the run was interrupted before every repository finished.\n")
+		fmt.Fprintf(os.Stderr, "\nDependencies:\n")
+		fmt.Fprintf(os.Stderr, "  This program requires the GitHub CLI (`gh`) to be installed and authenticated.\n")
+	}
+
+	// Parse arguments
+	fs.Parse(args)
+
+	// Show help message if requested
+	if help {
+		fs.Usage()
+		os.Exit(0)
+	}
+
+	if quiet && plain {
+		log.Fatalf("Error: --quiet and --plain cannot be combined")
+	}
+
+	// A replay needs neither an organization nor GitHub
+	if replay != "" {
+		if fs.NArg() > 0 || record != "" {
+			log.Fatalf("Error: --replay plays back a recorded run and takes no organization or --record")
+		}
+		if replaySpeed <= 0 {
+			log.Fatalf("Error: --replay-speed must be positive")
+		}
+		config := loadConfig(configPath)
+		applyTheme(config, noColor || plain, ascii || plain)
+		applyKeys(config)
+		runReplay(replay, replaySpeed, plain, quiet)
+		return
+	}
+
+	// Ensure organization name is provided; gists default to the authenticated user
+	if fs.NArg() == 0 && !gists {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if fs.NArg() > 1 && (user || gists) {
+		log.Fatalf("Error: only organizations can be synchronized several at once, not users or gists")
+	}
+	if fs.NArg() > 1 && team != "" {
+		log.Fatalf("Error: --team selects repositories of one organization and cannot be combined with several")
+	}
+
+	// Retrieve the organization or user name
+	org := fs.Arg(0)
+	var orgs []string
+	if fs.NArg() > 1 {
+		orgs = fs.Args()
+	}
+	for _, name := range fs.Args() {
+		if name == "" {
+			log.Fatalf("Error: organization name must not be empty")
+		}
+	}
+
+	if reportFormat != "" && !sync.ValidReportFormat(reportFormat) {
+		log.Fatalf("Error: unknown report format %q", reportFormat)
+	}
+
+	switch onConflict {
+	case sync.ConflictSkip, sync.ConflictAdopt, sync.ConflictRelocate:
+	default:
+		log.Fatalf("Error: --on-conflict must be skip, adopt or relocate")
+	}
+
+	// Several organizations get a directory each, unless the layout already has one
+	if len(orgs) > 1 && layout == sync.DefaultLayout {
+		layout = sync.OwnerLayout
+	}
+	if err := sync.ValidateLayout(layout); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(orgs) > 1 && !strings.Contains(layout, "{owner}") && !strings.Contains(layout, "{org}") {
+		log.Fatalf("Error: --layout must contain {owner} or {org} to keep several organizations apart")
+	}
+	var maxBytes int64
+	if maxSize != "" {
+		n, err := sync.ParseByteSize(maxSize)
+		if err != nil {
+			log.Fatalf("Error: --max-size: %v", err)
+		}
+		maxBytes = n
+	}
+
+	if watch < 0 {
+		log.Fatalf("Error: --watch must not be negative")
+	}
+	if watch > 0 && reportFormat != "" && reportFile == "" {
+		log.Fatalf("Error: --watch writes a report after every run and needs --report-file to keep it off the screen")
+	}
+	if metricsAddr != "" && watch == 0 {
+		log.Fatalf("Error: --metrics-addr requires --watch")
+	}
+
+	if concurrency < 0 {
+		log.Fatalf("Error: --concurrency must not be negative")
+	}
+	if !sync.ValidOrder(order) {
+		log.Fatalf("Error: --order must be size-asc, size-desc, name or random")
+	}
+	if !sync.ValidProtocol(protocol) {
+		log.Fatalf("Error: --protocol must be https, ssh or auto")
+	}
+	if pauseAfter < 0 {
+		log.Fatalf("Error: --pause-after must not be negative")
+	}
+
+	if singleBranch && mirror {
+		log.Fatalf("Error: --single-branch cannot be combined with --mirror, which keeps every ref")
+	}
+	if mirror && bare {
+		log.Fatalf("Error: --mirror and --bare cannot be combined; mirror clones are already bare")
+	}
+	if (mirror || bare) && (submodules || checkout) {
+		log.Fatalf("Error: --recurse-submodules and --checkout need working trees and cannot be combined with --mirror or --bare")
+	}
+	if noTouch && (checkout || stash || prune) {
+		log.Fatalf("Error: --checkout, --stash and --prune modify working trees and cannot be combined with --no-touch-worktree")
+	}
+	if stash && !checkout && !submodules {
+		log.Fatalf("Error: --stash only applies together with --checkout or --recurse-submodules")
+	}
+	if yes && !prune {
+		log.Fatalf("Error: --yes only applies together with --prune")
+	}
+
+	if !sync.ValidArchiveFormat(archive) {
+		log.Fatalf("Error: --archive must be tar.zst, tar.gz or tar")
+	}
+	if archiveDir == "" {
+		log.Fatalf("Error: --archive-dir must not be empty")
+	}
+
+	if team != "" && (user || gists) {
+		log.Fatalf("Error: --team selects repositories of an organization and cannot be combined with --user or --gists")
+	}
+	if languages != "" && gists {
+		log.Fatalf("Error: gists have no language, so --language cannot be combined with --gists")
+	}
+	if stdin {
+		if reposFile != "" {
+			log.Fatalf("Error: --stdin and --repos-file cannot be combined; --stdin is short for --repos-file -")
+		}
+		reposFile = "-"
+	}
+	repos := readRepos(only, reposFile)
+	if (only != "" || reposFile != "") && len(repos) == 0 {
+		log.Fatalf("Error: --only, --repos-file and --stdin name no repositories")
+	}
+	if len(repos) > 0 && (team != "" || languages != "" || maxSize != "" || profile != "" || strings.Contains(layout, "{language}")) {
+		log.Fatalf("Error: --only, --repos-file and --stdin name repositories without looking them up, so they cannot be filtered with --team, --language, --max-size or --profile, nor placed by a {language} layout")
+	}
+	if len(repos) > 0 && (prune || moveArchived || ci) {
+		log.Fatalf("Error: --prune, --move-archived and --ci need the repositories listed from GitHub and cannot be combined with --only, --repos-file or --stdin")
+	}
+	if collaborations && !user {
+		log.Fatalf("Error: --collaborations requires --user")
+	}
+	if gists && user {
+		log.Fatalf("Error: --gists and --user cannot be combined")
+	}
+
+	setupLogging(logFile, logLevel, quiet)
+	config := loadConfig(configPath)
+	applyTheme(config, noColor || plain, ascii || plain)
+	applyKeys(config)
+
+	opts := sync.Options{Owner: org, Owners: orgs, Target: sync.TargetOrg, StatusFile: statusFile, Host: ghHost(), FailFast: failFast, PauseAfter: pauseAfter, Prune: prune, MoveArchived: moveArchived, OnConflict: onConflict, FixRemotes: fixRemotes, Concurrency: concurrency, Order: order, Protocol: protocol, Profile: profile, Submodules: submodules, Fsync: fsync, Mirror: mirror, Bare: bare, CI: ci, Checkout: checkout, Stash: stash, FetchPrune: fetchPrune || config.Fetch.Prune, FetchTags: fetchTags || config.Fetch.Tags, NoTouchWorktree: noTouch, Force: force, Layout: layout, IgnoreDiskSpace: ignoreDisk, Team: team, Languages: splitList(languages), MaxSize: maxBytes, Watch: watch, MetricsAddr: metricsAddr, Verbose: verbose || saveLogs, SaveLogs: saveLogs, Plain: plain, Quiet: quiet, Repos: repos, Archive: archive, Maintenance: maintenance || register || config.Fetch.Maintenance, MaintenanceRegister: register, SingleBranch: singleBranch || config.Fetch.SingleBranch}
+	if archive != "" {
+		opts.ArchiveOutput = archiveDir
+	}
+	// Only the terminal UI can ask, and a run repeated by --watch has no one to answer
+	opts.Confirm = prune && !yes && !plain && !quiet && watch == 0
+	switch {
+	case gists:
+		opts.Target = sync.TargetGists
+	case user:
+		opts.Target = sync.TargetUser
+		opts.Collaborations = collaborations
+	}
+
+	if resume {
+		opts.Completed = resumeCompleted(opts)
+	}
+
+	run := sync.LastRun{Options: opts, ReportFormat: reportFormat, ReportFile: reportFile, MaxFailures: maxFailures}
+	os.Exit(exitCode(runSync(run, nil, config, record), maxFailures))
+}
+
+// readRepos returns the repositories named by --only and in the --repos-file, in that
+// order, reading the file from standard input for "-"
+func readRepos(only, path string) []string {
+	repos := splitList(only)
+	if path == "" {
+		return repos
+	}
+	in := os.Stdin
+	if path == "-" {
+		// Reading names from a terminal would wait for the user to type them
+		if info, err := in.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			log.Fatalf("Error: the repositories are read from standard input, which is a terminal; pipe them in, e.g. from 'gh repo list'")
+		}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error: --repos-file: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	listed, err := sync.ReadRepoList(in)
+	if err != nil {
+		log.Fatalf("Error: --repos-file: %v", err)
+	}
+	return append(repos, listed...)
+}
@@ -0,0 +1,334 @@
+// Package harness builds local fixtures for exercising orgsync end to end without
+// network access or GitHub credentials. A Fixture keeps bare repositories standing in
+// for GitHub and installs a fake gh on PATH that lists and clones them. git is
+// configured to rewrite https://github.com/ to the fixture, so clones keep the origin
+// URLs orgsync expects while every fetch stays on the local file system.
+//
+// A test of the sync package typically looks like:
+//
+//	f := harness.New(t, "acme")
+//	f.AddRepo("api", map[string]string{"README.md": "hello"})
+//	f.Setenv()
+//	// run orgsync against f.Owner in f.Root
+package harness
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Host is the GitHub host the fixture pretends to be
+const Host = "github.com"
+
+// Fixture is a temporary directory holding fake upstream repositories and a sync root
+type Fixture struct {
+	// Owner is the organization the fixture's repositories belong to
+	Owner string
+	// Dir holds remotes/, bin/, work/, failures/, redirects/ and the sync root
+	Dir string
+	// Root is the empty sync root orgsync should run in
+	Root string
+
+	tb  testing.TB
+	env []string
+}
+
+// New creates a fixture for owner in a temporary directory removed after the test
+func New(tb testing.TB, owner string) *Fixture {
+	tb.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		tb.Skip("git is not installed")
+	}
+
+	dir := tb.TempDir()
+	f := &Fixture{Owner: owner, Dir: dir, Root: filepath.Join(dir, "root"), tb: tb}
+	for _, sub := range []string{"root", "bin", "failures", "redirects", filepath.Join("remotes", owner), "work"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			tb.Fatalf("failed to create fixture: %v", err)
+		}
+	}
+
+	gitconfig := filepath.Join(dir, "gitconfig")
+	config := fmt.Sprintf("[url \"file://%s/\"]\n\tinsteadOf = https://%[2]s/\n\tinsteadOf = git@%[2]s:\n[init]\n\tdefaultBranch = main\n", filepath.Join(dir, "remotes"), Host)
+	if err := os.WriteFile(gitconfig, []byte(config), 0o644); err != nil {
+		tb.Fatalf("failed to write git config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "gh"), []byte(fakeGH(dir)), 0o755); err != nil {
+		tb.Fatalf("failed to install fake gh: %v", err)
+	}
+
+	f.env = []string{
+		"PATH=" + filepath.Join(dir, "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"GIT_CONFIG_GLOBAL=" + gitconfig,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME=orgsync harness",
+		"GIT_AUTHOR_EMAIL=harness@example.com",
+		"GIT_COMMITTER_NAME=orgsync harness",
+		"GIT_COMMITTER_EMAIL=harness@example.com",
+		"GH_HOST=" + Host,
+	}
+	return f
+}
+
+// WithOwner returns a fixture for another organization sharing the remotes, fake gh
+// and sync root of f, to set up runs synchronizing several organizations
+func (f *Fixture) WithOwner(owner string) *Fixture {
+	f.tb.Helper()
+	if err := os.MkdirAll(filepath.Join(f.Dir, "remotes", owner), 0o755); err != nil {
+		f.tb.Fatalf("failed to create fixture: %v", err)
+	}
+	other := *f
+	other.Owner = owner
+	return &other
+}
+
+// Env returns the environment variables that point git and gh at the fixture
+func (f *Fixture) Env() []string {
+	return append([]string{}, f.env...)
+}
+
+// Setenv applies Env to the test process and changes into Root, undoing both when the
+// test ends. Tests using it must not run in parallel.
+func (f *Fixture) Setenv() {
+	f.tb.Helper()
+	for _, kv := range f.env {
+		key, value, _ := strings.Cut(kv, "=")
+		f.tb.Setenv(key, value)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		f.tb.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(f.Root); err != nil {
+		f.tb.Fatalf("failed to change into the sync root: %v", err)
+	}
+	f.tb.Cleanup(func() { os.Chdir(wd) })
+}
+
+// remote is the path of the bare repository standing in for name on GitHub
+func (f *Fixture) remote(name string) string {
+	return filepath.Join(f.Dir, "remotes", f.Owner, name+".git")
+}
+
+// work is the scratch working tree used to push commits to name
+func (f *Fixture) work(name string) string {
+	return filepath.Join(f.Dir, "work", f.Owner, name)
+}
+
+// AddRepo creates an upstream repository with an initial commit of files and returns
+// the path of its bare repository
+func (f *Fixture) AddRepo(name string, files map[string]string) string {
+	f.tb.Helper()
+	f.Git("", "init", "--quiet", "--bare", f.remote(name))
+	f.Git("", "clone", "--quiet", f.remote(name), f.work(name))
+	f.Commit(name, files)
+	return f.remote(name)
+}
+
+// Commit writes files to the upstream repository name and pushes them as a new commit
+func (f *Fixture) Commit(name string, files map[string]string) {
+	f.tb.Helper()
+	if len(files) == 0 {
+		files = map[string]string{"README.md": name + "\n"}
+	}
+	for path, content := range files {
+		full := filepath.Join(f.work(name), path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			f.tb.Fatalf("failed to create %s: %v", path, err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			f.tb.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	f.Git(f.work(name), "add", "--all")
+	f.Git(f.work(name), "commit", "--quiet", "--allow-empty", "--message", "harness commit")
+	f.Git(f.work(name), "push", "--quiet", "origin", "HEAD")
+}
+
+// Tag creates tag on the tip of the upstream repository name
+func (f *Fixture) Tag(name, tag string) {
+	f.tb.Helper()
+	f.Git(f.work(name), "tag", tag)
+	f.Git(f.work(name), "push", "--quiet", "origin", tag)
+}
+
+// SetLanguage sets the primary language the fake gh reports for name
+func (f *Fixture) SetLanguage(name, language string) {
+	f.tb.Helper()
+	if err := os.WriteFile(filepath.Join(f.remote(name), "language"), []byte(language), 0o644); err != nil {
+		f.tb.Fatalf("failed to set language of %s: %v", name, err)
+	}
+}
+
+// ArchiveRepo marks the upstream repository name as archived in the listings of the
+// fake gh
+func (f *Fixture) ArchiveRepo(name string) {
+	f.tb.Helper()
+	if err := os.WriteFile(filepath.Join(f.remote(name), "archived"), nil, 0o644); err != nil {
+		f.tb.Fatalf("failed to archive %s: %v", name, err)
+	}
+}
+
+// DeleteRepo removes the upstream repository name, as if it was deleted on GitHub
+func (f *Fixture) DeleteRepo(name string) {
+	f.tb.Helper()
+	if err := os.RemoveAll(f.remote(name)); err != nil {
+		f.tb.Fatalf("failed to delete %s: %v", name, err)
+	}
+}
+
+// RenameRepo renames the upstream repository name to newName, as if it was renamed on
+// GitHub. The old name keeps redirecting to the new one.
+func (f *Fixture) RenameRepo(name, newName string) {
+	f.tb.Helper()
+	if err := os.Rename(f.remote(name), f.remote(newName)); err != nil {
+		f.tb.Fatalf("failed to rename %s: %v", name, err)
+	}
+	if err := os.Rename(f.work(name), f.work(newName)); err != nil {
+		f.tb.Fatalf("failed to rename %s: %v", name, err)
+	}
+	f.Git(f.work(newName), "remote", "set-url", "origin", f.remote(newName))
+	redirect := filepath.Join(f.Dir, "redirects", f.Owner+"_"+name)
+	if err := os.WriteFile(redirect, []byte(f.Owner+"/"+newName+"\n"), 0o644); err != nil {
+		f.tb.Fatalf("failed to redirect %s: %v", name, err)
+	}
+}
+
+// TransferRepo removes the upstream repository name, as if it was transferred to
+// newOwner on GitHub. The old name keeps redirecting to newOwner/name.
+func (f *Fixture) TransferRepo(name, newOwner string) {
+	f.tb.Helper()
+	f.DeleteRepo(name)
+	redirect := filepath.Join(f.Dir, "redirects", f.Owner+"_"+name)
+	if err := os.WriteFile(redirect, []byte(newOwner+"/"+name+"\n"), 0o644); err != nil {
+		f.tb.Fatalf("failed to redirect %s: %v", name, err)
+	}
+}
+
+// FailClone makes the next times clones of name fail with stderr, e.g.
+// "fatal: unable to access: Connection reset by peer" to exercise retries
+func (f *Fixture) FailClone(name string, times int, stderr string) {
+	f.tb.Helper()
+	data := strconv.Itoa(times) + "\n" + stderr + "\n"
+	if err := os.WriteFile(filepath.Join(f.Dir, "failures", f.Owner+"_"+name), []byte(data), 0o644); err != nil {
+		f.tb.Fatalf("failed to inject failure for %s: %v", name, err)
+	}
+}
+
+// Git runs git in dir with the fixture environment and returns its trimmed output.
+// An empty dir runs it in the fixture directory.
+func (f *Fixture) Git(dir string, args ...string) string {
+	f.tb.Helper()
+	if dir == "" {
+		dir = f.Dir
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), f.env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		f.tb.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// fakeGH is a shell script answering the gh invocations orgsync makes from the
+// repositories in dir. Anything else fails loudly so that tests notice new calls.
+func fakeGH(dir string) string {
+	return fmt.Sprintf(`#!/bin/sh
+# Fake gh installed by the orgsync test harness
+remotes='%[1]s/remotes'
+failures='%[1]s/failures'
+redirects='%[1]s/redirects'
+
+case "$1 $2" in
+"api graphql")
+	for arg in "$@"; do
+		case $arg in
+		owner=*) owner=${arg#owner=} ;;
+		esac
+	done
+	if [ ! -d "$remotes/$owner" ]; then
+		echo "GraphQL: Could not resolve to an Organization with the login of '$owner'." >&2
+		exit 1
+	fi
+	printf '{"data":{"repositoryOwner":{"repositories":{"nodes":['
+	separator=
+	for repo in "$remotes/$owner"/*.git; do
+		[ -d "$repo" ] || continue
+		name=$(basename "$repo" .git)
+		branch=null
+		if head=$(git --git-dir="$repo" symbolic-ref --short HEAD 2>/dev/null); then
+			branch="{\"name\":\"$head\"}"
+		fi
+		language=null
+		if [ -f "$repo/language" ]; then
+			language="{\"name\":\"$(cat "$repo/language")\"}"
+		fi
+		archived=false
+		[ -f "$repo/archived" ] && archived=true
+		printf '%%s{"name":"%%s","diskUsage":1,"primaryLanguage":%%s,"repositoryTopics":{"nodes":[]},"pushedAt":null,"defaultBranchRef":%%s,"visibility":"PUBLIC","isArchived":%%s}' \
+			"$separator" "$name" "$language" "$branch" "$archived"
+		separator=,
+	done
+	# Every repository fits on the first page
+	printf '],"pageInfo":{"hasNextPage":false,"endCursor":null}}}}}\n'
+	;;
+"repo clone")
+	source=$3
+	target=$4
+	shift 4
+	[ "$1" = "--" ] && shift
+	# Clones of owner/name go over HTTPS, as with gh's default git_protocol
+	case $source in
+	https://*) repo=${source#https://*/} ;;
+	git@*) repo=${source#*:} ;;
+	*) repo=$source; source="https://%[2]s/$repo.git" ;;
+	esac
+	repo=${repo%%.git}
+	failure="$failures/$(echo "$repo" | tr / _)"
+	if [ -f "$failure" ]; then
+		remaining=$(head -n 1 "$failure")
+		if [ "$remaining" -gt 0 ]; then
+			message=$(tail -n +2 "$failure")
+			printf '%%s\n%%s\n' "$((remaining - 1))" "$message" > "$failure"
+			echo "$message" >&2
+			exit 128
+		fi
+	fi
+	exec git clone "$@" "$source" "$target"
+	;;
+"api repos/"*/*)
+	repo=${2#repos/}
+	case $repo in
+	*/*/*)
+		echo "harness: unsupported gh invocation: gh $*" >&2
+		exit 1
+		;;
+	esac
+	redirect="$redirects/$(echo "$repo" | tr / _)"
+	if [ -f "$redirect" ]; then
+		printf '{"full_name":"%%s"}\n' "$(cat "$redirect")"
+	elif [ -d "$remotes/$repo.git" ]; then
+		printf '{"full_name":"%%s"}\n' "$repo"
+	else
+		echo "gh: Not Found (HTTP 404)" >&2
+		exit 1
+	fi
+	;;
+"api rate_limit")
+	printf '{"resources":{"core":{"limit":5000,"remaining":5000,"reset":%%s}}}\n' "$(($(date +%%s) + 3600))"
+	;;
+*)
+	echo "harness: unsupported gh invocation: gh $*" >&2
+	exit 1
+	;;
+esac
+`, dir, Host)
+}
@@ -0,0 +1,28 @@
+package schema
+
+import "time"
+
+// HistoryRun is one line of the run history: the outcome of every repository of a
+// finished run, kept to spot trends across runs
+type HistoryRun struct {
+	// Schema is HistorySchema
+	Schema string `json:"schema"`
+	RunID  string `json:"run_id"`
+	// Root is the absolute path of the sync root the run worked in
+	Root            string              `json:"root"`
+	Target          string              `json:"target"`
+	StartedAt       time.Time           `json:"started_at"`
+	DurationSeconds float64             `json:"duration_seconds"`
+	Repositories    []HistoryRepository `json:"repositories"`
+}
+
+// HistoryRepository is the outcome of one repository in a HistoryRun
+type HistoryRepository struct {
+	Owner           string  `json:"owner,omitempty"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	ErrorCategory   string  `json:"error_category,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Bytes           int64   `json:"bytes"`
+	UpToDate        bool    `json:"up_to_date,omitempty"`
+}
@@ -0,0 +1,31 @@
+package schema
+
+import "time"
+
+// Manifest is the inventory of the clones in a sync root. Each run updates it, so it
+// also lists clones synchronized by earlier runs as long as they are still on disk.
+type Manifest struct {
+	// Schema is ManifestSchema
+	Schema string `json:"schema"`
+	// RunID identifies the run that last updated the manifest
+	RunID     string    `json:"run_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Repositories are sorted by path
+	Repositories []ManifestEntry `json:"repositories"`
+}
+
+// ManifestEntry describes one clone in the sync root
+type ManifestEntry struct {
+	Owner string `json:"owner,omitempty"`
+	Name  string `json:"name"`
+	// Path is the clone's directory relative to the sync root, separated by slashes
+	Path          string `json:"path"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	// Head is the commit HEAD points to, empty for a repository without commits
+	Head string `json:"head,omitempty"`
+	// Size is the disk usage reported by the GitHub API, in bytes
+	Size int64 `json:"size"`
+	// SyncedAt is when the clone was last synchronized successfully, zero if that
+	// never happened while the manifest was kept
+	SyncedAt time.Time `json:"synced_at"`
+}
@@ -0,0 +1,137 @@
+package schema
+
+import "time"
+
+// Repository statuses used in reports
+const (
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusConflict  = "conflict"
+	StatusSkipped   = "skipped"
+	StatusPending   = "pending"
+)
+
+// Bottlenecks reported for a run's queue wait
+const (
+	BottleneckConcurrency = "concurrency"
+	BottleneckNetwork     = "network"
+)
+
+// Report is a structured summary of a run, produced after the TUI exits
+type Report struct {
+	// Schema is ReportSchema
+	Schema       string             `json:"schema"`
+	RunID        string             `json:"run_id"`
+	Target       string             `json:"target"`
+	StartedAt    time.Time          `json:"started_at"`
+	FinishedAt   time.Time          `json:"finished_at"`
+	Totals       ReportTotals       `json:"totals"`
+	Repositories []RepositoryReport `json:"repositories"`
+	// Pruned lists local clones removed because they no longer exist upstream
+	Pruned []string `json:"pruned,omitempty"`
+	// Transferred lists local clones of repositories transferred to an owner that is
+	// not synchronized, as "dir → owner/name"
+	Transferred []string `json:"transferred,omitempty"`
+	// Warnings are non-fatal notices printed while discovering repositories
+	Warnings []string `json:"warnings,omitempty"`
+	// Errors are run-level failures that stopped the run, such as failed discovery
+	// or too little disk space for the new clones
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ReportTotals aggregates the outcome of every repository in a run
+type ReportTotals struct {
+	Repositories    int            `json:"repositories"`
+	Succeeded       int            `json:"succeeded"`
+	Failed          int            `json:"failed"`
+	Cancelled       int            `json:"cancelled"`
+	Conflicts       int            `json:"conflicts"`
+	Skipped         int            `json:"skipped"`
+	UpToDate        int            `json:"up_to_date"`
+	Pending         int            `json:"pending"`
+	Warnings        int            `json:"warnings"`
+	Bytes           int64          `json:"bytes"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	ErrorCategories map[string]int `json:"error_categories,omitempty"`
+	// CI counts repositories by the outcome of their latest default-branch workflow run
+	CI map[string]int `json:"ci,omitempty"`
+	// Unpushed counts the clones with commits or branches that are on no remote
+	Unpushed int `json:"unpushed,omitempty"`
+	// QueueWaitP95Seconds is the 95th percentile of how long repositories waited for a worker
+	QueueWaitP95Seconds float64 `json:"queue_wait_p95_seconds"`
+	// Bottleneck is BottleneckConcurrency when repositories spent longer waiting for a
+	// worker than syncing, and BottleneckNetwork otherwise
+	Bottleneck string `json:"bottleneck,omitempty"`
+}
+
+// RepositoryReport is the outcome of synchronizing a single repository
+type RepositoryReport struct {
+	Owner           string    `json:"owner,omitempty"`
+	Name            string    `json:"name"`
+	Status          string    `json:"status"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Attempts        int       `json:"attempts"`
+	ErrorCategory   string    `json:"error_category,omitempty"`
+	Hint            string    `json:"hint,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	// Output is the stderr of the failed git or gh command, or for an exec run
+	// everything the command printed
+	Output string `json:"output,omitempty"`
+	Bytes  int64  `json:"bytes"`
+	Size   int64  `json:"size"`
+	// QueueWaitSeconds is how long the repository waited for a free worker
+	QueueWaitSeconds float64 `json:"queue_wait_seconds"`
+	// CI is the outcome of the latest default-branch workflow run, if it was requested
+	CI string `json:"ci,omitempty"`
+	// Warnings are non-fatal notices from git or gh; they do not fail the repository
+	Warnings []string `json:"warnings,omitempty"`
+	// UpToDate is set for a successful repository that already matched origin and
+	// needed no fetch
+	UpToDate bool `json:"up_to_date,omitempty"`
+	// Updated is set for a successful repository whose existing clone fetched new
+	// commits or refs from origin
+	Updated bool `json:"updated,omitempty"`
+	// Archived is set for a repository archived upstream; its clone is still fetched
+	// unless --move-archived moved it aside, which finishes it as skipped
+	Archived bool `json:"archived,omitempty"`
+	// Protocol is the protocol a new clone was made over, "https" or "ssh"
+	Protocol string `json:"protocol,omitempty"`
+	// Upstream is the branch of origin the HEAD of a successfully fetched working tree
+	// was compared with, e.g. origin/main. Ahead counts the commits HEAD has that
+	// Upstream lacks, which are local work, and Behind those it has yet to catch up
+	// with. All three are unset when the clone was not compared.
+	Upstream string `json:"upstream,omitempty"`
+	Ahead    *int   `json:"ahead,omitempty"`
+	Behind   *int   `json:"behind,omitempty"`
+	// Unpushed counts the commits of the clone that no remote branch contains, and
+	// LocalBranches are its branches that no remote has; deleting the clone loses both
+	Unpushed      int      `json:"unpushed,omitempty"`
+	LocalBranches []string `json:"local_branches,omitempty"`
+	// LogFile is the path of the complete command output saved with --save-logs
+	LogFile string `json:"log_file,omitempty"`
+	// Archive is the path of the archive packed with --archive that holds the
+	// repository as synchronized
+	Archive string `json:"archive,omitempty"`
+	// ExitCode is the exit status of the command of an exec run in the repository, or
+	// -1 when it did not run to completion; unset for a sync
+	ExitCode *int `json:"exit_code,omitempty"`
+}
+
+// Key is the repository as owner/name, or its name alone where the report has no owner
+func (r RepositoryReport) Key() string {
+	if r.Owner != "" {
+		return r.Owner + "/" + r.Name
+	}
+	return r.Name
+}
+
+// RepositoryEvent is one line of the NDJSON report: the outcome of a repository, tagged
+// with the run ID so lines from many runs can be shipped to the same log pipeline
+type RepositoryEvent struct {
+	// Schema is EventSchema
+	Schema string `json:"schema"`
+	RunID  string `json:"run_id"`
+	RepositoryReport
+}
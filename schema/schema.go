@@ -0,0 +1,46 @@
+// Package schema defines the machine-readable documents orgsync writes: the run report
+// (--output json), the per-repository events of --output ndjson, the run state file
+// used by --resume, the snapshot in the --status-file, the workspace manifest
+// listing every clone in a sync root and the run history behind `orgsync history`, and
+// identifies the runs saved with --record. Tools that consume them can decode into these
+// types without depending on the terminal UI.
+//
+// Every document carries a schema identifier such as "orgsync.report.v1". Within one
+// version, fields are only ever added: existing fields keep their name, type and
+// meaning, so consumers must ignore fields they do not know. Removing, renaming or
+// changing a field bumps the version, and the identifier changes with it.
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema identifiers of the current document versions
+const (
+	ReportSchema   = "orgsync.report.v1"
+	EventSchema    = "orgsync.event.v1"
+	StateSchema    = "orgsync.state.v2"
+	SnapshotSchema = "orgsync.snapshot.v1"
+	ManifestSchema = "orgsync.manifest.v1"
+	HistorySchema  = "orgsync.history.v1"
+	// RecordingSchema identifies the runs saved with --record, whose type lives with
+	// the engine that plays them back
+	RecordingSchema = "orgsync.recording.v1"
+	// StateSchemaV1 is the previous version of the state file, which listed completed
+	// repositories by name only. orgsync still resumes from it.
+	StateSchemaV1 = "orgsync.state.v1"
+)
+
+// Check returns an error unless a document with the schema identifier got can be read
+// as want. Documents written before orgsync versioned them have no identifier and are
+// read as the first version.
+func Check(got, want string) error {
+	if got == "" && strings.HasSuffix(want, ".v1") {
+		return nil
+	}
+	if got != want {
+		return fmt.Errorf("unsupported schema %q (expected %q); it was probably written by a different version of orgsync", got, want)
+	}
+	return nil
+}
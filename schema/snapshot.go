@@ -0,0 +1,62 @@
+package schema
+
+import "time"
+
+// StatusSyncing marks a repository whose clone or fetch is transferring data
+const StatusSyncing = "syncing"
+
+// RunSnapshot is the complete, serializable state of a run at one point in time. It is
+// the single data model for every view of a run from outside the TUI, such as the
+// status file read by `orgsync status`. The embedded Status keeps the snapshot readable
+// by anything that only understands the summary.
+type RunSnapshot struct {
+	Status
+	// Schema is SnapshotSchema
+	Schema    string    `json:"schema"`
+	Owner     string    `json:"owner,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Stopped is set once fail-fast cancelled the remaining work
+	Stopped      bool                 `json:"stopped,omitempty"`
+	Bytes        int64                `json:"bytes"`
+	Repositories []RepositorySnapshot `json:"repositories"`
+}
+
+// RepositorySnapshot is the state of one repository within a RunSnapshot
+type RepositorySnapshot struct {
+	Owner string `json:"owner,omitempty"`
+	Name  string `json:"name"`
+	// State is one of the report statuses, or StatusSyncing while data is transferred
+	State            string    `json:"state"`
+	Progress         float64   `json:"progress,omitempty"`
+	TransferSpeed    string    `json:"transfer_speed,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	DurationSeconds  float64   `json:"duration_seconds,omitempty"`
+	QueueWaitSeconds float64   `json:"queue_wait_seconds,omitempty"`
+	Attempts         int       `json:"attempts,omitempty"`
+	Bytes            int64     `json:"bytes,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	ErrorCategory    string    `json:"error_category,omitempty"`
+	CI               string    `json:"ci,omitempty"`
+}
+
+// Status is a point-in-time summary of a run, written for external monitors such as tmux
+type Status struct {
+	RunID     string    `json:"run_id"`
+	Target    string    `json:"target"`
+	Total     int       `json:"total"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// CIFailing counts repositories whose latest default-branch workflow run failed
+	CIFailing int `json:"ci_failing,omitempty"`
+}
+
+// Percent returns the share of completed repositories in the range 0-100
+func (s Status) Percent() int {
+	if s.Total == 0 {
+		return 0
+	}
+	return s.Completed * 100 / s.Total
+}
@@ -0,0 +1,28 @@
+package schema
+
+import "time"
+
+// Target identifies the kind of GitHub account being synchronized
+type Target int
+
+const (
+	TargetOrg Target = iota
+	TargetUser
+	TargetGists
+)
+
+// RunState is the persisted progress of a run
+type RunState struct {
+	// Schema is StateSchema
+	Schema string `json:"schema"`
+	RunID  string `json:"run_id"`
+	Owner  string `json:"owner"`
+	Target Target `json:"target"`
+	// Completed lists the repositories synchronized successfully as owner/name,
+	// including those carried over from the run this one resumed. StateSchemaV1 lists
+	// them by name only.
+	Completed []string `json:"completed"`
+	// Finished is set once every repository was processed without being cancelled
+	Finished  bool      `json:"finished"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveDir is the folder of the sync root that --move-archived moves clones into
+const ArchiveDir = "archive"
+
+// ErrArchived marks an archived repository that was not fetched because its clone was
+// moved into ArchiveDir, or because it was never cloned
+var ErrArchived = errors.New("archived")
+
+// moveToArchive moves the clone in dir to the same path under ArchiveDir and returns
+// the new path. A clone archived earlier under that path is kept by adding a timestamp.
+func moveToArchive(dir string) (string, error) {
+	target := filepath.Join(ArchiveDir, dir)
+	if _, err := os.Stat(target); err == nil {
+		target = fmt.Sprintf("%s.%s", target, time.Now().Format("20060102-150405"))
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	if err := os.Rename(dir, target); err != nil {
+		return "", fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	return target, nil
+}
+
+// archiveRepository moves the clone of the archived repo into ArchiveDir and returns
+// ErrArchived saying where it went. Pinned clones are left to be fetched as usual, and
+// nil is returned for them.
+func archiveRepository(opts Options, repo Repository) error {
+	dir := opts.repoDir(repo)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("%w: not cloned", ErrArchived)
+	}
+	if isPinned(dir, opts.Keep) {
+		return nil
+	}
+	target, err := moveToArchive(dir)
+	if err != nil {
+		return err
+	}
+	logger.Info("archived clone", repoAttr(repo), "path", target)
+	return fmt.Errorf("%w: moved to %s", ErrArchived, target)
+}
+
+// inArchive reports whether path is inside ArchiveDir
+func inArchive(path string) bool {
+	first, _, _ := strings.Cut(filepath.ToSlash(filepath.Clean(path)), "/")
+	return first == ArchiveDir
+}
+
+// ArchivedNames lists the repositories of the report that are archived upstream
+func ArchivedNames(r Report) []string {
+	var names []string
+	for _, repo := range r.Repositories {
+		if repo.Archived {
+			names = append(names, repo.Name)
+		}
+	}
+	return names
+}
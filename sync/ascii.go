@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"os"
+	"strings"
+)
+
+// glyphSet holds the characters the text output draws with that are not plain
+// letters, so that terminals which cannot show Unicode get ASCII ones instead
+type glyphSet struct {
+	arrow string
+	// ok and failed mark the outcome of a doctor check
+	ok     string
+	failed string
+}
+
+var (
+	unicodeGlyphs = glyphSet{
+		arrow:  "→",
+		ok:     "✓",
+		failed: "✗",
+	}
+	asciiGlyphs = glyphSet{
+		arrow:  "->",
+		ok:     "[ok]",
+		failed: "[!!]",
+	}
+)
+
+// glyphs are the characters of the text output, ASCII ones when the environment looks
+// like it cannot show Unicode
+var glyphs = glyphsFor(DetectASCII())
+
+// glyphsFor returns the ASCII glyphs or the Unicode ones
+func glyphsFor(ascii bool) glyphSet {
+	if ascii {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// DetectASCII reports whether the terminal is unlikely to show Unicode: TERM names a
+// dumb or VT-series terminal, or the locale (LC_ALL, LC_CTYPE or LANG, whichever is
+// set first) is not UTF-8
+func DetectASCII() bool {
+	if term := os.Getenv("TERM"); term == "dumb" || strings.HasPrefix(term, "vt") {
+		return true
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(os.Getenv(name)); locale != "" {
+			return !strings.Contains(locale, "utf-8") && !strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
+
+// ApplyASCII selects ASCII-only text output, or Unicode, overriding DetectASCII
+func ApplyASCII(ascii bool) {
+	glyphs = glyphsFor(ascii)
+}
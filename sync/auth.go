@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Auth picks the GitHub account a profile syncs with, for machines logged in to several.
+// Every field is optional; an empty Auth keeps the account gh has active.
+type Auth struct {
+	// Host is the GitHub host, as if given in GH_HOST
+	Host string `json:"host,omitempty"`
+	// TokenEnv names the environment variable holding the token to use, e.g.
+	// WORK_GITHUB_TOKEN. The token itself never goes into the config file.
+	TokenEnv string `json:"token_env,omitempty"`
+	// GHConfigDir is a gh configuration directory with its own login, set up with
+	// `GH_CONFIG_DIR=<dir> gh auth login`. A leading ~ is the home directory.
+	GHConfigDir string `json:"gh_config_dir,omitempty"`
+}
+
+// Empty reports whether the account gh has active is kept
+func (a Auth) Empty() bool {
+	return a.Host == "" && a.TokenEnv == "" && a.GHConfigDir == ""
+}
+
+// Apply points gh, git and orgsync at the account by setting the environment of the
+// process, before any command runs. git is given gh's credential helper for the host,
+// so that fetches of existing clones authenticate as the account too rather than with
+// whatever credentials git has stored.
+func (a Auth) Apply() error {
+	if a.Empty() {
+		return nil
+	}
+	if a.Host != "" {
+		os.Setenv("GH_HOST", a.Host)
+	}
+	host := apiHost()
+
+	if a.TokenEnv != "" {
+		token := os.Getenv(a.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("the profile's token_env %s is not set", a.TokenEnv)
+		}
+		// gh reads its token from a variable that depends on the host
+		variable := "GH_TOKEN"
+		if host != "github.com" {
+			variable = "GH_ENTERPRISE_TOKEN"
+		}
+		os.Setenv(variable, token)
+	}
+	if a.GHConfigDir != "" {
+		dir := a.GHConfigDir
+		if rest, ok := strings.CutPrefix(dir, "~"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to expand %s: %w", dir, err)
+			}
+			dir = filepath.Join(home, rest)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("the profile's gh_config_dir %s is not a directory", dir)
+		}
+		os.Setenv("GH_CONFIG_DIR", dir)
+	}
+
+	// Like `gh auth setup-git`, but only for this process
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	key := "credential.https://" + host + ".helper"
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", n), key)
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", n), "")
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", n+1), key)
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", n+1), "!gh auth git-credential")
+	os.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(n+2))
+	return nil
+}
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// pinnedBranch returns the branch or tag the config file pins repo to, looked up by
+// owner/name and then by name, or "" when it is not pinned
+func (o Options) pinnedBranch(repo Repository) string {
+	if branch := o.Branches[repo.Owner+"/"+repo.Name]; branch != "" {
+		return branch
+	}
+	return o.Branches[repo.Name]
+}
+
+// singleBranch reports whether the clone of repo tracks a single branch or tag rather
+// than every branch: with SingleBranch, or when the repository is pinned. Mirror clones
+// always keep every ref.
+func (o Options) singleBranch(repo Repository) bool {
+	return !o.Mirror && (o.SingleBranch || o.pinnedBranch(repo) != "")
+}
+
+// trackedRef returns the ref on origin that a single-branch clone of repo follows: the
+// pinned branch or tag, else the default branch, as told by origin when discovery did
+// not report it
+func trackedRef(ctx context.Context, opts Options, repoDir string, repo Repository, progress *progressWriter) (ref, sha string, err error) {
+	name := opts.pinnedBranch(repo)
+	if name == "" {
+		name = repo.DefaultBranch
+	}
+	// Without a name, origin tells which branch its HEAD points to
+	byHead := name == ""
+	args := []string{"-C", repoDir, "ls-remote", "origin", "refs/heads/" + name, "refs/tags/" + name}
+	if byHead {
+		args = []string{"-C", repoDir, "ls-remote", "--symref", "origin", "HEAD"}
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := runCommand(cmd, progress); err != nil {
+		return "", "", fmt.Errorf("failed to list the refs of origin: %w", newCommandError(err, progress))
+	}
+
+	refs := make(map[string]string)
+	for _, line := range splitLines(out.String()) {
+		sha, ref, _ := strings.Cut(line, "\t")
+		if head, ok := strings.CutPrefix(sha, "ref: "); ok && ref == "HEAD" {
+			name = strings.TrimPrefix(head, "refs/heads/")
+			continue
+		}
+		refs[ref] = sha
+	}
+	if byHead {
+		if sha, ok := refs["HEAD"]; ok && name != "" {
+			return "refs/heads/" + name, sha, nil
+		}
+		return "", "", fmt.Errorf("failed to find the default branch of %s", repo.Name)
+	}
+	// A branch wins over a tag of the same name, as it does for git clone --branch
+	for _, ref := range []string{"refs/heads/" + name, "refs/tags/" + name} {
+		if sha, ok := refs[ref]; ok {
+			return ref, sha, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s has no branch or tag %s", repo.Name, name)
+}
+
+// isTag reports whether name is a tag of the clone at repoDir rather than a branch of
+// its origin, which wins when both exist
+func isTag(ctx context.Context, repoDir, name string) bool {
+	if _, err := gitOutput(ctx, repoDir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+name); err == nil {
+		return false
+	}
+	_, err := gitOutput(ctx, repoDir, "rev-parse", "--verify", "--quiet", "refs/tags/"+name)
+	return err == nil
+}
+
+// fetchTracked updates a single-branch clone with the one branch or tag it tracks,
+// skipping the fetch when the clone has it already. branches is where the clone keeps
+// the branches of origin, e.g. "refs/remotes/origin/" or "refs/heads/" for bare clones;
+// a tag is kept as a tag.
+func fetchTracked(ctx context.Context, opts Options, repoDir string, repo Repository, branches string, gitArgs []string, progress *progressWriter) error {
+	ref, sha, err := trackedRef(ctx, opts, repoDir, repo, progress)
+	if err != nil {
+		return err
+	}
+	local := ref
+	if name, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		local = branches + name
+	}
+	if current, _ := gitOutput(ctx, repoDir, "rev-parse", "--verify", "--quiet", local); current == sha {
+		progress.upToDate = true
+		return nil
+	}
+
+	args := append([]string{"-C", repoDir, "fetch"}, gitArgs...)
+	cmd := exec.CommandContext(ctx, "git", append(args, "origin", "+"+ref+":"+local)...)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to fetch %s of %s: %w", strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/"), repo.Name, newCommandError(err, progress))
+	}
+	progress.updated = true
+	return nil
+}
+
+// aheadBehind counts the commits HEAD has that the branch of origin the clone of repo
+// follows lacks, and the other way round, once the clone was fetched. upstream is that
+// branch, e.g. origin/main, and is empty when there is none to compare with, as in an
+// empty repository or a clone pinned to a tag.
+func aheadBehind(ctx context.Context, opts Options, repo Repository) (upstream string, ahead, behind int) {
+	dir := opts.repoDir(repo)
+	name := opts.pinnedBranch(repo)
+	if name == "" {
+		name = repo.DefaultBranch
+	}
+	// Without a name, origin/HEAD points to the default branch of a full clone
+	upstream = "origin/HEAD"
+	if name != "" {
+		upstream = "origin/" + name
+	}
+	if _, err := gitOutput(ctx, dir, "rev-parse", "--verify", "--quiet", upstream); err != nil {
+		return "", 0, 0
+	}
+	out, err := gitOutput(ctx, dir, "rev-list", "--left-right", "--count", "HEAD..."+upstream)
+	if err != nil {
+		return "", 0, 0
+	}
+	if _, err := fmt.Sscan(out, &ahead, &behind); err != nil {
+		return "", 0, 0
+	}
+	return upstream, ahead, behind
+}
+
+// Divergence describes how HEAD compares with the branch of origin it was counted
+// against, e.g. "2 ahead, 5 behind", or is empty when they match or were not compared
+func Divergence(repo Repository) string {
+	var parts []string
+	if repo.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%d ahead", repo.Ahead))
+	}
+	if repo.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("%d behind", repo.Behind))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// checkoutDefault switches a freshly fetched, clean clone to its default branch, or to
+// the branch or tag it is pinned to, and fast-forwards it to origin. A branch that has
+// diverged from origin is left as it is with a warning rather than failing the sync,
+// and a pinned tag is checked out as a detached HEAD.
+func checkoutDefault(ctx context.Context, repoDir string, repo Repository, pinned string, progress *progressWriter) error {
+	branch := pinned
+	if branch == "" {
+		branch = repo.DefaultBranch
+	}
+	if pinned != "" && isTag(ctx, repoDir, pinned) {
+		cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "checkout", "--quiet", "--detach", "refs/tags/"+pinned)
+		if err := runCommand(cmd, progress); err != nil {
+			return fmt.Errorf("failed to check out tag %s of %s: %w", pinned, repo.Name, newCommandError(err, progress))
+		}
+		return nil
+	}
+	if branch == "" {
+		head, err := gitOutput(ctx, repoDir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to find the default branch of %s: %w", repo.Name, err)
+		}
+		branch = strings.TrimPrefix(head, "origin/")
+	}
+
+	// git creates a branch tracking origin/<branch> if there is none locally yet. It
+	// only guesses so for branches the fetch refspec covers, which a pinned branch of a
+	// single-branch clone is not, so such a branch is created from origin/<branch>.
+	args := []string{"-C", repoDir, "checkout", "--quiet", branch}
+	if _, err := gitOutput(ctx, repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil && pinned != "" {
+		args = []string{"-C", repoDir, "checkout", "--quiet", "-b", branch, "origin/" + branch}
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to check out %s of %s: %w", branch, repo.Name, newCommandError(err, progress))
+	}
+	// A failed fast-forward is only a warning, so keep its output out of the attempt
+	cmd = exec.CommandContext(ctx, "git", "-C", repoDir, "merge", "--ff-only", "--quiet", "origin/"+branch)
+	merge := &progressWriter{protectWorktree: progress.protectWorktree, transcript: progress.transcript}
+	err := runCommand(cmd, merge)
+	progress.commands = append(progress.commands, merge.commands...)
+	if err != nil {
+		progress.warnings = append(progress.warnings, fmt.Sprintf("%s has diverged from origin/%s and was not fast-forwarded", branch, branch))
+	}
+	return nil
+}
+
+// gitOutput runs a git command in dir and returns its trimmed standard output
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	started := time.Now()
+	err := cmd.Run()
+	// Queries are frequent and expected to fail at times, so they are only debug records
+	logCommand(slog.LevelDebug, cmd, started, err, "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
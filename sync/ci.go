@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// CI outcomes recorded for a repository's default branch. Any other conclusion GitHub
+// reports, such as "cancelled" or "timed_out", is recorded as is.
+const (
+	CISuccess = "success"
+	CIFailure = "failure"
+	// CIPending marks a workflow run that has not finished yet
+	CIPending = "pending"
+	// CINone marks a repository without workflow runs on its default branch
+	CINone = "none"
+)
+
+// ciWorkers bounds the number of concurrent workflow run lookups during discovery
+const ciWorkers = 8
+
+// fetchCIStatuses records the latest workflow run outcome of every repository in place.
+// Lookups that fail leave CI empty, meaning unknown; they never fail discovery.
+func fetchCIStatuses(repos []Repository) {
+	slots := make(chan struct{}, ciWorkers)
+	done := make(chan struct{})
+	for i := range repos {
+		go func(repo *Repository) {
+			slots <- struct{}{}
+			defer func() { <-slots; done <- struct{}{} }()
+			if status, err := fetchCIStatus(*repo); err == nil {
+				repo.CI = status
+			}
+		}(&repos[i])
+	}
+	for range repos {
+		<-done
+	}
+}
+
+// fetchCIStatus looks up the latest workflow run on the default branch of repo
+func fetchCIStatus(repo Repository) (string, error) {
+	if repo.Gist || repo.DefaultBranch == "" {
+		return "", nil
+	}
+	endpoint := fmt.Sprintf("repos/%s/%s/actions/runs?branch=%s&per_page=1", repo.Owner, repo.Name, url.QueryEscape(repo.DefaultBranch))
+	data, err := apiGet(endpoint, false, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch workflow runs of %s: %w", repo.Name, err)
+	}
+	var answer struct {
+		WorkflowRuns []struct {
+			Status     string
+			Conclusion string
+		} `json:"workflow_runs"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", fmt.Errorf("failed to parse workflow runs of %s: %w", repo.Name, err)
+	}
+
+	var status, conclusion string
+	if len(answer.WorkflowRuns) > 0 {
+		status, conclusion = answer.WorkflowRuns[0].Status, answer.WorkflowRuns[0].Conclusion
+	}
+	switch {
+	case status == "":
+		return CINone, nil
+	case status != "completed":
+		return CIPending, nil
+	case conclusion == "":
+		return CINone, nil
+	default:
+		return conclusion, nil
+	}
+}
@@ -0,0 +1,211 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Kinds of leftovers found by FindLeftovers
+const (
+	// LeftoverStaging is a staging directory holding clones cut short by a killed run
+	LeftoverStaging = "staging"
+	// LeftoverTemp is a temporary file a killed run did not get to remove
+	LeftoverTemp = "temp"
+	// LeftoverLogs is the directory of command logs saved with SaveLogs
+	LeftoverLogs = "logs"
+	// LeftoverBroken is a clone with no valid HEAD and no refs, as interrupted clones
+	// from before staging, or from other tools, are left
+	LeftoverBroken = "broken"
+	// LeftoverForeign is a clone of a repository that belongs to another owner or host
+	LeftoverForeign = "foreign"
+	// LeftoverEmpty is an empty directory
+	LeftoverEmpty = "empty"
+)
+
+// Leftover is something in the sync root that is not a healthy clone of the target
+type Leftover struct {
+	Path string
+	Kind string
+	// Origin is the origin remote of a broken or foreign clone, if it has one. A broken
+	// clone can be cloned again from it.
+	Origin string
+}
+
+// String describes the leftover for listing it
+func (l Leftover) String() string {
+	switch l.Kind {
+	case LeftoverStaging:
+		return l.Path + " (partial clones of an interrupted run)"
+	case LeftoverTemp:
+		return l.Path + " (temporary file)"
+	case LeftoverLogs:
+		return l.Path + " (saved command logs)"
+	case LeftoverBroken:
+		return l.Path + " (broken clone without a valid HEAD)"
+	case LeftoverForeign:
+		return fmt.Sprintf("%s (clone of %s)", l.Path, l.Origin)
+	case LeftoverEmpty:
+		return l.Path + " (empty directory)"
+	default:
+		return l.Path
+	}
+}
+
+// FindLeftovers returns what is in the sync root dir besides healthy clones: staging
+// directories and temporary files of killed runs, broken clones and empty directories.
+// When opts name an owner, clones of other owners' repositories are included too,
+// unless opts sync gists or collaborations, which span owners. Pinned directories are
+// left out, and clones are not searched inside. With logs, the saved command logs
+// under .orgsync/logs are included as well.
+func FindLeftovers(dir string, opts Options, logs bool) ([]Leftover, error) {
+	var leftovers []Leftover
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		switch {
+		case !d.IsDir():
+			if strings.HasPrefix(name, ".orgsync-write-test-") {
+				leftovers = append(leftovers, Leftover{Path: path, Kind: LeftoverTemp})
+			}
+			return nil
+		case path == dir:
+			return nil
+		case name == stagingDir:
+			// Runs keep the staging directory, but empty it when they finish
+			if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+				leftovers = append(leftovers, Leftover{Path: path, Kind: LeftoverStaging})
+			}
+			return filepath.SkipDir
+		case strings.HasPrefix(name, ".") || isPinned(path, opts.Keep):
+			return filepath.SkipDir
+		case isRepository(path) || isPartialBare(path):
+			if l, ok := inspectClone(path, opts); ok {
+				leftovers = append(leftovers, l)
+			}
+			return filepath.SkipDir
+		}
+		if entries, err := os.ReadDir(path); err == nil && len(entries) == 0 {
+			leftovers = append(leftovers, Leftover{Path: path, Kind: LeftoverEmpty})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for leftovers: %w", err)
+	}
+
+	if logs {
+		path := filepath.Join(dir, logsDir)
+		if _, err := os.Stat(path); err == nil {
+			leftovers = append(leftovers, Leftover{Path: path, Kind: LeftoverLogs})
+		}
+	}
+	return leftovers, nil
+}
+
+// isPartialBare reports whether dir looks like a bare clone that was cut short
+// before git wrote its HEAD
+func isPartialBare(dir string) bool {
+	for _, name := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// inspectClone reports the clone in dir as a leftover if it is broken or belongs to
+// someone other than the owner in opts
+func inspectClone(dir string, opts Options) (Leftover, bool) {
+	// The git directory is named explicitly, so that git does not search the parent
+	// directories for a repository when this one is too broken to be recognized
+	gitDir := "."
+	if !isBareRepository(dir) {
+		gitDir = ".git"
+	}
+	ctx := context.Background()
+	origin, _ := gitOutput(ctx, dir, "config", "--file", filepath.Join(gitDir, "config"), "--get", "remote.origin.url")
+
+	if _, err := gitOutput(ctx, dir, "--git-dir="+gitDir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// A clone of an empty repository has no HEAD either, but it has no refs to lose
+		if refs, err := gitOutput(ctx, dir, "--git-dir="+gitDir, "for-each-ref", "--count=1"); err != nil || refs == "" {
+			return Leftover{Path: dir, Kind: LeftoverBroken, Origin: origin}, true
+		}
+	}
+
+	if opts.Owner == "" || opts.Target == TargetGists || opts.Collaborations || origin == "" {
+		return Leftover{}, false
+	}
+	host, path := parseRemote(origin)
+	if strings.HasPrefix(host, "gist.") {
+		return Leftover{}, false
+	}
+	owner, _, _ := strings.Cut(path, "/")
+	if host != strings.ToLower(opts.Host) || !slices.ContainsFunc(opts.AllOwners(), func(o string) bool { return strings.EqualFold(o, owner) }) {
+		return Leftover{Path: dir, Kind: LeftoverForeign, Origin: origin}, true
+	}
+	return Leftover{}, false
+}
+
+// HoldsWork reports whether the leftover is a clone with local changes, or commits or
+// branches that are on no remote, which deleting or cloning it again would lose
+func (l Leftover) HoldsWork(ctx context.Context) bool {
+	if l.Kind != LeftoverBroken && l.Kind != LeftoverForeign {
+		return false
+	}
+	return holdsWork(ctx, l.Path)
+}
+
+// Remove deletes the leftover
+func (l Leftover) Remove() error {
+	if err := os.RemoveAll(l.Path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// Reclone replaces a broken clone with a fresh clone from its origin, keeping it bare
+// or mirrored if it was. The new clone is staged, so the broken one is only gone once
+// the clone succeeded.
+func (l Leftover) Reclone(ctx context.Context) error {
+	if l.Kind != LeftoverBroken || l.Origin == "" {
+		return fmt.Errorf("%s has no origin to clone again from", l.Path)
+	}
+	args := []string{"clone", "--progress"}
+	if isBareRepository(l.Path) {
+		mirror, _ := gitOutput(ctx, l.Path, "config", "--file", "config", "--get", "remote.origin.mirror")
+		if mirror == "true" {
+			args = append(args, "--mirror")
+		} else {
+			args = append(args, "--bare")
+		}
+	}
+
+	// Stage the clone next to the broken one, then swap them
+	fresh := l.Path + ".reclone"
+	err := cloneStaged(fresh, func(tmp string) error {
+		progress := &progressWriter{}
+		cmd := exec.CommandContext(ctx, "git", append(args, "--", l.Origin, tmp)...)
+		if err := runCommand(cmd, progress); err != nil {
+			return fmt.Errorf("failed to clone %s: %w", l.Origin, newCommandError(err, progress))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := l.Remove(); err != nil {
+		return err
+	}
+	if err := os.Rename(fresh, l.Path); err != nil {
+		return fmt.Errorf("failed to move clone into place: %w", err)
+	}
+	return nil
+}
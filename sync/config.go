@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is the optional orgsync configuration file
+type Config struct {
+	Policy Policy `json:"policy"`
+	// Keep lists name patterns of local repositories that --prune must never touch
+	Keep []string `json:"keep,omitempty"`
+	// Theme names the color palette: dark, light, solarized or custom
+	Theme string `json:"theme,omitempty"`
+	// Colors are the palette of the custom theme
+	Colors Theme `json:"colors,omitempty"`
+	// ASCII draws the terminal UI with ASCII characters only when true, and with Unicode
+	// when false; unset, it is detected from TERM and the locale
+	ASCII *bool `json:"ascii,omitempty"`
+	// Keys replaces the keys of terminal UI bindings, by binding name, e.g.
+	// {"skip": ["delete", "x"]}
+	Keys map[string][]string `json:"keys,omitempty"`
+	// Profiles are named repository selections and accounts, chosen with --profile
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// Retry overrides how failures are retried, keyed by error category
+	Retry RetryPolicy `json:"retry,omitempty"`
+	// Fetch turns on git fetch options for every run, as if given on the command line
+	Fetch FetchConfig `json:"fetch,omitempty"`
+	// Branches pins repositories, by name or owner/name, to the branch or tag their
+	// clones track instead of every branch, e.g. {"api": "release-2.x"}
+	Branches map[string]string `json:"branches,omitempty"`
+	// Hooks are shell commands run before and after each repository and after the run
+	Hooks Hooks `json:"hooks,omitempty"`
+	// Notify posts a summary of finished runs to a chat webhook
+	Notify Notify `json:"notify,omitempty"`
+}
+
+// FetchConfig holds the git fetch options that can be enabled in the config file
+type FetchConfig struct {
+	// Prune passes --prune, like --fetch-prune
+	Prune bool `json:"prune,omitempty"`
+	// Tags passes --tags, like --fetch-tags
+	Tags bool `json:"tags,omitempty"`
+	// Maintenance runs git's housekeeping after fetching, like --maintenance
+	Maintenance bool `json:"maintenance,omitempty"`
+	// SingleBranch has clones track only the default branch, like --single-branch
+	SingleBranch bool `json:"single_branch,omitempty"`
+}
+
+// DefaultConfigPath returns the per-user config location, e.g. ~/.config/orgsync/config.json
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "orgsync", "config.json")
+}
+
+// LoadConfig reads the config file at path. A missing file yields an empty config
+// unless required is set, which is used when the path was given explicitly.
+func LoadConfig(path string, required bool) (Config, error) {
+	var config Config
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if err := config.Retry.Validate(); err != nil {
+		return config, fmt.Errorf("invalid retry policy in %s: %w", path, err)
+	}
+	if err := config.Notify.Validate(); err != nil {
+		return config, fmt.Errorf("invalid notify section in %s: %w", path, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+func init() {
+	RegisterFormatter("csv", FormatterFunc(writeCSV))
+}
+
+// csvHeader names the columns written by writeCSV
+var csvHeader = []string{"owner", "name", "status", "started_at", "duration_seconds", "queue_wait_seconds", "attempts", "bytes", "size", "warnings", "ci", "ahead", "behind", "error_category", "error"}
+
+// writeCSV writes one row per repository, for spreadsheets and quick analysis
+func writeCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	for _, repo := range r.Repositories {
+		startedAt := ""
+		if !repo.StartedAt.IsZero() {
+			startedAt = repo.StartedAt.Format(time.RFC3339)
+		}
+		// Ahead and behind are left empty for clones that were not compared with origin
+		var ahead, behind string
+		if repo.Ahead != nil && repo.Behind != nil {
+			ahead, behind = strconv.Itoa(*repo.Ahead), strconv.Itoa(*repo.Behind)
+		}
+		row := []string{
+			repo.Owner,
+			repo.Name,
+			repo.Status,
+			startedAt,
+			strconv.FormatFloat(repo.DurationSeconds, 'f', 3, 64),
+			strconv.FormatFloat(repo.QueueWaitSeconds, 'f', 3, 64),
+			strconv.Itoa(repo.Attempts),
+			strconv.FormatInt(repo.Bytes, 10),
+			strconv.FormatInt(repo.Size, 10),
+			strconv.Itoa(len(repo.Warnings)),
+			repo.CI,
+			ahead,
+			behind,
+			repo.ErrorCategory,
+			repo.Error,
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
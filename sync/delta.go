@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// deltaNames is how many repositories the completion screen names per kind of change
+const deltaNames = 5
+
+// Baseline is what earlier runs of the same target in the same sync root synchronized,
+// as recorded in the history, for the completion screen to tell what changed since
+type Baseline struct {
+	// RunID and StartedAt identify the last run
+	RunID     string
+	StartedAt time.Time
+	// Known holds every repository any earlier run had, by deltaKey
+	Known map[string]bool
+	// Previous holds the repositories of the last run, by deltaKey
+	Previous map[string]HistoryRepository
+}
+
+// Delta is what changed since the run of a Baseline. Each list holds repository names,
+// with their owner when the run synchronized several organizations.
+type Delta struct {
+	// Appeared are repositories no earlier run had
+	Appeared []string
+	// Updated are existing clones that fetched new commits or refs
+	Updated []string
+	// Failed are repositories that failed now and succeeded in the last run
+	Failed []string
+	// Removed are repositories of the last run that are gone upstream
+	Removed []string
+}
+
+// deltaKey identifies a repository across runs
+func deltaKey(owner, name string) string {
+	return strings.ToLower(owner + "/" + name)
+}
+
+// NewBaseline collects what the runs of the history in the sync root root synchronized
+// for the target of opts, or returns nil when none of them did. Runs that discovered
+// nothing, e.g. because listing failed, are passed over.
+func NewBaseline(runs []HistoryRun, root string, opts Options) *Baseline {
+	var b *Baseline
+	target := opts.label()
+	for _, run := range runs {
+		if run.Root != root || run.Target != target || len(run.Repositories) == 0 {
+			continue
+		}
+		if b == nil {
+			b = &Baseline{Known: make(map[string]bool)}
+		}
+		b.record(run.RunID, run.StartedAt, run.Repositories)
+	}
+	return b
+}
+
+// record makes the run the last one of the baseline
+func (b *Baseline) record(runID string, startedAt time.Time, repos []HistoryRepository) {
+	b.RunID = runID
+	b.StartedAt = startedAt
+	b.Previous = make(map[string]HistoryRepository, len(repos))
+	for _, repo := range repos {
+		key := deltaKey(repo.Owner, repo.Name)
+		b.Known[key] = true
+		b.Previous[key] = repo
+	}
+}
+
+// Next returns the baseline for the run after the one reported, which watch mode compares
+// against the run before it rather than against the history it started with
+func (b *Baseline) Next(r Report) *Baseline {
+	if len(r.Repositories) == 0 {
+		return b
+	}
+	next := &Baseline{Known: make(map[string]bool)}
+	if b != nil {
+		for key := range b.Known {
+			next.Known[key] = true
+		}
+	}
+	next.record(r.RunID, r.StartedAt, historyRun(r, "").Repositories)
+	return next
+}
+
+// removed lists the repositories of the last run that are not among those discovered
+// upstream. Repositories of owners the run does not list, e.g. enqueued from elsewhere,
+// are not looked for.
+func (b *Baseline) removed(opts Options, upstream []Repository) []string {
+	if b == nil {
+		return nil
+	}
+	found := make(map[string]bool, len(upstream))
+	for _, repo := range upstream {
+		found[deltaKey(repo.Owner, repo.Name)] = true
+	}
+	owners := opts.AllOwners()
+	var names []string
+	for key, repo := range b.Previous {
+		if found[key] {
+			continue
+		}
+		if repo.Owner != "" && !slices.ContainsFunc(owners, func(o string) bool { return strings.EqualFold(o, repo.Owner) }) {
+			continue
+		}
+		names = append(names, deltaName(opts, repo.Owner, repo.Name))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// deltaName names a repository in a delta, with its owner when the run synchronizes
+// several organizations
+func deltaName(opts Options, owner, name string) string {
+	if len(opts.Owners) > 1 && owner != "" {
+		return owner + "/" + name
+	}
+	return name
+}
+
+// Delta compares the run so far with the baseline it started from, or returns false
+// without one
+func (s State) Delta() (Delta, bool) {
+	b := s.Options.Baseline
+	if b == nil {
+		return Delta{}, false
+	}
+	d := Delta{Removed: s.Removed}
+	for _, repo := range s.Report().Repositories {
+		key := deltaKey(repo.Owner, repo.Name)
+		name := deltaName(s.Options, repo.Owner, repo.Name)
+		previous, seen := b.Previous[key]
+		switch {
+		case !b.Known[key]:
+			d.Appeared = append(d.Appeared, name)
+		case repo.Updated:
+			d.Updated = append(d.Updated, name)
+		}
+		failed := repo.Status == StatusFailed || repo.Status == StatusConflict
+		if failed && seen && previous.Status == StatusSuccess {
+			d.Failed = append(d.Failed, name)
+		}
+	}
+	return d, true
+}
+
+// DeltaList renders the count and the first names of one kind of change
+func DeltaList(what string, names []string) string {
+	shown := names
+	if len(shown) > deltaNames {
+		shown = shown[:deltaNames]
+	}
+	list := strings.Join(shown, ", ")
+	if more := len(names) - len(shown); more > 0 {
+		list += fmt.Sprintf(" and %d more", more)
+	}
+	return fmt.Sprintf("%d %s: %s", len(names), what, list)
+}
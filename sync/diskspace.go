@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInsufficientSpace stops a run whose new clones would not fit on the file system
+var ErrInsufficientSpace = errors.New("not enough free disk space")
+
+// workingTreeFactor estimates a clone with a working tree at twice the repository size
+// GitHub reports, which only covers the packed history
+const workingTreeFactor = 2
+
+// estimateSpace returns the approximate number of bytes the clones that repos still
+// need would take, and how many clones that is. Fetches into existing clones are
+// assumed to be small, and repositories over MaxSize are not cloned at all.
+func (o Options) estimateSpace(repos []Repository) (need int64, clones int) {
+	for _, repo := range repos {
+		if checkSize(repo, o.MaxSize) != nil {
+			continue
+		}
+		if _, err := os.Stat(o.repoDir(repo)); err == nil {
+			continue
+		}
+		size := repo.Size
+		if !o.Mirror && !o.Bare {
+			size *= workingTreeFactor
+		}
+		need += size
+		clones++
+	}
+	return need, clones
+}
+
+// checkDiskSpace returns an ErrInsufficientSpace error when the new clones for repos
+// would not fit in the free space of the sync root. File systems whose free space
+// cannot be determined pass.
+func checkDiskSpace(opts Options, repos []Repository) error {
+	need, clones := opts.estimateSpace(repos)
+	if clones == 0 {
+		return nil
+	}
+	free, err := freeSpace(".")
+	if err != nil || free >= need {
+		return nil
+	}
+	return fmt.Errorf("%w: about %s needed for %d new clones, %s free", ErrInsufficientSpace, FormatBytes(need), clones, FormatBytes(free))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sync
+
+import "errors"
+
+// freeSpace is not implemented on this platform, so the disk space check is skipped
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package sync
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// freeSpace returns the bytes available to unprivileged users on the file system of dir
+func freeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to read free space of %s: %w", dir, err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Check is the outcome of one of the checks run by Diagnose
+type Check struct {
+	Name string
+	// Detail describes what was found, e.g. the installed version
+	Detail string
+	Err    error
+}
+
+// Mark is the sign for the outcome of the check, e.g. "✓" when it passed
+func (c Check) Mark() string {
+	if c.Err != nil {
+		return glyphs.failed
+	}
+	return glyphs.ok
+}
+
+// Diagnose checks that everything a sync of host into dir needs is in place: git and
+// gh are installed, gh is logged in with API quota left, and dir is writable and not
+// in use by a running orgsync. Without gh, a token in the environment will do instead.
+// The login and quota are only checked when gh or a token is there; every other check
+// runs whether or not the others pass.
+func Diagnose(dir, host string) []Check {
+	gh := checkVersion("gh", "gh", "--version")
+	checks := []Check{checkVersion("git", "git", "--version")}
+	switch {
+	case gh.Err == nil:
+		checks = append(checks, gh, checkAuth(host), checkRateLimit())
+	case withoutGH():
+		_, variable := apiToken(host)
+		checks = append(checks, Check{Name: "gh", Detail: "not installed; using the token in " + variable}, checkRateLimit())
+	default:
+		checks = append(checks, gh)
+	}
+	return append(checks, checkSyncRoot(dir), checkLock(dir))
+}
+
+// checkVersion runs a tool to print its version, which shows that it is installed
+func checkVersion(name string, args ...string) Check {
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return Check{Name: name, Err: fmt.Errorf("%s is not installed or not on PATH", args[0])}
+		}
+		return Check{Name: name, Err: fmt.Errorf("failed to run %s: %w", args[0], err)}
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return Check{Name: name, Detail: version}
+}
+
+// checkAuth verifies that gh has a working login for host
+func checkAuth(host string) Check {
+	check := Check{Name: "gh auth"}
+	out, err := exec.Command("gh", "auth", "status", "--hostname", host).CombinedOutput()
+	if err != nil {
+		output := strings.TrimSpace(string(out))
+		if output == "" {
+			output = err.Error()
+		}
+		check.Err = fmt.Errorf("not logged in to %s: %s (run `gh auth login --hostname %s`)", host, output, host)
+		return check
+	}
+	check.Detail = "logged in to " + host
+	return check
+}
+
+// checkRateLimit reports the API quota left, failing when it is exhausted
+func checkRateLimit() Check {
+	check := Check{Name: "API quota"}
+	limit, err := fetchRateLimit()
+	switch {
+	case err != nil:
+		check.Err = err
+	case limit.Limit > 0 && limit.Remaining == 0:
+		check.Err = fmt.Errorf("exhausted until %s", limit.Reset.Format(time.TimeOnly))
+	default:
+		check.Detail = fmt.Sprintf("%d of %d requests left", limit.Remaining, limit.Limit)
+	}
+	return check
+}
+
+// checkSyncRoot verifies that dir is writable and reports its free space
+func checkSyncRoot(dir string) Check {
+	check := Check{Name: "sync directory"}
+	if err := CheckWritable(dir); err != nil {
+		check.Err = err
+		return check
+	}
+	check.Detail = "writable"
+	if free, err := freeSpace(dir); err == nil {
+		check.Detail += fmt.Sprintf(", %s free", FormatBytes(free))
+	}
+	return check
+}
+
+// checkLock reports whether an orgsync is running in dir, which would keep a sync out
+func checkLock(dir string) Check {
+	check := Check{Name: "lock"}
+	path := filepath.Join(dir, LockFile)
+	holder, err := readLock(path)
+	host, _ := os.Hostname()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		check.Detail = "no orgsync running"
+	case err != nil || holder.stale(host):
+		check.Detail = "stale lock left by a crashed run; the next run takes it over"
+	default:
+		check.Err = fmt.Errorf("%w (pid %d on %s, started %s)", ErrLocked, holder.PID, holder.Host, holder.StartedAt.Format(time.DateTime))
+	}
+	return check
+}
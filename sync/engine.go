@@ -0,0 +1,726 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	gosync "sync"
+	"time"
+)
+
+// eventBuffer is how many events a run queues up for a consumer that falls behind
+const eventBuffer = 100
+
+// Event is something that happened during an Engine run: one of *DiscoveredEvent,
+// *DiscoveryProgressEvent, *RateLimitedEvent, *RepositoryStartedEvent, *RepositoryProgressEvent,
+// *RepositoryFinishedEvent, *PausedEvent, *ConfirmPruneEvent, *PrunedEvent or
+// *RunFinishedEvent
+type Event interface {
+	isEvent()
+}
+
+// DiscoveredEvent lists the repositories a run is about to synchronize. Err is set
+// when discovery failed, in which case the run finishes without syncing anything.
+type DiscoveredEvent struct {
+	RunID        string
+	Repositories []Repository
+	// Warnings are non-fatal notices gh printed while discovering repositories
+	Warnings  []string
+	RateLimit RateLimit
+	Err       error
+}
+
+// DiscoveryProgressEvent reports that discovery listed another page of repositories,
+// for organizations big enough to take a while
+type DiscoveryProgressEvent struct {
+	Pages        int
+	Repositories int
+}
+
+// RateLimitedEvent reports that discovery hit a rate limit and is tried again at Until
+type RateLimitedEvent struct {
+	Until     time.Time
+	RateLimit RateLimit
+}
+
+// RepositoryStartedEvent reports that a repository got a worker and starts syncing
+type RepositoryStartedEvent struct {
+	Repository Repository
+}
+
+// RepositoryProgressEvent reports the git transfer progress of a syncing repository.
+// Progress events are dropped rather than holding up git when the consumer falls behind.
+type RepositoryProgressEvent struct {
+	// Key is the repository as owner/name
+	Key string
+	// Progress is the fraction of objects received, across the repository and its submodules
+	Progress      float64
+	TransferSpeed string
+}
+
+// RepositoryFinishedEvent reports the outcome of a repository, with Done set and Err
+// telling whether and how it failed
+type RepositoryFinishedEvent struct {
+	Repository Repository
+}
+
+// PausedEvent reports that the run stopped starting repositories after Failures in a
+// row failed with network or authentication errors, the last of them in Category. It
+// waits for Engine.Resume, or for its context to be cancelled to abort it.
+type PausedEvent struct {
+	Failures int
+	Category string
+}
+
+// ConfirmPruneEvent reports that a run with Options.Confirm is about to prune Clones,
+// which no longer exist upstream. It waits for Engine.ConfirmPrune, or for its context
+// to be cancelled to keep them.
+type ConfirmPruneEvent struct {
+	Clones []string
+}
+
+// PrunedEvent reports the local clones pruned because they no longer exist upstream
+type PrunedEvent struct {
+	Pruned []string
+	// Kept are the pinned clones that were not pruned, Spared those holding work that is
+	// on no remote, and Declined those the user chose to keep when asked
+	Kept     []string
+	Spared   []string
+	Declined []string
+	Err      error
+}
+
+// RunFinishedEvent is the last event of a run, carrying its report
+type RunFinishedEvent struct {
+	Report Report
+}
+
+func (*DiscoveredEvent) isEvent()         {}
+func (*DiscoveryProgressEvent) isEvent()  {}
+func (*RateLimitedEvent) isEvent()        {}
+func (*RepositoryStartedEvent) isEvent()  {}
+func (*RepositoryProgressEvent) isEvent() {}
+func (*RepositoryFinishedEvent) isEvent() {}
+func (*PausedEvent) isEvent()             {}
+func (*ConfirmPruneEvent) isEvent()       {}
+func (*PrunedEvent) isEvent()             {}
+func (*RunFinishedEvent) isEvent()        {}
+
+// State is the state of a run at one point in time. The Engine owns the state of its
+// run and hands out copies of it, so a frontend never shares data with running syncs.
+type State struct {
+	// RunID is a ULID identifying this run in logs, status files and reports
+	RunID        string
+	Options      Options
+	StartedAt    time.Time
+	Repositories []Repository
+	// Done is set once everything the run does is over
+	Done   bool
+	Errors []error
+	// Stopped is set once fail-fast cancelled the remaining work
+	Stopped bool
+	// Warnings are non-fatal notices gh printed while discovering repositories
+	Warnings []string
+	// Pruned and Kept list local clones removed by --prune and those pinned against it,
+	// Spared those kept for holding work that is on no remote and Declined those the
+	// user chose to keep
+	Pruned   []string
+	Kept     []string
+	Spared   []string
+	Declined []string
+	// Confirming lists the clones the run waits for Engine.ConfirmPrune to prune
+	Confirming []string
+	// Transferred lists local clones of repositories transferred to another owner
+	Transferred []string
+	// Removed lists repositories of the last run recorded in Options.Baseline that
+	// discovery no longer found upstream
+	Removed []string
+	// RateLimit is the last known GitHub API quota
+	RateLimit RateLimit
+	// Received is the amount of data git reported transferring across the run so far,
+	// counting every attempt
+	Received int64
+	// ListedPages and Listed count the pages and repositories discovery has listed
+	ListedPages int
+	Listed      int
+	// RateLimitedUntil is set while discovery waits out a rate limit, and
+	// DiscoveryAttempts counts the attempts that hit one
+	RateLimitedUntil  time.Time
+	DiscoveryAttempts int
+	// Paused is set while the run holds back queued repositories after
+	// Options.PauseAfter consecutive failures, and PausedBy is the error category of
+	// the last of them
+	Paused   bool
+	PausedBy string
+	// Draining is set once the run stopped starting repositories to shut down
+	// gracefully, waiting for the running syncs only
+	Draining bool
+	// FinishedAt is when the run was done
+	FinishedAt time.Time
+}
+
+// clone copies the slices of the state that a run changes in place. Everything else
+// is replaced rather than modified, so it can be shared.
+func (s State) clone() State {
+	s.Repositories = slices.Clone(s.Repositories)
+	s.Errors = slices.Clone(s.Errors)
+	return s
+}
+
+var (
+	// ErrRunning is returned when an Engine is asked to start a run before its last one is done
+	ErrRunning = errors.New("a run is already in progress")
+	// ErrNotRunning is returned when repositories are enqueued while no run is syncing
+	ErrNotRunning = errors.New("no run is syncing repositories")
+	// ErrNotConfirming is returned for an answer to a question no run is asking
+	ErrNotConfirming = errors.New("no run is waiting for a confirmation")
+)
+
+// Engine synchronizes repositories without a user interface, for programs embedding
+// orgsync, and runs the syncs of the terminal UI. A run discovers, filters, clones and
+// fetches with the configured retries, repository hooks and pruning, writes the state
+// files, and works in the current directory as its sync root. What the command does
+// once a run is over, such as writing reports or the manifest and sending
+// notifications, is left to the caller.
+//
+// The Engine is the only owner of the state of its run: the syncs report to it, and
+// frontends render the copies returned by State, typically whenever an event arrives.
+// The zero value is ready to use. An Engine runs one run at a time.
+type Engine struct {
+	mu gosync.Mutex
+	// state is the current or last run, and index maps the Key of each repository
+	// to its position in state.Repositories
+	state   State
+	index   map[string]int
+	running bool
+	// pool runs the syncs once discovery is over, and is nil otherwise
+	pool *pool
+	// cancels interrupts the syncing repositories one by one, and skips lists those
+	// skipped on their way from the queue to a worker, both by Key
+	cancels map[string]context.CancelCauseFunc
+	skips   map[string]bool
+	// meter measures the rate at which Received grows
+	meter rateMeter
+	// failures counts the consecutive repositories that failed with network or
+	// authentication errors since the run started or was resumed
+	failures int
+	// confirm takes the answer of ConfirmPrune while the run waits for one, and is nil
+	// otherwise
+	confirm chan bool
+}
+
+// Run validates opts and starts a run in the background, returning its events. The
+// channel is closed after the final RunFinishedEvent, and the consumer must keep
+// receiving until then, since the run waits for every event but progress to be taken.
+// Cancelling ctx stops the run: running git commands are killed and the repositories
+// they were syncing finish with ErrCancelled. An empty opts.Host means github.com.
+func (e *Engine) Run(ctx context.Context, opts Options) (<-chan Event, error) {
+	return e.Start(ctx, opts, NewRunID(time.Now()))
+}
+
+// State returns a copy of the state of the current or last run, which stays valid
+// however the run goes on
+func (e *Engine) State() State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state.clone()
+}
+
+// Start starts a run identified by runID as Run does, for frontends that show the ID
+// of a run before it starts
+func (e *Engine) Start(ctx context.Context, opts Options, runID string) (<-chan Event, error) {
+	if opts.Host == "" {
+		opts.Host = "github.com"
+	}
+	if len(opts.Owners) > 1 && opts.Layout == "" {
+		opts.Layout = OwnerLayout
+	}
+	if len(opts.Owners) > 0 && opts.Owner == "" {
+		opts.Owner = opts.Owners[0]
+	}
+	if opts.Owner == "" && opts.Target != TargetGists && len(opts.Exec) == 0 {
+		return nil, errors.New("no organization or user to synchronize")
+	}
+	if err := opts.validateOwners(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateRepos(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateArchive(); err != nil {
+		return nil, err
+	}
+	if err := ValidateExec(opts.Exec); err != nil {
+		return nil, err
+	}
+	if opts.Layout != "" {
+		if err := ValidateLayout(opts.Layout); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.Policy.CheckHost(opts.Host); err != nil {
+		return nil, err
+	}
+	for _, owner := range opts.AllOwners() {
+		if owner == "" {
+			continue
+		}
+		if err := opts.Policy.CheckOwner(owner); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.Notify.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notify settings: %w", err)
+	}
+	if !ValidOrder(opts.Order) {
+		return nil, fmt.Errorf("unknown order %q", opts.Order)
+	}
+	if !ValidProtocol(opts.Protocol) {
+		return nil, fmt.Errorf("unknown protocol %q", opts.Protocol)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return nil, ErrRunning
+	}
+	e.running = true
+	e.state = State{RunID: runID, Options: opts, StartedAt: time.Now()}
+	e.index = nil
+	e.cancels = make(map[string]context.CancelCauseFunc)
+	e.skips = make(map[string]bool)
+	e.failures = 0
+	e.meter = rateMeter{}
+
+	events := make(chan Event, eventBuffer)
+	if opts.Replay != nil {
+		go e.replay(ctx, opts, events)
+	} else {
+		go e.run(ctx, opts, events)
+	}
+	return events, nil
+}
+
+// Enqueue adds repositories to the run while it syncs, e.g. to sync failed ones again
+// or ones created upstream since discovery. The run is done only once they are done
+// too. A repository the run already has is synchronized again if it is done, and left
+// alone while it is still queued or syncing. ErrNotRunning is returned before discovery
+// is over and once the last repository is done.
+func (e *Engine) Enqueue(repos ...Repository) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pool == nil {
+		return ErrNotRunning
+	}
+	var added []Repository
+	for _, repo := range repos {
+		if i, ok := e.index[repo.Key()]; ok && !e.state.Repositories[i].Done {
+			continue
+		}
+		added = append(added, repo.pending())
+	}
+	if !e.pool.add(added...) {
+		return ErrNotRunning
+	}
+	for _, repo := range added {
+		if i, ok := e.index[repo.Key()]; ok {
+			e.state.Repositories[i] = repo
+			continue
+		}
+		e.index[repo.Key()] = len(e.state.Repositories)
+		e.state.Repositories = append(e.state.Repositories, repo)
+	}
+	return nil
+}
+
+// Resume continues a run paused after consecutive network or authentication failures,
+// once their cause has been dealt with. It does nothing for a run that is not paused.
+func (e *Engine) Resume() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pool == nil {
+		return ErrNotRunning
+	}
+	if e.state.Paused {
+		logger.Info("run resumed", "run_id", e.state.RunID)
+	}
+	e.state.Paused = false
+	e.state.PausedBy = ""
+	e.failures = 0
+	e.pool.resume()
+	return nil
+}
+
+// ConfirmPrune answers a run waiting with a ConfirmPruneEvent: the clones in
+// State.Confirming are deleted if prune is set, and kept otherwise.
+func (e *Engine) ConfirmPrune(prune bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.confirm == nil {
+		return ErrNotConfirming
+	}
+	e.confirm <- prune
+	e.confirm = nil
+	e.state.Confirming = nil
+	return nil
+}
+
+// Skip takes a single repository out of the run, finishing it with ErrSkippedByUser: a
+// queued one never starts, and a syncing one has its git command killed. Clones are
+// staged, so this never leaves a half-written clone behind. The repository is named by
+// its Key, as owner/name.
+func (e *Engine) Skip(key string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pool == nil {
+		return ErrNotRunning
+	}
+	if i, ok := e.index[key]; !ok || e.state.Repositories[i].Done {
+		return fmt.Errorf("%s is neither queued nor syncing", key)
+	}
+	logger.Info("skipping repository", "run_id", e.state.RunID, "repo", key)
+	if cancel, ok := e.cancels[key]; ok {
+		cancel(ErrSkippedByUser)
+	} else if !e.pool.remove(key, ErrSkippedByUser) {
+		// A worker has just taken it off the queue
+		e.skips[key] = true
+	}
+	return nil
+}
+
+// Drain shuts the run down gracefully: no more repositories start, the queued ones
+// finish as cancelled and the run is done as soon as the running syncs are. Unlike
+// cancelling the context of the run, it never interrupts git, so no clone is left
+// half-written.
+func (e *Engine) Drain() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pool == nil {
+		return ErrNotRunning
+	}
+	if !e.state.Draining {
+		logger.Info("run draining", "run_id", e.state.RunID)
+	}
+	e.state.Draining = true
+	e.state.Paused = false
+	e.state.PausedBy = ""
+	e.pool.drain()
+	return nil
+}
+
+// pending returns the repository as discovered, without the outcome of any sync
+func (r Repository) pending() Repository {
+	return Repository{
+		Owner:         r.Owner,
+		Name:          r.Name,
+		Gist:          r.Gist,
+		Size:          r.Size,
+		Language:      r.Language,
+		Topics:        r.Topics,
+		PushedAt:      r.PushedAt,
+		DefaultBranch: r.DefaultBranch,
+		Visibility:    r.Visibility,
+		Archived:      r.Archived,
+		CI:            r.CI,
+	}
+}
+
+// update changes the state of the run under the lock and returns a copy of the result
+func (e *Engine) update(change func(s *State)) State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	change(&e.state)
+	return e.state.clone()
+}
+
+// updateRepository changes the state of the repository with the given key under the
+// lock, unless it is done already. Unlike update it copies nothing, as it runs for every
+// progress report.
+func (e *Engine) updateRepository(key string, change func(repo *Repository)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if i, ok := e.index[key]; ok && !e.state.Repositories[i].Done {
+		change(&e.state.Repositories[i])
+	}
+}
+
+// run carries out a run, saving the state and status files as it goes
+func (e *Engine) run(ctx context.Context, opts Options, events chan<- Event) {
+	defer close(events)
+	// Fail-fast cancels ctx, but the question whether to prune is still asked
+	outer := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.State().logStarted()
+
+	// Discovery waits out rate limits as the retry policy allows
+	var fetched discoveryResult
+	for attempt := 0; ; {
+		fetched = discoverRepositories(opts, attempt, func(pages, repos int) {
+			e.update(func(s *State) {
+				s.ListedPages = pages
+				s.Listed = repos
+			})
+			events <- &DiscoveryProgressEvent{Pages: pages, Repositories: repos}
+		})
+		if fetched.RetryAt.IsZero() {
+			break
+		}
+		e.update(func(s *State) {
+			s.RateLimitedUntil = fetched.RetryAt
+			s.RateLimit = fetched.RateLimit
+			s.DiscoveryAttempts = fetched.Attempt
+		})
+		events <- &RateLimitedEvent{Until: fetched.RetryAt, RateLimit: fetched.RateLimit}
+		attempt = fetched.Attempt
+		if !sleepContext(ctx, time.Until(fetched.RetryAt)) {
+			fetched = discoveryResult{Err: ErrCancelled}
+			break
+		}
+	}
+	state := e.update(func(s *State) {
+		s.Repositories = fetched.Repositories
+		s.Warnings = fetched.Warnings
+		s.RateLimit = fetched.RateLimit
+		s.RateLimitedUntil = time.Time{}
+		if fetched.Err != nil {
+			s.Errors = append(s.Errors, fetched.Err)
+		}
+		e.index = make(map[string]int, len(s.Repositories))
+		for i, repo := range s.Repositories {
+			e.index[repo.Key()] = i
+		}
+	})
+	// Clones of renamed and transferred repositories are dealt with before anything is
+	// cloned or pruned
+	if fetched.Err == nil && len(fetched.Upstream) > 0 {
+		redirects := followRedirects(ctx, opts, fetched.Upstream, state.Repositories)
+		state = e.update(func(s *State) {
+			s.Transferred = redirects.Transferred
+			s.Removed = opts.Baseline.removed(opts, fetched.Upstream)
+			for key, dir := range redirects.Moved {
+				repo := &s.Repositories[e.index[key]]
+				repo.Warnings = append(repo.Warnings, fmt.Sprintf("renamed on GitHub; moved from %s", dir))
+			}
+		})
+	}
+	state.writeStatus()
+	if fetched.Err == nil {
+		state.writeState()
+	}
+	events <- &DiscoveredEvent{
+		RunID:        state.RunID,
+		Repositories: state.Repositories,
+		Warnings:     fetched.Warnings,
+		RateLimit:    fetched.RateLimit,
+		Err:          fetched.Err,
+	}
+
+	// Pruning runs alongside the syncs, and only against a complete discovery. Clones
+	// are only looked into for work, so that cancelling the syncs does not spare them.
+	var pruned chan pruneResult
+	if opts.Prune && fetched.Err == nil && len(fetched.Upstream) > 0 && len(opts.Only) == 0 {
+		pruned = make(chan pruneResult, 1)
+		go func() {
+			result := pruneCandidates(context.WithoutCancel(ctx), opts, fetched.Upstream)
+			if !opts.Confirm {
+				result = removeOrphans(opts, result)
+			}
+			pruned <- result
+		}()
+	}
+
+	// The run goes on until every repository is done, including those enqueued meanwhile
+	workers := newPool(opts.Concurrency, opts.MaxSize, func(repo Repository) syncResult {
+		return e.syncQueued(ctx, opts, repo, events)
+	})
+	e.mu.Lock()
+	e.pool = workers
+	workers.add(schedule(state.Repositories, opts.Order)...)
+	e.mu.Unlock()
+	// Aborting a paused run has the queued repositories finish as cancelled
+	stop := context.AfterFunc(ctx, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.state.Paused = false
+		e.state.PausedBy = ""
+		workers.resume()
+	})
+	defer stop()
+	for !workers.idle() {
+		var paused bool
+		result := <-workers.results
+		repo := result.Repo
+		repo.Done = true
+		repo.Err = result.Err
+		state = e.update(func(s *State) {
+			delete(e.skips, repo.Key())
+			if i, ok := e.index[repo.Key()]; ok {
+				// The transfer progress is only ever reported to the engine
+				repo.Progress = s.Repositories[i].Progress
+				s.Repositories[i] = repo
+			}
+			if result.RateLimit.Limit > 0 {
+				s.RateLimit = result.RateLimit
+			}
+			// Abort everything else on the first real failure when fail-fast is enabled
+			if result.Err != nil && !errors.Is(result.Err, ErrCancelled) && !errors.Is(result.Err, ErrSkippedByUser) && opts.FailFast && !s.Stopped {
+				s.Stopped = true
+				cancel()
+			}
+			paused = e.countFailure(s, result.Err)
+		})
+		if paused {
+			workers.pause()
+			logger.Warn("run paused", "run_id", state.RunID, "failures", opts.PauseAfter, "category", state.PausedBy)
+		}
+		state.writeStatus()
+		state.writeState()
+		opts.repoDone(repo)
+		events <- &RepositoryFinishedEvent{Repository: repo}
+		if paused {
+			events <- &PausedEvent{Failures: opts.PauseAfter, Category: state.PausedBy}
+		}
+		workers.done()
+	}
+	e.mu.Lock()
+	e.pool = nil
+	e.mu.Unlock()
+
+	if pruned != nil {
+		result := <-pruned
+		if len(result.Pending) > 0 {
+			if e.awaitConfirmation(outer, result.Pending, events) {
+				result = removeOrphans(opts, result)
+			} else {
+				result = declineOrphans(result)
+			}
+		}
+		state = e.update(func(s *State) {
+			s.Pruned = result.Pruned
+			s.Kept = result.Kept
+			s.Spared = result.Spared
+			s.Declined = result.Declined
+		})
+		events <- &PrunedEvent{Pruned: result.Pruned, Kept: result.Kept, Spared: result.Spared, Declined: result.Declined, Err: result.Err}
+	}
+
+	// The files and subscribers see the finished run before frontends do, so that
+	// nothing is written any more once State reports it done
+	state.Done = true
+	state.FinishedAt = time.Now()
+	state.writeStatus()
+	if fetched.Err == nil {
+		state.writeState()
+	}
+	logger.Info("run finished", "run_id", state.RunID, "target", opts.label(), "repositories", len(state.Repositories), "failed", len(state.Failed()), "errors", len(state.Errors), "duration", state.FinishedAt.Sub(state.StartedAt))
+	report := state.Report()
+	opts.runComplete(report)
+	e.mu.Lock()
+	e.state.Done = true
+	e.state.FinishedAt = state.FinishedAt
+	e.running = false
+	e.mu.Unlock()
+	events <- &RunFinishedEvent{Report: report}
+}
+
+// awaitConfirmation asks whether to prune clones with a ConfirmPruneEvent and waits
+// for the answer to ConfirmPrune. A run cancelled meanwhile prunes nothing.
+func (e *Engine) awaitConfirmation(ctx context.Context, clones []string, events chan<- Event) bool {
+	answer := make(chan bool, 1)
+	e.update(func(s *State) {
+		s.Confirming = clones
+		e.confirm = answer
+	})
+	logger.Info("waiting for confirmation to prune", "clones", len(clones))
+	events <- &ConfirmPruneEvent{Clones: clones}
+	select {
+	case ok := <-answer:
+		return ok
+	case <-ctx.Done():
+		e.update(func(s *State) {
+			s.Confirming = nil
+			e.confirm = nil
+		})
+		return false
+	}
+}
+
+// countFailure keeps count of consecutive network and authentication failures, which
+// mean that every other repository is bound to fail as well, e.g. with an expired
+// token. It pauses the run in s when there were Options.PauseAfter in a row, reporting
+// whether it did. e.mu must be held.
+func (e *Engine) countFailure(s *State, err error) bool {
+	category := ClassifyError(err)
+	switch category {
+	case CategoryNetwork, CategoryAuth:
+		e.failures++
+	case CategoryCancelled, CategorySkipped:
+		return false
+	default:
+		e.failures = 0
+		return false
+	}
+	if s.Options.PauseAfter <= 0 || e.failures < s.Options.PauseAfter || s.Paused || s.Stopped {
+		return false
+	}
+	s.Paused = true
+	s.PausedBy = category
+	return true
+}
+
+// syncQueued synchronizes repo once it got a worker, reporting its start and progress
+// to the state of the run and as events
+func (e *Engine) syncQueued(ctx context.Context, opts Options, repo Repository, events chan<- Event) syncResult {
+	// Each repository can be cancelled on its own by Skip
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.mu.Lock()
+	e.cancels[repo.Key()] = cancel
+	if e.skips[repo.Key()] {
+		cancel(ErrSkippedByUser)
+	}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.cancels, repo.Key())
+		e.mu.Unlock()
+	}()
+	if ctx.Err() != nil {
+		return syncResult{Repo: repo, Err: cancelled(ctx)}
+	}
+
+	e.updateRepository(repo.Key(), func(r *Repository) {
+		r.QueueWait = repo.QueueWait
+		r.StartedAt = time.Now()
+	})
+	opts.repoStarted(repo)
+	events <- &RepositoryStartedEvent{Repository: repo}
+	report := func(progress float64, speed string, received int64) {
+		// Progress is best effort: drop events rather than stall git when the consumer
+		// falls behind, and stop reporting once the run has been cancelled
+		if ctx.Err() != nil {
+			return
+		}
+		e.updateRepository(repo.Key(), func(r *Repository) {
+			r.Progress = progress
+			r.TransferSpeed = speed
+			// A retry counts from zero again
+			delta := received - r.BytesReceived
+			if delta < 0 {
+				delta = received
+			}
+			r.BytesReceived = received
+			e.state.Received += delta
+			e.meter.add(time.Now(), e.state.Received)
+		})
+		opts.repoProgressed(repo.Key(), progress, speed)
+		select {
+		case events <- &RepositoryProgressEvent{Key: repo.Key(), Progress: progress, TransferSpeed: speed}:
+		default:
+		}
+	}
+	return syncRepository(ctx, opts, repo, report)
+}
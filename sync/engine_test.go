@@ -0,0 +1,173 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jdmcgrath/orgsync/internal/harness"
+)
+
+// runEngine runs opts to the end in the current directory and returns its report
+func runEngine(t *testing.T, opts Options) Report {
+	t.Helper()
+	var engine Engine
+	events, err := engine.Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("failed to start the run: %v", err)
+	}
+	var report Report
+	for event := range events {
+		if finished, ok := event.(*RunFinishedEvent); ok {
+			report = finished.Report
+		}
+	}
+	return report
+}
+
+// repositoryReport returns the report of the repository name, failing the test if the
+// run did not include it
+func repositoryReport(t *testing.T, report Report, name string) RepositoryReport {
+	t.Helper()
+	for _, repo := range report.Repositories {
+		if repo.Name == name {
+			return repo
+		}
+	}
+	t.Fatalf("%s is not in the report", name)
+	return RepositoryReport{}
+}
+
+func TestEngineRun(t *testing.T) {
+	const reset = "fatal: unable to access 'https://github.com/acme/api/': Connection reset by peer"
+	retryNetwork := RetryPolicy{CategoryNetwork: {Attempts: 2, Backoff: "1ms"}}
+
+	tests := []struct {
+		name string
+		// prepare sets up the fixture, and earlier runs, before the run under test
+		prepare func(t *testing.T, f *harness.Fixture)
+		opts    Options
+		check   func(t *testing.T, f *harness.Fixture, report Report)
+	}{
+		{
+			name: "clones new repositories",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", map[string]string{"README.md": "api\n"})
+				f.AddRepo("web", map[string]string{"README.md": "web\n"})
+			},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				if report.Totals.Succeeded != 2 {
+					t.Errorf("%d repositories succeeded, want 2", report.Totals.Succeeded)
+				}
+				for _, name := range []string{"api", "web"} {
+					data, err := os.ReadFile(filepath.Join(f.Root, name, "README.md"))
+					if err != nil || string(data) != name+"\n" {
+						t.Errorf("README.md of %s is %q, %v", name, data, err)
+					}
+					if got := f.Git(filepath.Join(f.Root, name), "config", "--get", "remote.origin.url"); got != "https://github.com/acme/"+name+".git" {
+						t.Errorf("origin of %s is %s", name, got)
+					}
+				}
+			},
+		},
+		{
+			name: "fetches existing clones",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", nil)
+				runEngine(t, Options{Owner: f.Owner})
+				f.Commit("api", map[string]string{"CHANGELOG.md": "v2\n"})
+			},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				if repo := repositoryReport(t, report, "api"); repo.Status != StatusSuccess || !repo.Updated {
+					t.Errorf("api has status %s, updated %v, want an updated success", repo.Status, repo.Updated)
+				}
+				if got, want := f.Git(filepath.Join(f.Root, "api"), "rev-parse", "origin/main"), f.Git(filepath.Join(f.Dir, "remotes", f.Owner, "api.git"), "rev-parse", "main"); got != want {
+					t.Errorf("origin/main is %s, want %s", got, want)
+				}
+			},
+		},
+		{
+			name: "retries a failed clone",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", nil)
+				f.FailClone("api", 1, reset)
+			},
+			opts: Options{Retry: retryNetwork},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				if repo := repositoryReport(t, report, "api"); repo.Status != StatusSuccess || repo.Attempts != 2 {
+					t.Errorf("api has status %s after %d attempts, want a success after 2", repo.Status, repo.Attempts)
+				}
+			},
+		},
+		{
+			name: "gives up after the last retry",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", nil)
+				f.FailClone("api", 2, reset)
+			},
+			opts: Options{Retry: retryNetwork},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				repo := repositoryReport(t, report, "api")
+				if repo.Status != StatusFailed || repo.Attempts != 2 || repo.ErrorCategory != CategoryNetwork {
+					t.Errorf("api has status %s after %d attempts with a %q error, want a network failure after 2", repo.Status, repo.Attempts, repo.ErrorCategory)
+				}
+				if _, err := os.Stat(filepath.Join(f.Root, "api")); !os.IsNotExist(err) {
+					t.Errorf("the failed clone of api was left behind: %v", err)
+				}
+			},
+		},
+		{
+			name: "prunes clones deleted upstream",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", nil)
+				f.AddRepo("web", nil)
+				runEngine(t, Options{Owner: f.Owner})
+				f.DeleteRepo("web")
+			},
+			opts: Options{Prune: true},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				if len(report.Pruned) != 1 || filepath.Base(report.Pruned[0]) != "web" {
+					t.Errorf("pruned %q, want web", report.Pruned)
+				}
+				if _, err := os.Stat(filepath.Join(f.Root, "web")); !os.IsNotExist(err) {
+					t.Errorf("web was not deleted: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(f.Root, "api")); err != nil {
+					t.Errorf("api was deleted: %v", err)
+				}
+			},
+		},
+		{
+			name: "keeps clones of other owners sharing the sync root",
+			prepare: func(t *testing.T, f *harness.Fixture) {
+				f.AddRepo("api", nil)
+				f.AddRepo("web", nil)
+				runEngine(t, Options{Owner: f.Owner})
+				vendored := filepath.Join(f.Root, "vendored")
+				f.Git("", "clone", "--quiet", "https://github.com/acme/web", vendored)
+				f.Git(vendored, "remote", "set-url", "origin", "https://github.com/globex/vendored.git")
+				f.DeleteRepo("web")
+			},
+			opts: Options{Prune: true},
+			check: func(t *testing.T, f *harness.Fixture, report Report) {
+				if len(report.Pruned) != 1 || filepath.Base(report.Pruned[0]) != "web" {
+					t.Errorf("pruned %q, want web", report.Pruned)
+				}
+				if _, err := os.Stat(filepath.Join(f.Root, "vendored")); err != nil {
+					t.Errorf("the clone of globex/vendored was deleted: %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := harness.New(t, "acme")
+			f.Setenv()
+			tt.prepare(t, f)
+			opts := tt.opts
+			opts.Owner = f.Owner
+			tt.check(t, f, runEngine(t, opts))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Error categories used to group failures in reports
+const (
+	CategoryAuth      = "auth"
+	CategoryRateLimit = "rate_limit"
+	CategoryNotFound  = "not_found"
+	CategoryNetwork   = "network"
+	CategoryDisk      = "disk"
+	CategoryCancelled = "cancelled"
+	CategoryConflict  = "conflict"
+	CategoryDirty     = "dirty"
+	CategoryTooLarge  = "too_large"
+	CategorySkipped   = "skipped"
+	CategoryArchived  = "archived"
+	CategoryCommand   = "command"
+	CategoryUnknown   = "unknown"
+)
+
+// ErrCancelled marks repositories whose sync was aborted before it could finish
+var ErrCancelled = errors.New("cancelled")
+
+// ErrSkippedByUser marks a repository the user took out of a run while it was queued
+// or syncing
+var ErrSkippedByUser = errors.New("skipped by user")
+
+// cancelled returns the error for a sync that ctx interrupted: ErrSkippedByUser when
+// the user skipped the repository, ErrCancelled otherwise
+func cancelled(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), ErrSkippedByUser) {
+		return ErrSkippedByUser
+	}
+	return ErrCancelled
+}
+
+// commandError is returned when a git or gh command fails, carrying its stderr
+type commandError struct {
+	err    error
+	stderr string
+}
+
+func (e *commandError) Error() string {
+	if e.stderr == "" {
+		return e.err.Error()
+	}
+	lines := strings.Split(e.stderr, "\n")
+	return e.err.Error() + ": " + lines[len(lines)-1]
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+// newCommandError attaches the captured stderr of a command to its error
+func newCommandError(err error, progress *progressWriter) error {
+	return &commandError{err: err, stderr: progress.Output()}
+}
+
+// ErrorOutput returns the stderr captured for a failed command, if any
+func ErrorOutput(err error) string {
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.stderr
+	}
+	var replayed *replayedError
+	if errors.As(err, &replayed) {
+		return replayed.output
+	}
+	return ""
+}
+
+// categoryPatterns maps substrings of git and gh output to error categories
+var categoryPatterns = []struct {
+	category string
+	patterns []string
+}{
+	// Checked before auth because GitHub reports rate limits as HTTP 403
+	{CategoryRateLimit, []string{"rate limit exceeded", "secondary rate limit", "http 429"}},
+	{CategoryAuth, []string{"authentication failed", "permission denied (publickey)", "could not read username", "http 401", "http 403", "bad credentials"}},
+	{CategoryNotFound, []string{"repository not found", "could not resolve to a repository", "could not resolve to an organization", "http 404", "not found"}},
+	{CategoryNetwork, []string{"could not resolve host", "connection timed out", "connection reset", "connection refused", "early eof", "unable to access", "the remote end hung up"}},
+	// Checked after auth so that "Permission denied (publickey)" is not mistaken for a disk error
+	{CategoryDisk, []string{"not enough free disk space", "read-only file system", "permission denied", "no space left on device", "disk quota exceeded"}},
+}
+
+// categoryHints suggests a fix for categories with a well-known cause
+var categoryHints = map[string]string{
+	CategoryAuth:      "check that `gh auth status` succeeds and the token can read the repository",
+	CategoryConflict:  "rerun with --on-conflict adopt to point origin at the expected repository, or relocate to move the directory aside",
+	CategoryRateLimit: "wait for the API quota to reset (see `gh api rate_limit`) or pause other tools sharing the token, then rerun with 'orgsync rerun --failed'",
+	CategoryDirty:     "commit or stash the local changes, rerun with --stash to have them stashed and restored around a checkout, or with --force to go ahead anyway",
+	CategoryTooLarge:  "raise --max-size to include it, or clone it by hand where there is room for it",
+	CategoryDisk:      "check that the sync directory is on a writable file system with free space and that you own it",
+}
+
+// Hint returns a remediation hint for an error category, or "" if there is none
+func Hint(category string) string {
+	return categoryHints[category]
+}
+
+// ClassifyError assigns a failure to a category based on its message and output
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrCancelled) {
+		return CategoryCancelled
+	}
+	if errors.Is(err, ErrConflict) {
+		return CategoryConflict
+	}
+	if errors.Is(err, ErrDirty) {
+		return CategoryDirty
+	}
+	if errors.Is(err, ErrTooLarge) {
+		return CategoryTooLarge
+	}
+	if errors.Is(err, ErrSkippedByUser) {
+		return CategorySkipped
+	}
+	if errors.Is(err, ErrArchived) {
+		return CategoryArchived
+	}
+	// What an exec command prints is its own business, not a sign of what went wrong
+	if errors.Is(err, ErrCommandFailed) {
+		return CategoryCommand
+	}
+	// A replayed failure keeps the category it was recorded with
+	var replayed *replayedError
+	if errors.As(err, &replayed) && replayed.category != "" {
+		return replayed.category
+	}
+	text := strings.ToLower(err.Error() + "\n" + ErrorOutput(err))
+	for _, c := range categoryPatterns {
+		for _, pattern := range c.patterns {
+			if strings.Contains(text, pattern) {
+				return c.category
+			}
+		}
+	}
+	return CategoryUnknown
+}
+
+// SkipReason explains why a repository that finished with err was skipped rather than
+// synchronized, e.g. "dirty", or returns "" if it was not skipped
+func SkipReason(err error) string {
+	switch {
+	case errors.Is(err, ErrDirty):
+		return "dirty"
+	case errors.Is(err, ErrTooLarge):
+		return "too large"
+	case errors.Is(err, ErrSkippedByUser):
+		return "by user"
+	case errors.Is(err, ErrArchived):
+		return "archived"
+	default:
+		return ""
+	}
+}
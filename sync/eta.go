@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+)
+
+// etaMinProgress is how far a run must have got before an ETA is estimated, as the
+// first seconds of a run say little about the rest of it
+const etaMinProgress = 0.02
+
+// DoneFraction is the fraction of the run that is done, weighted by the size of each
+// repository once the API reported sizes: a finished repository counts with all of its
+// size and a syncing one with the share git reported receiving. Without sizes, e.g. for
+// gists, every repository weighs the same.
+func (s State) DoneFraction() float64 {
+	if len(s.Repositories) == 0 {
+		return 0
+	}
+	total := s.TotalSize()
+	var done float64
+	for _, repo := range s.Repositories {
+		weight := float64(repo.Size)
+		if total == 0 {
+			weight = 1
+		}
+		switch {
+		case repo.Done:
+			done += weight
+		case !repo.StartedAt.IsZero():
+			done += weight * repo.Progress
+		}
+	}
+	if total == 0 {
+		return done / float64(len(s.Repositories))
+	}
+	return done / float64(total)
+}
+
+// eta estimates how long the rest of the run takes at the pace the syncs went so far,
+// from when the first repository started. It reports false before there is enough to
+// go on, and once the run is done.
+func (s State) eta(now time.Time) (time.Duration, bool) {
+	if s.Done {
+		return 0, false
+	}
+	var started time.Time
+	for _, repo := range s.Repositories {
+		if !repo.StartedAt.IsZero() && (started.IsZero() || repo.StartedAt.Before(started)) {
+			started = repo.StartedAt
+		}
+	}
+	done := s.DoneFraction()
+	if started.IsZero() || done < etaMinProgress || done >= 1 {
+		return 0, false
+	}
+	elapsed := now.Sub(started)
+	return time.Duration(float64(elapsed) * (1 - done) / done), true
+}
+
+// ETAStatus renders the ETA for the header, or is empty when there is none
+func (s State) ETAStatus(now time.Time) string {
+	eta, ok := s.eta(now)
+	if !ok {
+		return ""
+	}
+	eta = eta.Round(time.Minute)
+	switch {
+	case eta < time.Minute:
+		return "ETA <1m"
+	case eta < time.Hour:
+		return fmt.Sprintf("ETA %dm", eta/time.Minute)
+	default:
+		return fmt.Sprintf("ETA %dh%02dm", eta/time.Hour, eta%time.Hour/time.Minute)
+	}
+}
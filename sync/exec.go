@@ -0,0 +1,196 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// maxExecOutput bounds how much of what the command of an exec run prints is kept per
+// repository; the end is kept, where failures are usually reported
+const maxExecOutput = 64 << 10
+
+// execWaitDelay is how long an exec command that was cancelled gets to close its output
+// before it is abandoned, e.g. when it left children behind that still hold it open
+const execWaitDelay = 5 * time.Second
+
+// ErrCommandFailed marks a repository in which the command of an exec run failed
+var ErrCommandFailed = errors.New("command failed")
+
+// execVars are what the arguments of an exec command can refer to, e.g.
+// {{.Org}}/{{.Name}}. Org is the owner under another name, and Path is the absolute
+// path of the clone.
+type execVars struct {
+	Owner         string
+	Org           string
+	Name          string
+	DefaultBranch string
+	Path          string
+}
+
+// ValidateExec checks that the arguments of an exec command are valid templates that
+// only refer to the fields the command can be given: Owner, Org, Name, DefaultBranch
+// and Path
+func ValidateExec(args []string) error {
+	_, err := expandExec(args, execVars{})
+	return err
+}
+
+// expandExec fills in the templates in the arguments of an exec command. An argument
+// without {{ is taken as it is.
+func expandExec(args []string, vars execVars) ([]string, error) {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			expanded[i] = arg
+			continue
+		}
+		tmpl, err := template.New("exec").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in %q: %w", arg, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return nil, fmt.Errorf("invalid template in %q: %w", arg, err)
+		}
+		expanded[i] = b.String()
+	}
+	return expanded, nil
+}
+
+// execRepositories selects the clones recorded in the manifest of the sync root for a
+// run of Exec. Nothing is asked of GitHub; clones that are gone since the manifest was
+// written are left out with a warning.
+func execRepositories(opts Options) discoveryResult {
+	manifest, err := LoadManifest(ManifestFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = errors.New("no manifest in this directory; synchronize it first")
+		}
+		return discoveryResult{Err: err}
+	}
+	var (
+		repos    []Repository
+		warnings []string
+	)
+	for _, entry := range manifest.Repositories {
+		dir := filepath.FromSlash(entry.Path)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			warnings = append(warnings, fmt.Sprintf("skipped %s: its clone at %s is gone", entry.Name, entry.Path))
+			continue
+		}
+		repos = append(repos, Repository{
+			Owner:         entry.Owner,
+			Name:          entry.Name,
+			Dir:           dir,
+			DefaultBranch: entry.DefaultBranch,
+			Size:          entry.Size,
+		})
+	}
+	repos = filterOnly(repos, opts.Only)
+	logger.Info("listed clones", "manifest", ManifestFile, "clones", len(manifest.Repositories), "selected", len(repos))
+	return discoveryResult{Repositories: repos, Warnings: warnings}
+}
+
+// execRepository runs the command of an exec run in the clone of repo, once and without
+// retries, keeping what it prints and its exit status. Its arguments are expanded for
+// repo first. A command that exits with a status other than 0 fails the repository.
+func execRepository(ctx context.Context, opts Options, repo Repository) syncResult {
+	path, err := filepath.Abs(opts.repoDir(repo))
+	if err != nil {
+		err = fmt.Errorf("failed to resolve the path of %s: %w", repo.Name, err)
+	}
+	var args []string
+	if err == nil {
+		args, err = expandExec(opts.Exec, execVars{Owner: repo.Owner, Org: repo.Owner, Name: repo.Name, DefaultBranch: repo.DefaultBranch, Path: path})
+	}
+	if err != nil {
+		repo.FinishedAt = time.Now()
+		logRepositoryFinished(repo, err)
+		return syncResult{Repo: repo, Err: err}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = path
+	cmd.Env = append(os.Environ(),
+		"ORGSYNC_OWNER="+repo.Owner,
+		"ORGSYNC_REPO="+repo.Name,
+		"ORGSYNC_PATH="+path,
+	)
+	cmd.WaitDelay = execWaitDelay
+	started := time.Now()
+	repo.Attempts++
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimRight(string(out), "\n")
+	if len(output) > maxExecOutput {
+		output = "..." + output[len(output)-maxExecOutput:]
+	}
+	logCommand(slog.LevelInfo, cmd, started, err, "")
+
+	repo.ExitCode = -1
+	if cmd.ProcessState != nil {
+		repo.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		err = cancelled(ctx)
+	case err != nil && repo.ExitCode > 0:
+		err = &commandError{err: fmt.Errorf("%w with exit status %d", ErrCommandFailed, repo.ExitCode), stderr: output}
+	case err != nil:
+		err = &commandError{err: fmt.Errorf("%w: %w", ErrCommandFailed, err), stderr: output}
+	}
+	repo.History = append(repo.History, Attempt{
+		Commands:  []string{strings.Join(args, " ")},
+		StartedAt: started,
+		Duration:  time.Since(started),
+		Err:       err,
+		Output:    output,
+	})
+	repo.FinishedAt = time.Now()
+	logRepositoryFinished(repo, err)
+	return syncResult{Repo: repo, Err: err}
+}
+
+// ExecOutput returns what the command of an exec run printed in the clone of the
+// repository
+func (r Repository) ExecOutput() string {
+	if len(r.History) == 0 {
+		return ""
+	}
+	return r.History[len(r.History)-1].Output
+}
+
+// IndentOutput indents every line of output, to set it apart from the line naming the
+// repository it came from
+func IndentOutput(output string) []string {
+	if output == "" {
+		return nil
+	}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+	return lines
+}
+
+// ExecOutput renders what the command of an exec run printed in each repository it ran
+// in, under a line naming the repository and how the command ended, for printing once
+// the terminal UI is gone
+func (s State) ExecOutput() []string {
+	var lines []string
+	for _, repo := range s.Repositories {
+		if repo.FinishedAt.IsZero() {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", repo.Name, PlainStatus(repo)))
+		lines = append(lines, IndentOutput(repo.ExecOutput())...)
+	}
+	return lines
+}
@@ -0,0 +1,88 @@
+package sync
+
+import "time"
+
+// digestLayout is the format of the time of day a FailureTracker posts its digest at
+const digestLayout = "15:04"
+
+// FailureTracker follows which repositories fail across the successive runs of one
+// program, so that a long-running one can report only what changed rather than the
+// same failures after every run. Record must not be called concurrently.
+type FailureTracker struct {
+	// Digest is a time of day such as "09:00" after which the next run is due a full
+	// summary, once a day, whether or not anything changed
+	Digest string
+
+	// failing holds the repositories failing as of the last run by owner/name, nil
+	// before the first run
+	failing map[string]bool
+	// digested is when the last digest was due, or the first run finished
+	digested time.Time
+}
+
+// FailureChanges is how the outcome of a run differs from the one before it
+type FailureChanges struct {
+	// Failed lists the repositories that started failing and Recovered those that no
+	// longer fail
+	Failed    []RepositoryReport
+	Recovered []RepositoryReport
+	// First is set for the first run, which has nothing to be compared with
+	First bool
+	// Digest is set when the run is due the daily full summary
+	Digest bool
+}
+
+// Changed reports whether any repository started failing or recovered
+func (c FailureChanges) Changed() bool {
+	return len(c.Failed)+len(c.Recovered) > 0
+}
+
+// Record takes in the run in r, finished at now, and returns how it differs from the
+// previous one
+func (t *FailureTracker) Record(r Report, now time.Time) FailureChanges {
+	previous := t.failing
+	changes := FailureChanges{First: previous == nil}
+
+	failing := make(map[string]bool, len(previous))
+	for key := range previous {
+		failing[key] = true
+	}
+	for _, repo := range r.Repositories {
+		key := repo.Owner + "/" + repo.Name
+		switch repo.Status {
+		case StatusFailed, StatusConflict:
+			failing[key] = true
+			if !changes.First && !previous[key] {
+				changes.Failed = append(changes.Failed, repo)
+			}
+		case StatusPending, StatusCancelled:
+			// Repositories the run did not finish keep their previous state
+		default:
+			delete(failing, key)
+			if previous[key] {
+				changes.Recovered = append(changes.Recovered, repo)
+			}
+		}
+	}
+	t.failing = failing
+
+	// The first run starts the clock for the digest
+	changes.Digest = !changes.First && t.digestDue(now)
+	if changes.Digest || changes.First {
+		t.digested = now
+	}
+	return changes
+}
+
+// digestDue reports whether the daily digest time passed since the last digest
+func (t *FailureTracker) digestDue(now time.Time) bool {
+	at, err := time.Parse(digestLayout, t.Digest)
+	if t.Digest == "" || err != nil {
+		return false
+	}
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if now.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	return t.digested.Before(scheduled)
+}
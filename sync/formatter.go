@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// OutputFormatter encodes a run report in one output format. Formats register
+// themselves by name with RegisterFormatter and are chosen with --output.
+type OutputFormatter interface {
+	Format(w io.Writer, r Report) error
+}
+
+// FormatterFunc adapts an ordinary function to the OutputFormatter interface
+type FormatterFunc func(w io.Writer, r Report) error
+
+// Format calls f(w, r)
+func (f FormatterFunc) Format(w io.Writer, r Report) error {
+	return f(w, r)
+}
+
+// formatters holds every registered output format by name
+var formatters = make(map[string]OutputFormatter)
+
+// RegisterFormatter makes an output format available under name. It panics if the
+// name is already taken, as that is a programming error.
+func RegisterFormatter(name string, f OutputFormatter) {
+	if _, ok := formatters[name]; ok {
+		panic(fmt.Sprintf("output format %q registered twice", name))
+	}
+	formatters[name] = f
+}
+
+// LookupFormatter returns the output format registered under name
+func LookupFormatter(name string) (OutputFormatter, error) {
+	f, ok := formatters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q", name)
+	}
+	return f, nil
+}
+
+// FormatterNames lists the registered output formats
+func FormatterNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	RegisterFormatter("gha", FormatterFunc(writeGHA))
+}
+
+// ghaData escapes a workflow command message as the GitHub Actions runner expects
+var ghaData = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// ghaProperty escapes a workflow command property, which additionally reserves ':' and ','
+var ghaProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// writeGHA writes GitHub Actions workflow commands, so failures and warnings show up
+// as annotations on the workflow run when the report is printed to standard output
+func writeGHA(w io.Writer, r Report) error {
+	var b strings.Builder
+	t := r.Totals
+	fmt.Fprintf(&b, "::group::orgsync %s (run %s)\n", r.Target, r.RunID)
+	for _, repo := range r.Repositories {
+		switch repo.Status {
+		case StatusFailed, StatusConflict:
+			message := repo.Error
+			if repo.Hint != "" {
+				message += "\nHint: " + repo.Hint
+			}
+			if repo.Output != "" {
+				message += "\n" + repo.Output
+			}
+			title := fmt.Sprintf("%s (%s)", repo.Name, repo.ErrorCategory)
+			fmt.Fprintf(&b, "::error title=%s::%s\n", ghaProperty.Replace(title), ghaData.Replace(message))
+		case StatusSkipped:
+			fmt.Fprintf(&b, "::warning title=%s::%s\n", ghaProperty.Replace(repo.Name), ghaData.Replace("skipped: "+repo.Error))
+		}
+		for _, warning := range repo.Warnings {
+			fmt.Fprintf(&b, "::warning title=%s::%s\n", ghaProperty.Replace(repo.Name), ghaData.Replace(warning))
+		}
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(&b, "::warning title=orgsync::%s\n", ghaData.Replace(warning))
+	}
+	b.WriteString("::endgroup::\n")
+
+	summary := fmt.Sprintf("orgsync %s: %d repositories, %d succeeded, %d failed", r.Target, t.Repositories, t.Succeeded, t.Failed+t.Conflicts)
+	if t.Skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", t.Skipped)
+	}
+	if t.Cancelled+t.Pending > 0 {
+		summary += fmt.Sprintf(", %d not synchronized", t.Cancelled+t.Pending)
+	}
+	fmt.Fprintf(&b, "::notice title=orgsync::%s\n", ghaData.Replace(summary))
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
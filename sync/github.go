@@ -0,0 +1,257 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitHub is reached through gh, which takes care of logins, hosts and pagination. Where
+// gh is not installed, e.g. in build containers that only have git, a token from the
+// environment is used to call the API over HTTPS and to authenticate git instead.
+
+// apiTimeout bounds a single API request made without gh
+const apiTimeout = time.Minute
+
+var apiClient = &http.Client{Timeout: apiTimeout}
+
+// apiHost is the GitHub host to talk to, read from GH_HOST as gh does
+func apiHost() string {
+	if host := os.Getenv("GH_HOST"); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+// apiToken returns the token for host and the environment variable holding it, looked
+// up in the variables gh reads, or empty strings if none is set
+func apiToken(host string) (token, variable string) {
+	variables := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != "github.com" {
+		variables = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	for _, variable := range variables {
+		if token := os.Getenv(variable); token != "" {
+			return token, variable
+		}
+	}
+	return "", ""
+}
+
+// withoutGH reports whether GitHub is reached without gh, because gh is not installed
+// and a token is set
+func withoutGH() bool {
+	if _, err := exec.LookPath("gh"); err == nil {
+		return false
+	}
+	_, variable := apiToken(apiHost())
+	return variable != ""
+}
+
+// apiURL returns the URL of a REST API endpoint, or of the GraphQL API for "graphql"
+func apiURL(host, endpoint string) string {
+	switch {
+	case host == "github.com":
+		return "https://api.github.com/" + endpoint
+	case endpoint == "graphql":
+		return "https://" + host + "/api/graphql"
+	default:
+		return "https://" + host + "/api/v3/" + endpoint
+	}
+}
+
+// apiGet fetches a REST API endpoint and returns the JSON GitHub answered with. With
+// paginate, the JSON of every page follows the one before, as `gh api --paginate`
+// prints it. Through gh, its stderr goes to stderr when that is set.
+func apiGet(endpoint string, paginate bool, stderr *progressWriter) ([]byte, error) {
+	if !withoutGH() {
+		args := []string{"api", endpoint}
+		if paginate {
+			args = []string{"api", "--paginate", endpoint}
+		}
+		return runGH(args, stderr)
+	}
+
+	var out bytes.Buffer
+	for url := apiURL(apiHost(), endpoint); url != ""; {
+		page, next, err := apiRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(page)
+		if !paginate {
+			break
+		}
+		url = next
+	}
+	return out.Bytes(), nil
+}
+
+// apiGraphQL runs a GraphQL query with string variables and returns the JSON GitHub
+// answered with. Errors in the answer fail the query, as they make gh fail.
+func apiGraphQL(query string, variables map[string]string, stderr *progressWriter) ([]byte, error) {
+	if !withoutGH() {
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		args := []string{"api", "graphql"}
+		for _, name := range names {
+			args = append(args, "-f", name+"="+variables[name])
+		}
+		return runGH(append(args, "-f", "query="+query), stderr)
+	}
+
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+	data, _, err := apiRequest(http.MethodPost, apiURL(apiHost(), "graphql"), body)
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL answer: %w", err)
+	}
+	if len(answer.Errors) > 0 {
+		messages := make([]string, len(answer.Errors))
+		for i, e := range answer.Errors {
+			messages[i] = e.Message
+		}
+		return nil, fmt.Errorf("GraphQL: %s", strings.Join(messages, ", "))
+	}
+	return data, nil
+}
+
+// runGH runs gh with args and returns its standard output. Its stderr is parsed by
+// stderr when that is set, and only logged otherwise.
+func runGH(args []string, stderr *progressWriter) ([]byte, error) {
+	cmd := exec.Command("gh", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if stderr != nil {
+		if err := runCommand(cmd, stderr); err != nil {
+			return nil, newCommandError(err, stderr)
+		}
+		return out.Bytes(), nil
+	}
+	var errOut bytes.Buffer
+	cmd.Stderr = &errOut
+	started := time.Now()
+	err := cmd.Run()
+	// Lookups are frequent and expected to fail at times, so they are only debug records
+	logCommand(slog.LevelDebug, cmd, started, err, strings.TrimSpace(errOut.String()))
+	if err != nil {
+		return nil, &commandError{err: err, stderr: strings.TrimSpace(errOut.String())}
+	}
+	return out.Bytes(), nil
+}
+
+// apiRequest sends a request to the API with the token from the environment and
+// returns the body of the answer and the URL of the next page, if there is one
+func apiRequest(method, url string, body []byte) (data []byte, next string, err error) {
+	token, _ := apiToken(apiHost())
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), method, url, reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build request for %s: %w", url, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	started := time.Now()
+	resp, err := apiClient.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		data, err = io.ReadAll(resp.Body)
+	}
+	if err == nil && resp.StatusCode >= http.StatusMultipleChoices {
+		// Shaped like gh's errors, so that they are classified alike
+		var answer struct{ Message string }
+		json.Unmarshal(data, &answer)
+		if answer.Message == "" {
+			answer.Message = http.StatusText(resp.StatusCode)
+		}
+		err = fmt.Errorf("%s (HTTP %d)", answer.Message, resp.StatusCode)
+	}
+	attrs := []any{"method", method, "url", url, "duration", time.Since(started)}
+	if err != nil {
+		logger.Warn("api request failed", append(attrs, "error", err)...)
+		return nil, "", err
+	}
+	logger.Debug("api request finished", append(attrs, "status", resp.StatusCode)...)
+	return data, nextPage(resp.Header.Get("Link")), nil
+}
+
+// nextPage returns the URL of the next page from a Link header, or "" on the last page
+func nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		url, rel, ok := strings.Cut(part, ";")
+		if ok && strings.TrimSpace(rel) == `rel="next"` {
+			return strings.Trim(strings.TrimSpace(url), "<>")
+		}
+	}
+	return ""
+}
+
+// decodePages decodes the JSON arrays of a paginated answer into one slice
+func decodePages[T any](data []byte) ([]T, error) {
+	var all []T
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var page []T
+		err := dec.Decode(&page)
+		if errors.Is(err, io.EOF) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse API answer: %w", err)
+		}
+		all = append(all, page...)
+	}
+}
+
+// gitEnv returns the environment git commands run with, or nil to inherit it. Without
+// gh, git is given a credential helper answering with the token for the GitHub host.
+// The helper reads the token from the environment, so it never shows in a command line
+// or a config file.
+func gitEnv() []string {
+	if !withoutGH() {
+		return nil
+	}
+	host := apiHost()
+	_, variable := apiToken(host)
+	key := "credential.https://" + host + ".helper"
+	helper := fmt.Sprintf(`!f() { test "$1" = get && echo username=x-access-token && echo "password=$%s"; }; f`, variable)
+
+	// Configuration passed the same way by the caller is kept
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool { return strings.HasPrefix(kv, "GIT_CONFIG_COUNT=") })
+	return append(env,
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+2),
+		// An empty helper clears those configured elsewhere for the host
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=", n),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n+1, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n+1, helper),
+	)
+}
@@ -0,0 +1,276 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// HistoryFile records every finished run, one JSON line each, in HistoryDir. Runs of
+// all sync roots go to the same file, so that `orgsync history` can compare them.
+const HistoryFile = "history.jsonl"
+
+// HistoryRun and HistoryRepository are defined in the schema package
+type (
+	HistoryRun        = schema.HistoryRun
+	HistoryRepository = schema.HistoryRepository
+)
+
+const (
+	// slowerFactor is how many times longer a repository must take lately than it used
+	// to for the history to call it slower
+	slowerFactor = 2
+	// minTrendSamples is how many timed syncs a repository needs for a trend
+	minTrendSamples = 4
+	// minTrendDuration ignores repositories that are quick either way, where a few
+	// seconds of network jitter would look like a trend
+	minTrendDuration = 5 * time.Second
+	// minRepeatFailures is how often a repository must have failed to be a repeat offender
+	minRepeatFailures = 2
+)
+
+// HistoryDir is where orgsync keeps data across sync roots: $XDG_DATA_HOME/orgsync,
+// or ~/.local/share/orgsync
+func HistoryDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "orgsync"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the history: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "orgsync"), nil
+}
+
+// historyRun condenses the report of a run in root to what the history keeps
+func historyRun(r Report, root string) HistoryRun {
+	run := HistoryRun{
+		Schema:          schema.HistorySchema,
+		RunID:           r.RunID,
+		Root:            root,
+		Target:          r.Target,
+		StartedAt:       r.StartedAt,
+		DurationSeconds: r.Totals.DurationSeconds,
+		Repositories:    make([]HistoryRepository, 0, len(r.Repositories)),
+	}
+	for _, repo := range r.Repositories {
+		run.Repositories = append(run.Repositories, HistoryRepository{
+			Owner:           repo.Owner,
+			Name:            repo.Name,
+			Status:          repo.Status,
+			ErrorCategory:   repo.ErrorCategory,
+			DurationSeconds: repo.DurationSeconds,
+			Bytes:           repo.Bytes,
+			UpToDate:        repo.UpToDate,
+		})
+	}
+	return run
+}
+
+// AppendHistory adds the run of report in the sync root root to the history at path.
+// The line is appended in a single write, so runs finishing at the same time in
+// different roots do not interleave.
+func AppendHistory(path, root string, r Report) error {
+	data, err := json.Marshal(historyRun(r, root))
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// LoadHistory reads the runs recorded at path, oldest first. Lines that cannot be
+// read, such as one cut short by a crash or written by a newer orgsync, are skipped.
+func LoadHistory(path string) ([]HistoryRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	var runs []HistoryRun
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var run HistoryRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		if schema.Check(run.Schema, schema.HistorySchema) != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	slices.SortStableFunc(runs, func(a, b HistoryRun) int { return a.StartedAt.Compare(b.StartedAt) })
+	return runs, nil
+}
+
+// Trend is a repository that takes markedly longer to sync lately than it used to
+type Trend struct {
+	Repository string
+	// Before and Recent are the median durations of the earlier and the later half of
+	// its timed syncs
+	Before time.Duration
+	Recent time.Duration
+	// Runs counts the timed syncs the trend is based on
+	Runs int
+}
+
+// Factor is how many times longer the repository takes now
+func (t Trend) Factor() float64 {
+	return float64(t.Recent) / float64(t.Before)
+}
+
+// Offender is a repository that failed in several runs
+type Offender struct {
+	Repository string
+	Failures   int
+	// Runs counts the runs the repository was part of
+	Runs int
+	// Category is the error category of its latest failure
+	Category   string
+	LastFailed time.Time
+}
+
+// HistoryAnalysis is what the history says about the runs of a period
+type HistoryAnalysis struct {
+	Runs      int
+	Since     time.Time
+	Slower    []Trend
+	Offenders []Offender
+}
+
+// AnalyzeHistory looks for repositories that got slower and for those failing again
+// and again, among the runs since the given time in the sync root root, or in every
+// root when root is empty. Syncs that found a clone up to date are not timed, as they
+// transfer nothing and would hide a trend.
+func AnalyzeHistory(runs []HistoryRun, root string, since time.Time) HistoryAnalysis {
+	analysis := HistoryAnalysis{Since: since}
+	durations := make(map[string][]time.Duration)
+	offenders := make(map[string]*Offender)
+	var order []string
+	for _, run := range runs {
+		if (root != "" && run.Root != root) || run.StartedAt.Before(since) {
+			continue
+		}
+		analysis.Runs++
+		for _, repo := range run.Repositories {
+			name := repo.Name
+			if repo.Owner != "" {
+				name = repo.Owner + "/" + repo.Name
+			}
+			offender, ok := offenders[name]
+			if !ok {
+				offender = &Offender{Repository: name}
+				offenders[name] = offender
+				order = append(order, name)
+			}
+			offender.Runs++
+			switch repo.Status {
+			case StatusSuccess:
+				if !repo.UpToDate && repo.DurationSeconds > 0 {
+					durations[name] = append(durations[name], seconds(repo.DurationSeconds))
+				}
+			case StatusFailed, StatusConflict:
+				offender.Failures++
+				offender.Category = repo.ErrorCategory
+				offender.LastFailed = run.StartedAt
+			}
+		}
+	}
+
+	for _, name := range order {
+		if trend, ok := trendOf(name, durations[name]); ok {
+			analysis.Slower = append(analysis.Slower, trend)
+		}
+		if offender := offenders[name]; offender.Failures >= minRepeatFailures {
+			analysis.Offenders = append(analysis.Offenders, *offender)
+		}
+	}
+	slices.SortStableFunc(analysis.Slower, func(a, b Trend) int { return cmp.Compare(b.Factor(), a.Factor()) })
+	slices.SortStableFunc(analysis.Offenders, func(a, b Offender) int { return cmp.Compare(b.Failures, a.Failures) })
+	return analysis
+}
+
+// trendOf compares the median durations of the earlier and later half of a
+// repository's syncs, in the order they happened, and reports whether it got slower
+func trendOf(name string, durations []time.Duration) (Trend, bool) {
+	if len(durations) < minTrendSamples {
+		return Trend{}, false
+	}
+	half := len(durations) / 2
+	trend := Trend{
+		Repository: name,
+		Before:     median(durations[:half]),
+		Recent:     median(durations[len(durations)-half:]),
+		Runs:       len(durations),
+	}
+	if trend.Before <= 0 || trend.Recent < minTrendDuration || trend.Factor() < slowerFactor {
+		return Trend{}, false
+	}
+	return trend, true
+}
+
+// median returns the middle of the durations
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// WriteHistory prints the analysis for people
+func WriteHistory(w io.Writer, a HistoryAnalysis) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d runs since %s\n", a.Runs, a.Since.Format(time.DateOnly))
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	if len(a.Slower) == 0 {
+		fmt.Fprintf(tw, "\nNo repository got notably slower.\n")
+	} else {
+		fmt.Fprintf(tw, "\nGot slower:\n")
+		for _, t := range a.Slower {
+			fmt.Fprintf(tw, "  %s\t%.1fx slower\t%s %s %s\tover %d syncs\n", t.Repository, t.Factor(), t.Before.Round(time.Second), glyphs.arrow, t.Recent.Round(time.Second), t.Runs)
+		}
+	}
+	if len(a.Offenders) == 0 {
+		fmt.Fprintf(tw, "\nNo repository failed more than once.\n")
+	} else {
+		fmt.Fprintf(tw, "\nRepeat failures:\n")
+		for _, o := range a.Offenders {
+			fmt.Fprintf(tw, "  %s\tfailed %d of %d runs\tlast on %s with %s errors\n", o.Repository, o.Failures, o.Runs, o.LastFailed.Format(time.DateOnly), o.Category)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands run around each repository and once after the run. They
+// get ORGSYNC_* environment variables describing what was synchronized.
+type Hooks struct {
+	// PreRepo runs before a repository is synchronized; if it fails the repository
+	// is not synchronized and fails
+	PreRepo string `json:"pre_repo,omitempty"`
+	// PostRepo runs after a repository was synchronized, whatever the outcome. A
+	// failure is reported as a warning.
+	PostRepo string `json:"post_repo,omitempty"`
+	// PostRun runs once after the whole run
+	PostRun string `json:"post_run,omitempty"`
+}
+
+// maxHookOutput bounds how much of a failed hook's output is kept in its error
+const maxHookOutput = 500
+
+// outcome returns the report status for a repository that finished with err
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return StatusSuccess
+	case errors.Is(err, ErrCancelled):
+		return StatusCancelled
+	case errors.Is(err, ErrConflict):
+		return StatusConflict
+	case SkipReason(err) != "":
+		return StatusSkipped
+	default:
+		return StatusFailed
+	}
+}
+
+// runRepoHook runs a pre_repo or post_repo hook for repo. It runs inside the clone when
+// there is one, and in the sync root otherwise. status is empty before the sync.
+func runRepoHook(ctx context.Context, name, command string, opts Options, repo Repository, status string) error {
+	path, err := filepath.Abs(opts.repoDir(repo))
+	if err != nil {
+		return fmt.Errorf("failed to resolve the path of %s: %w", repo.Name, err)
+	}
+	dir := ""
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		dir = path
+	}
+	env := []string{
+		"ORGSYNC_OWNER=" + repo.Owner,
+		"ORGSYNC_REPO=" + repo.Name,
+		"ORGSYNC_PATH=" + path,
+		"ORGSYNC_STATUS=" + status,
+	}
+	return runHook(ctx, name, command, dir, env)
+}
+
+// RunPostRunHook runs the post_run hook, if any, in the sync root once the run is over.
+// ORGSYNC_STATUS is "success" when no repository failed, "failed" otherwise, and
+// "interrupted" when some were never synchronized. reportFile is passed on as
+// ORGSYNC_REPORT and may be empty.
+func RunPostRunHook(hooks Hooks, report Report, reportFile string) error {
+	if hooks.PostRun == "" {
+		return nil
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to resolve the sync root: %w", err)
+	}
+	if reportFile != "" {
+		if reportFile, err = filepath.Abs(reportFile); err != nil {
+			return fmt.Errorf("failed to resolve the report path: %w", err)
+		}
+	}
+
+	t := report.Totals
+	status := "success"
+	switch {
+	case t.Failed+t.Conflicts > 0:
+		status = "failed"
+	case t.Cancelled+t.Pending > 0:
+		status = "interrupted"
+	}
+	env := []string{
+		"ORGSYNC_RUN_ID=" + report.RunID,
+		"ORGSYNC_PATH=" + root,
+		"ORGSYNC_STATUS=" + status,
+		"ORGSYNC_SUCCEEDED=" + strconv.Itoa(t.Succeeded),
+		"ORGSYNC_FAILED=" + strconv.Itoa(t.Failed+t.Conflicts),
+		"ORGSYNC_REPORT=" + reportFile,
+	}
+	return runHook(context.Background(), "post_run", hooks.PostRun, "", env)
+}
+
+// runHook runs command with sh in dir, adding env to the environment
+func runHook(ctx context.Context, name, command, dir string, env []string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	started := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(slog.LevelInfo, cmd, started, err, strings.TrimSpace(string(out)))
+	if err != nil {
+		output := strings.TrimSpace(string(out))
+		if len(output) > maxHookOutput {
+			output = "..." + output[len(output)-maxHookOutput:]
+		}
+		if output != "" {
+			return fmt.Errorf("%s hook failed: %w: %s", name, err, output)
+		}
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
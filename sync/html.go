@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+func init() {
+	RegisterFormatter("html", FormatterFunc(writeHTML))
+}
+
+// htmlTemplate renders a self-contained page that can be attached to a CI run or mailed
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes":    FormatBytes,
+	"duration": func(s float64) time.Duration { return seconds(s).Round(time.Millisecond) },
+	"time":     func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>orgsync {{.Target}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.success { color: #006400; }
+.failed, .conflict { color: #c00000; }
+.cancelled, .pending { color: #b35900; }
+.ci-success { color: #006400; }
+.ci-failure { color: #c00000; }
+pre { margin: 0.3em 0 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>orgsync {{.Target}}</h1>
+<p>Run {{.RunID}}, started {{time .StartedAt}}, took {{duration .Totals.DurationSeconds}}.</p>
+{{with .Totals}}<p>{{.Repositories}} repositories: {{.Succeeded}} succeeded, {{.Failed}} failed{{if .Conflicts}}, {{.Conflicts}} conflicts{{end}}{{if .Skipped}}, {{.Skipped}} skipped{{end}}{{if .Cancelled}}, {{.Cancelled}} cancelled{{end}}{{if .Pending}}, {{.Pending}} pending{{end}}. Transferred {{bytes .Bytes}}.</p>{{end}}
+<table>
+<tr><th>Repository</th><th>Status</th><th>Duration</th><th>Attempts</th><th>Transferred</th><th>CI</th><th>Details</th></tr>
+{{range .Repositories}}<tr>
+<td>{{if .Owner}}{{.Owner}}/{{end}}{{.Name}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{duration .DurationSeconds}}</td>
+<td>{{.Attempts}}</td>
+<td>{{bytes .Bytes}}</td>
+<td class="ci-{{.CI}}">{{.CI}}</td>
+<td>{{if .Error}}[{{.ErrorCategory}}] {{.Error}}{{if .Hint}}<div>Hint: {{.Hint}}</div>{{end}}{{if .Output}}<pre>{{.Output}}</pre>{{end}}{{end}}{{range .Warnings}}<div>Warning: {{.}}</div>{{end}}</td>
+</tr>
+{{end}}</table>
+{{if .Pruned}}<p>Pruned: {{range $i, $name := .Pruned}}{{if $i}}, {{end}}{{$name}}{{end}}</p>{{end}}
+{{if .Warnings}}<h2>Warnings</h2>
+<ul>{{range .Warnings}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// writeHTML writes the report as a standalone HTML page
+func writeHTML(w io.Writer, r Report) error {
+	if err := htmlTemplate.Execute(w, r); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile lists repositories to leave out of discovery, one gitignore-style
+// pattern per line, so a team can share the list alongside its workspace
+const IgnoreFile = ".orgsyncignore"
+
+// IgnoreRules are the parsed patterns of an ignore file, in file order
+type IgnoreRules []ignoreRule
+
+// ignoreRule is a single line of an ignore file
+type ignoreRule struct {
+	pattern string
+	// negate re-includes repositories an earlier pattern ignored, written as !pattern
+	negate bool
+}
+
+// LoadIgnore reads the ignore file at path. A missing file ignores nothing.
+func LoadIgnore(path string) (IgnoreRules, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	rules, err := ParseIgnore(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseIgnore parses ignore file content. Blank lines and lines starting with # are
+// skipped, a leading ! negates a pattern and a trailing / is ignored. Patterns are
+// case-insensitive globs matched against the repository name, or against owner/name
+// when they contain a slash.
+func ParseIgnore(content string) (IgnoreRules, error) {
+	var rules IgnoreRules
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{pattern: line}
+		if strings.HasPrefix(line, "!") {
+			rule = ignoreRule{pattern: line[1:], negate: true}
+		} else if strings.HasPrefix(line, `\`) {
+			// \# and \! match names starting with those characters, as in gitignore
+			rule.pattern = line[1:]
+		}
+		rule.pattern = strings.ToLower(strings.Trim(rule.pattern, "/"))
+		if _, err := path.Match(rule.pattern, ""); err != nil || rule.pattern == "" {
+			return nil, fmt.Errorf("line %d: invalid pattern %q", i+1, line)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Ignores reports whether repo is excluded. As in gitignore, the last matching
+// pattern decides.
+func (r IgnoreRules) Ignores(repo Repository) bool {
+	name := strings.ToLower(repo.Name)
+	full := strings.ToLower(repo.Owner + "/" + repo.Name)
+	ignored := false
+	for _, rule := range r {
+		subject := name
+		if strings.Contains(rule.pattern, "/") {
+			subject = full
+		}
+		if ok, _ := path.Match(rule.pattern, subject); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// filterIgnored drops the repositories the ignore rules exclude
+func filterIgnored(repos []Repository, rules IgnoreRules) []Repository {
+	if len(rules) == 0 {
+		return repos
+	}
+	var kept []Repository
+	for _, repo := range repos {
+		if !rules.Ignores(repo) {
+			kept = append(kept, repo)
+		}
+	}
+	return kept
+}
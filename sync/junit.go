@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+func init() {
+	RegisterFormatter("junit", FormatterFunc(writeJUnit))
+}
+
+// junitTestSuites is the root of a JUnit XML document, as understood by most CI systems
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	ID        string          `xml:"id,attr,omitempty"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the report as JUnit XML, with each repository as a test case
+func writeJUnit(w io.Writer, r Report) error {
+	suite := junitTestSuite{
+		ID:        r.RunID,
+		Name:      "orgsync " + r.Target,
+		Tests:     r.Totals.Repositories,
+		Failures:  r.Totals.Failed + r.Totals.Conflicts,
+		Skipped:   r.Totals.Pending + r.Totals.Cancelled + r.Totals.Skipped,
+		Time:      fmt.Sprintf("%.3f", r.Totals.DurationSeconds),
+		Timestamp: r.StartedAt.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, repo := range r.Repositories {
+		classname := repo.Owner
+		if classname == "" {
+			classname = r.Target
+		}
+		tc := junitTestCase{
+			Name:      repo.Name,
+			Classname: classname,
+			Time:      fmt.Sprintf("%.3f", repo.DurationSeconds),
+		}
+		switch repo.Status {
+		case StatusFailed, StatusConflict:
+			output := repo.Output
+			if output == "" {
+				output = repo.Error
+			}
+			tc.Failure = &junitFailure{Message: repo.Error, Type: repo.ErrorCategory, Output: output}
+		case StatusPending, StatusCancelled:
+			tc.Skipped = &junitSkipped{Message: "not synchronized"}
+		case StatusSkipped:
+			tc.Skipped = &junitSkipped{Message: repo.Error}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LastRunFile records the most recent invocation in the sync root for `orgsync rerun`
+const LastRunFile = ".orgsync-last-run.json"
+
+// LastRun captures the effective settings and outcome of a finished run
+type LastRun struct {
+	RunID        string    `json:"run_id,omitempty"`
+	Options      Options   `json:"options"`
+	ReportFormat string    `json:"report_format,omitempty"`
+	ReportFile   string    `json:"report_file,omitempty"`
+	MaxFailures  int       `json:"max_failures,omitempty"`
+	Failed       []string  `json:"failed,omitempty"`
+	FinishedAt   time.Time `json:"finished_at"`
+}
+
+// SaveLastRun writes the last run record to path
+func SaveLastRun(path string, run LastRun) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode last run: %w", err)
+	}
+	if err := WriteFileAtomic(path, data, 0o644, run.Options.Fsync); err != nil {
+		return fmt.Errorf("failed to save last run: %w", err)
+	}
+	return nil
+}
+
+// LoadLastRun reads the last run record from path
+func LoadLastRun(path string) (LastRun, error) {
+	var run LastRun
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return run, fmt.Errorf("failed to read last run: %w", err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("failed to parse last run: %w", err)
+	}
+	return run, nil
+}
+
+// Failed returns the repositories that failed to sync, as owner/name. Skipped repositories,
+// e.g. with local changes, did not fail.
+func (s State) Failed() []string {
+	var failed []string
+	for _, repo := range s.Repositories {
+		if repo.Err != nil && SkipReason(repo.Err) == "" {
+			failed = append(failed, repo.Key())
+		}
+	}
+	return failed
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultLayout keeps every clone directly in the sync root
+const DefaultLayout = "{repo}"
+
+// unknownLanguage stands in for {language} when GitHub detected none
+const unknownLanguage = "unknown"
+
+// layoutVariable matches the {name} placeholders of a layout template
+var layoutVariable = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// ValidateLayout returns an error unless layout is a relative path template using only
+// {owner} (or its alias {org}), {language} and {repo}, with {repo} in its last element
+func ValidateLayout(layout string) error {
+	if filepath.IsAbs(layout) {
+		return fmt.Errorf("layout %q must be relative to the sync root", layout)
+	}
+	for _, match := range layoutVariable.FindAllStringSubmatch(layout, -1) {
+		switch match[1] {
+		case "owner", "org", "language", "repo":
+		default:
+			return fmt.Errorf("layout %q: unknown variable %s (expected {owner}, {org}, {language} or {repo})", layout, match[0])
+		}
+	}
+	if !strings.Contains(filepath.Base(layout), "{repo}") {
+		return fmt.Errorf("layout %q must end with an element containing {repo}", layout)
+	}
+	for _, element := range strings.Split(filepath.ToSlash(layout), "/") {
+		if element == ".." {
+			return fmt.Errorf("layout %q must stay inside the sync root", layout)
+		}
+	}
+	return nil
+}
+
+// expandLayout fills in the layout template for the given owner, language and name
+func expandLayout(layout, owner, language, name string) string {
+	if layout == "" {
+		layout = DefaultLayout
+	}
+	if language == "" {
+		language = unknownLanguage
+	}
+	return filepath.FromSlash(strings.NewReplacer(
+		"{owner}", owner,
+		"{org}", owner,
+		"{language}", language,
+		"{repo}", name,
+	).Replace(layout))
+}
+
+// layoutSeparatesOwners reports whether layout puts the clones of different owners in
+// different directories
+func layoutSeparatesOwners(layout string) bool {
+	return strings.Contains(layout, "{owner}") || strings.Contains(layout, "{org}")
+}
+
+// layoutPatterns returns glob patterns, relative to the sync root, matching every
+// directory the layout places repositories of the given owners in
+func layoutPatterns(layout string, owners []string) []string {
+	patterns := make([]string, 0, len(owners))
+	seen := make(map[string]bool)
+	for _, owner := range owners {
+		pattern := filepath.Join(".", expandLayout(strings.ReplaceAll(layout, "{language}", "*"), owner, "", "*"))
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
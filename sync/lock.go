@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFile marks a sync root as in use by a running orgsync
+const LockFile = ".orgsync.lock"
+
+// Lock describes the orgsync process holding a sync root
+type Lock struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ErrLocked is returned when another orgsync is already running in the sync root
+var ErrLocked = errors.New("another orgsync is running in this directory")
+
+// AcquireLock claims the sync root dir for this process, so that two runs never fetch
+// into the same clones at once. A lock left behind by a process on this host that no
+// longer exists is stale and taken over. The returned function releases the lock.
+func AcquireLock(dir string) (release func(), err error) {
+	path := filepath.Join(dir, LockFile)
+	host, _ := os.Hostname()
+	lock := Lock{PID: os.Getpid(), Host: host, StartedAt: time.Now()}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	// A stale lock is removed and creating it retried once
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, werr := f.Write(data)
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock %s: %w", path, errors.Join(werr, cerr))
+			}
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock %s: %w", path, err)
+		}
+
+		holder, err := readLock(path)
+		if err == nil && !holder.stale(host) {
+			return nil, fmt.Errorf("%w (pid %d on %s, started %s); remove %s if it is no longer running",
+				ErrLocked, holder.PID, holder.Host, holder.StartedAt.Format(time.DateTime), path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale lock %s: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s keeps reappearing", ErrLocked, path)
+}
+
+// readLock loads the lock at path. A lock that cannot be parsed, such as one cut short
+// by a crash while it was written, is reported as an error and treated as stale.
+func readLock(path string) (Lock, error) {
+	var lock Lock
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lock, err
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("failed to parse lock %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// stale reports whether the lock was left behind by a process that no longer runs.
+// Processes on other hosts, e.g. sharing the directory over NFS, cannot be checked
+// and are assumed to be alive.
+func (l Lock) stale(host string) bool {
+	if l.Host != host {
+		return false
+	}
+	return l.PID <= 0 || !processAlive(l.PID)
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package sync
+
+// processAlive cannot tell on this platform, so a lock is never taken over as stale
+func processAlive(pid int) bool {
+	return true
+}
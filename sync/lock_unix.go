@@ -0,0 +1,21 @@
+//go:build unix
+
+package sync
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processAlive reports whether the process pid runs on this host
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 checks for existence without affecting the process; EPERM means it
+	// exists but belongs to another user
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
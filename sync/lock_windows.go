@@ -0,0 +1,28 @@
+//go:build windows
+
+package sync
+
+import (
+	"errors"
+	"syscall"
+)
+
+// stillActive is the exit code Windows reports for a process that has not exited
+const stillActive = 259
+
+// processAlive reports whether the process pid runs on this host. Windows has no
+// signal 0, so the process is opened and asked for its exit code instead.
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access is denied to the processes of other users, which do exist; any other
+		// error means there is no such process
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// logger receives structured records of every command, retry and status change. It
+// discards them until SetLogger is called, as the terminal UI owns the screen, but
+// keeps the latest for the log pane either way.
+var logger = slog.New(&tailHandler{next: discardHandler{}, tail: recentLogs})
+
+// SetLogger directs the records of the sync engine to l, e.g. a --log-file. It must be
+// called before the first run starts.
+func SetLogger(l *slog.Logger) {
+	logger = slog.New(&tailHandler{next: l.Handler(), tail: recentLogs})
+}
+
+// Logger returns the logger of the sync engine, for frontends to write their records
+// where the engine writes its own
+func Logger() *slog.Logger {
+	return logger
+}
+
+// discardHandler drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logCommand records a finished git or gh command at level, or as a warning with its
+// output when it failed
+func logCommand(level slog.Level, cmd *exec.Cmd, started time.Time, err error, output string) {
+	attrs := []any{"command", strings.Join(cmd.Args, " "), "duration", time.Since(started)}
+	if cmd.Dir != "" {
+		attrs = append(attrs, "dir", cmd.Dir)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+		if output != "" {
+			attrs = append(attrs, "output", output)
+		}
+		level = max(level, slog.LevelWarn)
+	}
+	logger.Log(context.Background(), level, "command finished", attrs...)
+}
+
+// repoAttr identifies a repository in log records
+func repoAttr(repo Repository) slog.Attr {
+	return slog.String("repo", repo.Key())
+}
+
+// logRepositoryFinished records the outcome of a repository, as an error if it failed
+func logRepositoryFinished(repo Repository, err error) {
+	status := outcome(err)
+	attrs := []any{repoAttr(repo), "status", status, "attempts", repo.Attempts, "duration", repo.FinishedAt.Sub(repo.StartedAt)}
+	level := slog.LevelInfo
+	if err != nil {
+		attrs = append(attrs, "category", ClassifyError(err), "error", err)
+	}
+	if status == StatusFailed || status == StatusConflict {
+		level = slog.LevelError
+	}
+	if repo.UpToDate {
+		attrs = append(attrs, "up_to_date", true)
+	}
+	logger.Log(context.Background(), level, "repository finished", attrs...)
+}
+
+// logStarted records the start of a run
+func (s State) logStarted() {
+	logger.Info("run started", "run_id", s.RunID, "target", s.Options.label(), "only", len(s.Options.Only), "resumed", len(s.Options.Completed))
+}
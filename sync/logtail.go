@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// maxLogTail is how many records are kept for RecentLogs
+const maxLogTail = 200
+
+// logTail keeps the latest log records of the sync engine as lines of text, at info
+// level and above, whether or not they are written anywhere else
+type logTail struct {
+	mu    gosync.Mutex
+	lines []string
+}
+
+// recentLogs holds the records returned by RecentLogs
+var recentLogs = &logTail{}
+
+// RecentLogs returns up to n of the latest records the engine logged at info level and
+// above, oldest first, as lines of text, e.g. for the log pane of the terminal UI. They
+// are kept whether or not SetLogger has them written anywhere.
+func RecentLogs(n int) []string {
+	return recentLogs.last(n)
+}
+
+// add keeps a line, dropping the oldest once there are too many
+func (t *logTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, line)
+	if len(t.lines) > maxLogTail {
+		t.lines = append(t.lines[:0], t.lines[len(t.lines)-maxLogTail:]...)
+	}
+}
+
+// last returns up to n of the latest lines, oldest first
+func (t *logTail) last(n int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.lines[max(len(t.lines)-n, 0):]...)
+}
+
+// tailHandler passes records on to the handler of the logger set with SetLogger and
+// keeps them in a logTail as well
+type tailHandler struct {
+	next slog.Handler
+	tail *logTail
+	// attrs were added with WithAttrs, prefixed with the open groups
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h *tailHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || h.next.Enabled(ctx, level)
+}
+
+func (h *tailHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelInfo {
+		h.tail.add(h.format(r))
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *tailHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.next = h.next.WithAttrs(attrs)
+	next.attrs = append(append([]slog.Attr(nil), h.attrs...), h.grouped(attrs)...)
+	return &next
+}
+
+func (h *tailHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.next = h.next.WithGroup(name)
+	next.groups = append(append([]string(nil), h.groups...), name)
+	return &next
+}
+
+// grouped prefixes the keys of attrs with the open groups
+func (h *tailHandler) grouped(attrs []slog.Attr) []slog.Attr {
+	if len(h.groups) == 0 {
+		return attrs
+	}
+	prefix := strings.Join(h.groups, ".") + "."
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = slog.Attr{Key: prefix + a.Key, Value: a.Value}
+	}
+	return out
+}
+
+// format renders a record on one line, e.g.
+// "12:04:05 WARN retrying repository repo=api attempt=1 wait=2s"
+func (h *tailHandler) format(r slog.Record) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", r.Time.Format(time.TimeOnly), r.Level, r.Message)
+	attrs := h.attrs
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, h.grouped([]slog.Attr{a})...)
+		return true
+	})
+	for _, a := range attrs {
+		value := strings.Join(strings.Fields(a.Value.String()), " ")
+		fmt.Fprintf(&b, " %s=%s", a.Key, value)
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
+)
+
+var (
+	// noMaintenance is set once git turns out to be older than `git maintenance`,
+	// which came with git 2.29, so that `git gc --auto` is run straight away
+	noMaintenance atomic.Bool
+	// registerMu serializes `git maintenance register`, as every registration writes
+	// the same global git config file and concurrent ones fail to lock it
+	registerMu gosync.Mutex
+)
+
+// maintainRepository runs git's housekeeping in the clone at repoDir unless it was
+// already up to date, so that clones fetched forever do not pile up loose objects. The
+// --auto variants only do work once enough has accumulated. With register, the clone is
+// also added to those `git maintenance` looks after in the background. A failure is
+// only a warning, since the clone itself is fine.
+func maintainRepository(ctx context.Context, repoDir string, register bool, progress *progressWriter) {
+	maintain := &progressWriter{protectWorktree: progress.protectWorktree, transcript: progress.transcript}
+	defer func() { progress.commands = append(progress.commands, maintain.commands...) }()
+
+	if !progress.upToDate {
+		if err := runMaintenance(ctx, repoDir, maintain); err != nil {
+			progress.warnings = append(progress.warnings, fmt.Sprintf("git maintenance failed: %v", newCommandError(err, maintain)))
+			return
+		}
+	}
+	if register && !noMaintenance.Load() {
+		registerMu.Lock()
+		defer registerMu.Unlock()
+		cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "maintenance", "register")
+		if err := runCommand(cmd, maintain); err != nil {
+			progress.warnings = append(progress.warnings, fmt.Sprintf("failed to register for git maintenance: %v", newCommandError(err, maintain)))
+		}
+	}
+}
+
+// runMaintenance runs `git maintenance run --auto`, or `git gc --auto` with a git too
+// old for the former
+func runMaintenance(ctx context.Context, repoDir string, progress *progressWriter) error {
+	if !noMaintenance.Load() {
+		cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "maintenance", "run", "--auto", "--quiet")
+		err := runCommand(cmd, progress)
+		if err == nil || !strings.Contains(progress.Output(), "is not a git command") {
+			return err
+		}
+		noMaintenance.Store(true)
+		logger.Info("git maintenance is not available, running git gc instead")
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "gc", "--auto", "--quiet")
+	return runCommand(cmd, progress)
+}
@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// ManifestFile is the inventory of the clones in the sync root, for other tools such as
+// code search or backup verification
+const ManifestFile = "orgsync-manifest.json"
+
+// Manifest and ManifestEntry are defined in the schema package
+type (
+	Manifest      = schema.Manifest
+	ManifestEntry = schema.ManifestEntry
+)
+
+// LoadManifest reads the manifest from path
+func LoadManifest(path string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := schema.Check(manifest.Schema, schema.ManifestSchema); err != nil {
+		return manifest, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// WriteManifest updates the manifest at path with the clones of this run. Entries
+// from earlier runs are kept while their clone is still on disk, so repositories this
+// run filtered out stay listed, and dropped once it is gone, e.g. after --prune. A
+// repository that did not sync successfully keeps its previous sync time.
+func (s State) WriteManifest(path string) error {
+	previous, err := LoadManifest(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// The manifest is derived data; a damaged one is rebuilt from this run
+		previous = Manifest{}
+	}
+	entries := make(map[string]ManifestEntry, len(previous.Repositories))
+	for _, entry := range previous.Repositories {
+		if _, err := os.Stat(filepath.FromSlash(entry.Path)); err == nil {
+			entries[entry.Path] = entry
+		}
+	}
+
+	for _, repo := range s.Repositories {
+		dir := s.Options.repoDir(repo)
+		key := filepath.ToSlash(filepath.Clean(dir))
+		if _, err := os.Stat(dir); err != nil {
+			delete(entries, key)
+			continue
+		}
+		entry := ManifestEntry{
+			Owner:         repo.Owner,
+			Name:          repo.Name,
+			Path:          key,
+			DefaultBranch: repo.DefaultBranch,
+			Size:          repo.Size,
+			SyncedAt:      entries[key].SyncedAt,
+		}
+		if repo.Done && repo.Err == nil {
+			entry.SyncedAt = repo.FinishedAt
+		}
+		entries[key] = entry
+	}
+
+	manifest := Manifest{
+		Schema:       schema.ManifestSchema,
+		RunID:        s.RunID,
+		UpdatedAt:    time.Now(),
+		Repositories: make([]ManifestEntry, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		// The checked-out commit can change between runs, so it is always read afresh
+		entry.Head, _ = gitOutput(context.Background(), filepath.FromSlash(entry.Path), "rev-parse", "--verify", "--quiet", "HEAD")
+		manifest.Repositories = append(manifest.Repositories, entry)
+	}
+	sort.Slice(manifest.Repositories, func(i, j int) bool {
+		return manifest.Repositories[i].Path < manifest.Repositories[j].Path
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := WriteFileAtomic(path, data, 0o644, s.Options.Fsync); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// FormatManifest renders the manifest as "text", an aligned table of the clones, or
+// as indented "json"
+func FormatManifest(m Manifest, format string) (string, error) {
+	switch format {
+	case "text":
+		var b strings.Builder
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PATH\tBRANCH\tHEAD\tSIZE\tSYNCED")
+		for _, entry := range m.Repositories {
+			synced := "never"
+			if !entry.SyncedAt.IsZero() {
+				synced = entry.SyncedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.7s\t%s\t%s\n", entry.Path, entry.DefaultBranch, entry.Head, FormatBytes(entry.Size), synced)
+		}
+		w.Flush()
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	case "json":
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unknown list format %q", format)
+	}
+}
@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFormatter("markdown", FormatterFunc(writeMarkdown))
+}
+
+// slowestRepositories is how many repositories the Markdown report lists as slowest
+const slowestRepositories = 5
+
+// markdownCell escapes text for a cell of a Markdown table, which ends at a newline
+// and between pipes
+var markdownCell = strings.NewReplacer("|", `\|`, "\r", "", "\n", " ")
+
+// writeMarkdown writes a summary for people rather than programs: the totals, every
+// failure with its error, the slowest repositories and a table of all of them, ready
+// to paste into a pull request or a chat
+func writeMarkdown(w io.Writer, r Report) error {
+	var b strings.Builder
+	t := r.Totals
+	fmt.Fprintf(&b, "## orgsync %s\n\n", r.Target)
+	fmt.Fprintf(&b, "Run `%s`, started %s, took %s.\n\n", r.RunID, r.StartedAt.Format(time.RFC3339), seconds(t.DurationSeconds).Round(time.Second))
+
+	for _, err := range r.Errors {
+		fmt.Fprintf(&b, "> **Error:** %s\n\n", err)
+	}
+
+	b.WriteString("| Repositories | Succeeded | Up to date | Failed | Skipped | Not synchronized | Transferred |\n")
+	b.WriteString("|---:|---:|---:|---:|---:|---:|---:|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d | %s |\n\n", t.Repositories, t.Succeeded, t.UpToDate, t.Failed+t.Conflicts, t.Skipped, t.Cancelled+t.Pending, FormatBytes(t.Bytes))
+
+	var failures []RepositoryReport
+	for _, repo := range r.Repositories {
+		if repo.Status == StatusFailed || repo.Status == StatusConflict {
+			failures = append(failures, repo)
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(&b, "### Failures (%d)\n\n", len(failures))
+		for _, repo := range failures {
+			fmt.Fprintf(&b, "- **%s** (%s): %s\n", repo.Key(), repo.ErrorCategory, strings.ReplaceAll(repo.Error, "\n", " "))
+			if repo.Hint != "" {
+				fmt.Fprintf(&b, "  Hint: %s\n", repo.Hint)
+			}
+			if repo.Output != "" {
+				fmt.Fprintf(&b, "  ```text\n  %s\n  ```\n", strings.ReplaceAll(repo.Output, "\n", "\n  "))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	finished := slices.DeleteFunc(slices.Clone(r.Repositories), func(repo RepositoryReport) bool {
+		return repo.DurationSeconds == 0
+	})
+	slices.SortStableFunc(finished, func(a, b RepositoryReport) int {
+		return cmp.Compare(b.DurationSeconds, a.DurationSeconds)
+	})
+	if len(finished) > slowestRepositories {
+		finished = finished[:slowestRepositories]
+	}
+	if len(finished) > 0 {
+		b.WriteString("### Slowest repositories\n\n")
+		b.WriteString("| Repository | Duration | Size | Transferred | Attempts |\n")
+		b.WriteString("|---|---:|---:|---:|---:|\n")
+		for _, repo := range finished {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %d |\n", markdownCell.Replace(repo.Key()), seconds(repo.DurationSeconds).Round(time.Millisecond), FormatBytes(repo.Size), FormatBytes(repo.Bytes), repo.Attempts)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Pruned) > 0 {
+		fmt.Fprintf(&b, "**Pruned:** %s\n\n", markdownCell.Replace(strings.Join(r.Pruned, ", ")))
+	}
+	if len(r.Transferred) > 0 {
+		fmt.Fprintf(&b, "**Transferred to other owners:** %s\n\n", markdownCell.Replace(strings.Join(r.Transferred, ", ")))
+	}
+	if t.Warnings > 0 {
+		fmt.Fprintf(&b, "**Warnings:** %d\n\n", t.Warnings)
+	}
+
+	// Long listings stay out of the way until expanded where Markdown allows HTML
+	if len(r.Repositories) > 0 {
+		fmt.Fprintf(&b, "<details>\n<summary>All %d repositories</summary>\n\n", len(r.Repositories))
+		b.WriteString("| Repository | Status | Duration | Attempts | Transferred | Error |\n")
+		b.WriteString("|---|---|---:|---:|---:|---|\n")
+		for _, repo := range r.Repositories {
+			status := repo.Status
+			if repo.UpToDate {
+				status += " (up to date)"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %s | %s |\n", markdownCell.Replace(repo.Key()), status, seconds(repo.DurationSeconds).Round(time.Millisecond), repo.Attempts, FormatBytes(repo.Bytes), markdownCell.Replace(repo.Error))
+		}
+		b.WriteString("\n</details>\n")
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrTooLarge marks a repository that was skipped because the disk usage GitHub reports
+// for it exceeds Options.MaxSize
+var ErrTooLarge = errors.New("too large")
+
+// byteUnits maps the suffixes accepted by ParseByteSize to their multipliers. Like
+// FormatBytes they are binary, so "1GB" is 1024 MB.
+var byteUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// ParseByteSize converts a size such as "500MB", "1.5G" or "1048576" into bytes
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	value, unit := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("size %q must be a positive number followed by an optional unit, e.g. 500MB", s)
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("size %q: unknown unit %q (expected B, KB, MB, GB or TB)", s, s[i:])
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// checkSize returns ErrTooLarge if repo is larger than maxSize. A maxSize of 0 allows
+// any size.
+func checkSize(repo Repository, maxSize int64) error {
+	if maxSize <= 0 || repo.Size <= maxSize {
+		return nil
+	}
+	return fmt.Errorf("%w: %s exceeds --max-size %s", ErrTooLarge, FormatBytes(repo.Size), FormatBytes(maxSize))
+}
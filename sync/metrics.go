@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the repository sync duration
+// histogram
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 900, 1800}
+
+// Metrics accumulates the outcome of the runs of one program, as in watch mode, and
+// serves them in the Prometheus text format. As a Subscriber it observes every run it
+// is subscribed to. Observe must not be called concurrently; serving may happen at
+// any time.
+type Metrics struct {
+	current atomic.Pointer[metricValues]
+}
+
+// metricValues are the values of every metric at one point in time. They are never
+// modified once published, so that scrapes need no locking.
+type metricValues struct {
+	runs           int
+	lastRun        time.Time
+	lastRunSeconds float64
+	lastRunFailed  int
+	repositories   map[string]int
+	failures       map[string]int
+	bytes          int64
+	// buckets counts durations up to each of durationBuckets; the last element counts
+	// all of them
+	buckets       []int
+	durationSum   float64
+	durationCount int
+}
+
+func (m *Metrics) OnRepoStart(Repository)                 {}
+func (m *Metrics) OnRepoProgress(string, float64, string) {}
+func (m *Metrics) OnRepoDone(Repository)                  {}
+
+// OnRunComplete observes the finished run
+func (m *Metrics) OnRunComplete(r Report) {
+	m.Observe(r)
+}
+
+// Observe adds the finished run in r to the metrics
+func (m *Metrics) Observe(r Report) {
+	v := metricValues{repositories: make(map[string]int), failures: make(map[string]int), buckets: make([]int, len(durationBuckets)+1)}
+	if old := m.current.Load(); old != nil {
+		v = *old
+		v.repositories = make(map[string]int, len(old.repositories))
+		for status, n := range old.repositories {
+			v.repositories[status] = n
+		}
+		v.failures = make(map[string]int, len(old.failures))
+		for category, n := range old.failures {
+			v.failures[category] = n
+		}
+		v.buckets = append([]int{}, old.buckets...)
+	}
+
+	v.runs++
+	v.lastRun = r.FinishedAt
+	v.lastRunSeconds = r.Totals.DurationSeconds
+	v.lastRunFailed = r.Totals.Failed + r.Totals.Conflicts
+	v.bytes += r.Totals.Bytes
+	for _, repo := range r.Repositories {
+		if repo.Status == StatusPending {
+			continue
+		}
+		v.repositories[repo.Status]++
+		if repo.Status == StatusFailed || repo.Status == StatusConflict {
+			v.failures[repo.ErrorCategory]++
+		}
+		if repo.Status == StatusCancelled {
+			continue
+		}
+		for i, bound := range durationBuckets {
+			if repo.DurationSeconds <= bound {
+				v.buckets[i]++
+			}
+		}
+		v.buckets[len(durationBuckets)]++
+		v.durationSum += repo.DurationSeconds
+		v.durationCount++
+	}
+	m.current.Store(&v)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write renders the metrics. Before the first run there is nothing but the run count.
+func (m *Metrics) write(w io.Writer) {
+	v := m.current.Load()
+	if v == nil {
+		v = &metricValues{buckets: make([]int, len(durationBuckets)+1)}
+	}
+
+	metric(w, "orgsync_runs_total", "counter", "Runs finished since orgsync started.")
+	fmt.Fprintf(w, "orgsync_runs_total %d\n", v.runs)
+	if v.runs == 0 {
+		return
+	}
+	metric(w, "orgsync_last_run_timestamp_seconds", "gauge", "When the last run finished, in seconds since the epoch.")
+	fmt.Fprintf(w, "orgsync_last_run_timestamp_seconds %d\n", v.lastRun.Unix())
+	metric(w, "orgsync_last_run_duration_seconds", "gauge", "How long the last run took.")
+	fmt.Fprintf(w, "orgsync_last_run_duration_seconds %s\n", formatFloat(v.lastRunSeconds))
+	metric(w, "orgsync_last_run_failed_repositories", "gauge", "Repositories that failed in the last run.")
+	fmt.Fprintf(w, "orgsync_last_run_failed_repositories %d\n", v.lastRunFailed)
+
+	metric(w, "orgsync_repositories_total", "counter", "Repositories processed, by outcome.")
+	for _, status := range sortedKeys(v.repositories) {
+		fmt.Fprintf(w, "orgsync_repositories_total{status=%q} %d\n", status, v.repositories[status])
+	}
+	metric(w, "orgsync_failures_total", "counter", "Failed repositories, by error category.")
+	for _, category := range sortedKeys(v.failures) {
+		fmt.Fprintf(w, "orgsync_failures_total{category=%q} %d\n", category, v.failures[category])
+	}
+	metric(w, "orgsync_received_bytes_total", "counter", "Bytes git reported receiving.")
+	fmt.Fprintf(w, "orgsync_received_bytes_total %d\n", v.bytes)
+
+	metric(w, "orgsync_sync_duration_seconds", "histogram", "Time taken to synchronize a repository, including retries.")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "orgsync_sync_duration_seconds_bucket{le=%q} %d\n", formatFloat(bound), v.buckets[i])
+	}
+	fmt.Fprintf(w, "orgsync_sync_duration_seconds_bucket{le=\"+Inf\"} %d\n", v.buckets[len(durationBuckets)])
+	fmt.Fprintf(w, "orgsync_sync_duration_seconds_sum %s\n", formatFloat(v.durationSum))
+	fmt.Fprintf(w, "orgsync_sync_duration_seconds_count %d\n", v.durationCount)
+}
+
+// metric writes the HELP and TYPE lines introducing a metric
+func metric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatFloat renders a sample value the way Prometheus expects it
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of m in order, so that scrapes are stable
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
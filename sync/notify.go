@@ -0,0 +1,220 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// When a run is notified about
+const (
+	NotifyFailure = "failure"
+	NotifyAlways  = "always"
+)
+
+// Notify posts a summary of finished runs to a chat webhook, such as a Slack or Discord
+// incoming webhook
+type Notify struct {
+	// URL is the webhook the message is posted to
+	URL string `json:"url"`
+	// Template is a text/template rendering the message from the run's Report. It
+	// defaults to the totals followed by the failed repositories.
+	Template string `json:"template,omitempty"`
+	// On is NotifyFailure (the default) to only post about runs that had failures or
+	// errors, or NotifyAlways
+	On string `json:"on,omitempty"`
+	// ChangesOnly posts only when repositories start failing or recover, from the
+	// second run of watch mode on, so that flapping repositories do not post every run.
+	// On still applies: with NotifyFailure, a run in which repositories only recover
+	// and nothing fails is not posted.
+	ChangesOnly bool `json:"changes_only,omitempty"`
+	// Digest is a time of day such as "09:00" after which the next run posts a full
+	// summary, once a day, whether or not anything changed or failed
+	Digest string `json:"digest,omitempty"`
+}
+
+// defaultNotifyTemplate summarizes a run in a few lines of chat
+const defaultNotifyTemplate = `orgsync {{.Target}}: {{.Totals.Succeeded}} of {{.Totals.Repositories}} repositories synced, {{failed .}} failed` +
+	`{{if .Totals.Skipped}}, {{.Totals.Skipped}} skipped{{end}}{{if .Totals.Pending}}, {{.Totals.Pending}} not synced{{end}} in {{duration .Totals.DurationSeconds}}` +
+	`{{range .Errors}}
+Error: {{.}}{{end}}{{if changed}}{{range newFailures}}
+Now failing: {{.Name}} [{{.ErrorCategory}}]: {{.Error}}{{end}}{{range recovered}}
+Recovered: {{.Name}}{{end}}{{else}}{{range failures .}}
+- {{.Name}} [{{.ErrorCategory}}]: {{.Error}}{{end}}{{end}}`
+
+// maxNotifyFailures bounds how many failed repositories the failures function lists
+const maxNotifyFailures = 10
+
+// discordLimit is the longest message Discord webhooks accept
+const discordLimit = 2000
+
+// notifyTimeout bounds how long posting a notification may take
+const notifyTimeout = 10 * time.Second
+
+// notifyFuncs are available to notification templates
+var notifyFuncs = template.FuncMap{
+	"bytes":    FormatBytes,
+	"duration": func(s float64) time.Duration { return seconds(s).Round(time.Second) },
+	// failed counts failed and conflicting repositories
+	"failed": func(r Report) int { return r.Totals.Failed + r.Totals.Conflicts },
+	// failures lists the first failed and conflicting repositories
+	"failures": func(r Report) []RepositoryReport {
+		var failures []RepositoryReport
+		for _, repo := range r.Repositories {
+			if (repo.Status == StatusFailed || repo.Status == StatusConflict) && len(failures) < maxNotifyFailures {
+				failures = append(failures, repo)
+			}
+		}
+		return failures
+	},
+}
+
+// Validate returns an error if the notification cannot be sent as configured
+func (n Notify) Validate() error {
+	if n.URL == "" {
+		if n.Template != "" || n.On != "" || n.ChangesOnly || n.Digest != "" {
+			return fmt.Errorf("url is required")
+		}
+		return nil
+	}
+	u, err := url.Parse(n.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("url %q must be an http or https URL", n.URL)
+	}
+	switch n.On {
+	case "", NotifyFailure, NotifyAlways:
+	default:
+		return fmt.Errorf("unknown value %q for on (expected %s or %s)", n.On, NotifyFailure, NotifyAlways)
+	}
+	if n.Digest != "" {
+		if _, err := time.Parse(digestLayout, n.Digest); err != nil {
+			return fmt.Errorf("digest %q must be a time of day such as 09:00", n.Digest)
+		}
+	}
+	if _, err := n.template(nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// template parses the message template. changes are what the changed, newFailures and
+// recovered functions report; nil for a notification about the whole run.
+func (n Notify) template(changes *FailureChanges) (*template.Template, error) {
+	text := n.Template
+	if text == "" {
+		text = defaultNotifyTemplate
+	}
+	if changes == nil {
+		changes = &FailureChanges{}
+	}
+	tmpl, err := template.New("notify").Funcs(notifyFuncs).Funcs(template.FuncMap{
+		"changed":     func() bool { return len(changes.Failed)+len(changes.Recovered) > 0 },
+		"newFailures": func() []RepositoryReport { return changes.Failed },
+		"recovered":   func() []RepositoryReport { return changes.Recovered },
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SendNotification posts the message for the run in r to the webhook. Nothing is sent
+// without a URL, or when only failures are notified and the run had none.
+func SendNotification(n Notify, r Report) error {
+	if n.URL == "" {
+		return nil
+	}
+	if !n.notifies(r) {
+		return nil
+	}
+	return n.post(r, nil)
+}
+
+// notifies reports whether On has the run in r posted about
+func (n Notify) notifies(r Report) bool {
+	failed := r.Totals.Failed+r.Totals.Conflicts > 0 || len(r.Errors) > 0
+	return n.On == NotifyAlways || failed
+}
+
+// post renders the message for the run in r and posts it to the webhook
+func (n Notify) post(r Report, changes *FailureChanges) error {
+	tmpl, err := n.template(changes)
+	if err != nil {
+		return err
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, r); err != nil {
+		return fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	// Slack and most other webhooks take the message as text, Discord as content
+	payload := map[string]string{"text": message.String()}
+	if u, err := url.Parse(n.URL); err == nil && (strings.HasSuffix(u.Hostname(), "discord.com") || strings.HasSuffix(u.Hostname(), "discordapp.com")) {
+		// Discord counts characters, and cutting bytes could split one
+		content := []rune(message.String())
+		if len(content) > discordLimit {
+			content = append(content[:discordLimit-1], '…')
+		}
+		payload = map[string]string{"content": string(content)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("failed to send notification: %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// Notifier sends the notifications of successive runs of one program, as in watch mode.
+// Its FailureTracker remembers which repositories failed, so that with ChangesOnly it
+// only posts about changes, and when the last digest was posted. Send must not be
+// called concurrently.
+type Notifier struct {
+	Notify  Notify
+	tracker FailureTracker
+	// errors is set when the last run had run-level errors
+	errors bool
+}
+
+// Send posts the notification for the run in r, finished at now
+func (n *Notifier) Send(r Report, now time.Time) error {
+	if n.Notify.URL == "" {
+		return nil
+	}
+	n.tracker.Digest = n.Notify.Digest
+	changes := n.tracker.Record(r, now)
+	hadErrors := n.errors
+	n.errors = len(r.Errors) > 0
+	switch {
+	case changes.Digest:
+		return n.Notify.post(r, nil)
+	case !n.Notify.ChangesOnly || changes.First:
+		return SendNotification(n.Notify, r)
+	case !changes.Changed() && n.errors == hadErrors, !n.Notify.notifies(r):
+		return nil
+	}
+	return n.Notify.post(r, &changes)
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OwnerLayout keeps the clones of each owner in a directory of its own, as runs
+// synchronizing several organizations need
+const OwnerLayout = "{owner}/{repo}"
+
+// AllOwners lists the organizations or users the run synchronizes: Owners when there
+// are several, Owner otherwise
+func (o Options) AllOwners() []string {
+	if len(o.Owners) > 0 {
+		return o.Owners
+	}
+	return []string{o.Owner}
+}
+
+// validateOwners checks the settings of a run synchronizing several organizations,
+// which all share one sync root and one set of filters
+func (o Options) validateOwners() error {
+	if len(o.Owners) <= 1 {
+		return nil
+	}
+	if o.Target != TargetOrg {
+		return errors.New("only organizations can be synchronized several at once")
+	}
+	if o.Team != "" {
+		return errors.New("a team belongs to a single organization and cannot be combined with several")
+	}
+	if !layoutSeparatesOwners(o.Layout) {
+		return fmt.Errorf("layout %q must contain {owner} or {org} to keep several organizations apart", o.Layout)
+	}
+	seen := make(map[string]bool, len(o.Owners))
+	for _, owner := range o.Owners {
+		if owner == "" {
+			return errors.New("organization name must not be empty")
+		}
+		if seen[strings.ToLower(owner)] {
+			return fmt.Errorf("organization %s is given more than once", owner)
+		}
+		seen[strings.ToLower(owner)] = true
+	}
+	return nil
+}
+
+// fetchReposInOrgs lists the repositories of every org, one after the other. listed,
+// if set, is told the pages and repositories listed across all of them.
+func fetchReposInOrgs(orgs []string, stderr *progressWriter, listed func(pages, repos int)) ([]Repository, error) {
+	var all []Repository
+	pagesBefore := 0
+	for _, org := range orgs {
+		var pagesListed int
+		repos, err := fetchReposInOrg(org, stderr, func(pages, repos int) {
+			pagesListed = pages
+			if listed != nil {
+				listed(pagesBefore+pages, len(all)+repos)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", org, err)
+		}
+		all = append(all, repos...)
+		pagesBefore += pagesListed
+	}
+	return all, nil
+}
@@ -0,0 +1,215 @@
+package sync
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of the archives --archive packs repositories into, for Options.Archive
+const (
+	ArchiveTar    = "tar"
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarZst = "tar.zst"
+)
+
+// DefaultArchiveOutput is the folder of the sync root archives are packed into unless
+// Options.ArchiveOutput names another
+const DefaultArchiveOutput = "orgsync-archives"
+
+// headSuffix names the file next to each archive recording the HEAD it was packed at
+const headSuffix = ".head"
+
+// ValidArchiveFormat reports whether format is one of the Archive constants, or empty
+// for packing no archives
+func ValidArchiveFormat(format string) bool {
+	switch format {
+	case "", ArchiveTar, ArchiveTarGz, ArchiveTarZst:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateArchive checks that the archives of the run can be packed, which for
+// tar.zst takes the zstd command
+func (o Options) validateArchive() error {
+	if !ValidArchiveFormat(o.Archive) {
+		return fmt.Errorf("unknown archive format %q", o.Archive)
+	}
+	if o.Archive == ArchiveTarZst {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return fmt.Errorf("packing %s archives needs zstd: %w", ArchiveTarZst, err)
+		}
+	}
+	return nil
+}
+
+// ArchiveFolder returns the folder archives are packed into
+func (o Options) ArchiveFolder() string {
+	if o.ArchiveOutput == "" {
+		return DefaultArchiveOutput
+	}
+	return o.ArchiveOutput
+}
+
+// archivePath returns where the archive of repo goes: its layout path under the
+// archive folder, with the extension of the format
+func (o Options) archivePath(repo Repository) string {
+	return filepath.Join(o.ArchiveFolder(), o.repoDir(repo)) + "." + o.Archive
+}
+
+// packRepository packs the clone of repo into its archive and returns the path of the
+// archive. The archive is left as it is when it was packed at the HEAD the clone has
+// now, and packed reports whether a new one was written.
+func packRepository(ctx context.Context, opts Options, repo Repository) (path string, packed bool, err error) {
+	dir := opts.repoDir(repo)
+	path = opts.archivePath(repo)
+	// An empty repository has no HEAD and is packed every time
+	head, _ := gitOutput(ctx, dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	if head != "" {
+		recorded, err := os.ReadFile(path + headSuffix)
+		if _, statErr := os.Stat(path); err == nil && statErr == nil && strings.TrimSpace(string(recorded)) == head {
+			return path, false, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	tmp := f.Name()
+	// Remove the partial archive on any failure; after the rename this is a no-op
+	defer os.Remove(tmp)
+	started := time.Now()
+	if err := writeArchive(ctx, f, dir, opts.Archive); err != nil {
+		f.Close()
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", false, fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	// The HEAD is recorded once the archive is in place, so an interrupted pack is
+	// redone by the next run
+	if err := WriteFileAtomic(path+headSuffix, []byte(head+"\n"), 0o644, opts.Fsync); err != nil {
+		return "", false, err
+	}
+	logger.Info("packed repository", repoAttr(repo), "path", path, "head", head, "duration", time.Since(started))
+	return path, true, nil
+}
+
+// writeArchive writes the tree at dir to w as a tar stream compressed as format asks
+func writeArchive(ctx context.Context, w io.Writer, dir, format string) error {
+	switch format {
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		if err := writeTar(ctx, gz, dir); err != nil {
+			return err
+		}
+		return gz.Close()
+	case ArchiveTarZst:
+		return writeZstd(ctx, w, func(w io.Writer) error { return writeTar(ctx, w, dir) })
+	default:
+		return writeTar(ctx, w, dir)
+	}
+}
+
+// writeZstd has the zstd command compress what write writes into w
+func writeZstd(ctx context.Context, w io.Writer, write func(io.Writer) error) error {
+	cmd := exec.CommandContext(ctx, "zstd", "-q", "-c", "-T0")
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	writeErr := write(in)
+	in.Close()
+	err = cmd.Wait()
+	logCommand(slog.LevelDebug, cmd, started, err, stderr.String())
+	if err != nil {
+		return &commandError{err: err, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return writeErr
+}
+
+// writeTar writes the tree at dir to w as a tar stream whose entries are under the
+// name of dir, e.g. api/.git/HEAD. Symbolic links are kept as links, and sockets and
+// other special files are left out.
+func writeTar(ctx context.Context, w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	base := filepath.Dir(filepath.Clean(dir))
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch mode := info.Mode(); {
+		case mode&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !mode.IsRegular() && !mode.IsDir():
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PlainStatus describes the outcome of a finished repository in words, without colors
+func PlainStatus(repo Repository) string {
+	switch {
+	case errors.Is(repo.Err, ErrConflict):
+		return fmt.Sprintf("conflict: %v", repo.Err)
+	case SkipReason(repo.Err) != "":
+		return "skipped (" + SkipReason(repo.Err) + ")"
+	case errors.Is(repo.Err, ErrCancelled):
+		return "cancelled"
+	case repo.Err != nil:
+		return fmt.Sprintf("failed (%s): %v", ClassifyError(repo.Err), repo.Err)
+	case repo.Archived:
+		return "done, archived upstream"
+	case repo.UpToDate && Divergence(repo) != "":
+		return "up to date, " + Divergence(repo)
+	case repo.UpToDate:
+		return "up to date"
+	}
+	status := "done"
+	if !repo.StartedAt.IsZero() && repo.FinishedAt.After(repo.StartedAt) {
+		status += " in " + repo.FinishedAt.Sub(repo.StartedAt).Round(time.Second).String()
+	}
+	if d := Divergence(repo); d != "" {
+		status += ", " + d
+	}
+	if n := len(repo.Warnings); n > 0 {
+		status += fmt.Sprintf(", %d warnings", n)
+	}
+	return status
+}
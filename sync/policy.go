@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Policy restricts which organizations, users and hosts orgsync may touch.
+// Entries are glob patterns matched case-insensitively; deny entries win over allow
+// entries, and an empty allow list allows everything not denied.
+type Policy struct {
+	AllowOrgs  []string `json:"allow_orgs,omitempty"`
+	DenyOrgs   []string `json:"deny_orgs,omitempty"`
+	AllowHosts []string `json:"allow_hosts,omitempty"`
+	DenyHosts  []string `json:"deny_hosts,omitempty"`
+}
+
+// CheckHost returns an error if the policy forbids syncing from host
+func (p Policy) CheckHost(host string) error {
+	if !permitted(host, p.AllowHosts, p.DenyHosts) {
+		return fmt.Errorf("host %s is not permitted by the configured policy", host)
+	}
+	return nil
+}
+
+// CheckOwner returns an error if the policy forbids syncing repositories of owner
+func (p Policy) CheckOwner(owner string) error {
+	if !permitted(owner, p.AllowOrgs, p.DenyOrgs) {
+		return fmt.Errorf("organization %s is not permitted by the configured policy", owner)
+	}
+	return nil
+}
+
+func permitted(name string, allow, deny []string) bool {
+	if matchesAny(name, deny) {
+		return false
+	}
+	return len(allow) == 0 || matchesAny(name, allow)
+}
+
+func matchesAny(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPermitted drops repositories whose owner the policy forbids, which matters
+// when discovery returns repositories from other owners (e.g. user collaborations)
+func filterPermitted(repos []Repository, policy Policy) []Repository {
+	var permitted []Repository
+	for _, repo := range repos {
+		if repo.Owner == "" || policy.CheckOwner(repo.Owner) == nil {
+			permitted = append(permitted, repo)
+		}
+	}
+	return permitted
+}
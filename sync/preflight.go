@@ -0,0 +1,30 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+)
+
+// CheckWritable verifies that the sync root exists, is a directory, and that new
+// files can be created in it, so that a read-only mount or a permission problem is
+// reported once up front instead of as hundreds of clone failures.
+func CheckWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("cannot access sync directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("sync directory %s is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".orgsync-write-test-*")
+	if err != nil {
+		return fmt.Errorf("sync directory %s is not writable: %w (%s)", dir, err, Hint(CategoryDisk))
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove files in sync directory %s: %w (%s)", dir, err, Hint(CategoryDisk))
+	}
+	return nil
+}
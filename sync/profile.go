@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile is a saved selection of repositories, usually composed with `orgsync explore`.
+// Rules have the form field:pattern, where field is name, language or topic and the
+// pattern is a case-insensitive glob, or pushed:<days>d for repositories pushed to within
+// that many days. A rule without a field matches the name. A profile may also name the
+// account to sync with.
+type Profile struct {
+	// Include keeps only repositories matching at least one rule; empty keeps everything
+	Include []string `json:"include,omitempty"`
+	// Exclude drops repositories matching any rule, even if they are included
+	Exclude []string `json:"exclude,omitempty"`
+	// Auth is the account the profile syncs with
+	Auth Auth `json:"auth,omitempty"`
+}
+
+// ValidateRule returns an error if rule cannot be understood
+func ValidateRule(rule string) error {
+	field, pattern, ok := strings.Cut(rule, ":")
+	if !ok {
+		field, pattern = "name", rule
+	}
+	if pattern == "" {
+		return fmt.Errorf("rule %q has no pattern", rule)
+	}
+	switch field {
+	case "name", "language", "topic":
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("rule %q: %w", rule, err)
+		}
+		return nil
+	case "pushed":
+		if _, err := parseDays(pattern); err != nil {
+			return fmt.Errorf("rule %q: %w", rule, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("rule %q: unknown field %q (expected name, language, topic or pushed)", rule, field)
+	}
+}
+
+// matchRule reports whether repo satisfies a single rule
+func matchRule(rule string, repo Repository, now time.Time) bool {
+	field, pattern, ok := strings.Cut(rule, ":")
+	if !ok {
+		field, pattern = "name", rule
+	}
+	switch field {
+	case "name":
+		return matchesAny(repo.Name, []string{pattern})
+	case "language":
+		return matchesAny(repo.Language, []string{pattern})
+	case "topic":
+		for _, topic := range repo.Topics {
+			if matchesAny(topic, []string{pattern}) {
+				return true
+			}
+		}
+		return false
+	case "pushed":
+		days, err := parseDays(pattern)
+		return err == nil && !repo.PushedAt.IsZero() && now.Sub(repo.PushedAt) <= time.Duration(days)*24*time.Hour
+	default:
+		return false
+	}
+}
+
+// parseDays parses a day count such as "90d"
+func parseDays(s string) (int, error) {
+	days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("expected a number of days such as 90d, got %q", s)
+	}
+	return days, nil
+}
+
+// Matches reports whether the profile selects repo
+func (p Profile) Matches(repo Repository, now time.Time) bool {
+	for _, rule := range p.Exclude {
+		if matchRule(rule, repo, now) {
+			return false
+		}
+	}
+	if len(p.Include) == 0 {
+		return true
+	}
+	for _, rule := range p.Include {
+		if matchRule(rule, repo, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether the profile selects every repository
+func (p Profile) Empty() bool {
+	return len(p.Include) == 0 && len(p.Exclude) == 0
+}
+
+// Filter keeps the repositories the profile selects
+func (p Profile) Filter(repos []Repository) []Repository {
+	if p.Empty() {
+		return repos
+	}
+	now := time.Now()
+	var selected []Repository
+	for _, repo := range repos {
+		if p.Matches(repo, now) {
+			selected = append(selected, repo)
+		}
+	}
+	return selected
+}
+
+// SaveProfile stores profile under name in the config file at path, creating the file
+// if needed. Settings orgsync does not know about are preserved.
+func SaveProfile(configPath, name string, profile Profile) error {
+	raw := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(configPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read config: %w", err)
+	default:
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse config %s: %w", configPath, err)
+		}
+	}
+
+	profiles := make(map[string]Profile)
+	if existing, ok := raw["profiles"]; ok {
+		if err := json.Unmarshal(existing, &profiles); err != nil {
+			return fmt.Errorf("failed to parse profiles in %s: %w", configPath, err)
+		}
+	}
+	// The account is only ever set by hand, so saving rules keeps it
+	if existing, ok := profiles[name]; ok && profile.Auth.Empty() {
+		profile.Auth = existing.Auth
+	}
+	profiles[name] = profile
+	encoded, err := json.Marshal(profiles)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+	raw["profiles"] = encoded
+
+	data, err = json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := WriteFileAtomic(configPath, append(data, '\n'), 0o644, false); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
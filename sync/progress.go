@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gitProgressPattern matches git's sideband progress lines, e.g.
+// "Receiving objects:  45% (450/1000), 1.20 MiB | 2.40 MiB/s"
+var gitProgressPattern = regexp.MustCompile(`Receiving objects:\s+(\d+)%[^,|]*(?:,\s*([\d.]+ (?:[KMG]iB|bytes)))?(?:\s*\|\s*([\d.]+ (?:[KMG]iB|bytes)/s))?`)
+
+// gitNoisePattern matches the remaining progress chatter that is not worth keeping as output
+var gitNoisePattern = regexp.MustCompile(`^(remote: )?(Enumerating|Counting|Compressing|Receiving|Resolving|Updating files|Total|Cloning into|Submodule|Fetching submodule)`)
+
+// submoduleRegisteredPattern matches the line git prints for each submodule it is about to
+// clone, e.g. "Submodule 'lib' (https://github.com/org/lib) registered for path 'lib'"
+var submoduleRegisteredPattern = regexp.MustCompile(`^Submodule '.*' \(.*\) registered for path`)
+
+// gitWarningPattern matches non-fatal notices from git and gh, such as expiring tokens
+// or repository redirects, which are reported as warnings rather than errors
+var gitWarningPattern = regexp.MustCompile(`(?i)^(remote: )?(warning|notice|hint)\b|token expires|expires soon|redirect|moved permanently|deprecat`)
+
+// isWarning reports whether a line of stderr is a non-fatal warning
+func isWarning(line string) bool {
+	lower := strings.ToLower(line)
+	if strings.HasPrefix(lower, "fatal:") || strings.HasPrefix(lower, "error:") {
+		return false
+	}
+	return gitWarningPattern.MatchString(line)
+}
+
+// progressWriter parses git progress from stderr and reports it as it arrives.
+// git redraws progress lines with carriage returns, so both \r and \n end a line.
+// Warnings are collected separately; any other output is kept so that failures can be explained.
+// Submodules are transferred one after another once the repository itself is done, so the
+// reported progress spans the repository and every announced submodule.
+type progressWriter struct {
+	report func(progress float64, speed string, received int64)
+	buf    []byte
+	// received is the number of bytes git reported receiving, across all submodules
+	received int64
+	output   []string
+	warnings []string
+	// commands lists the command lines whose stderr was written here
+	commands []string
+	// submodules counts the submodules git announced it will clone
+	submodules int
+	// phase is the index of the transfer in progress, 0 for the repository itself,
+	// and percent is the last progress it reported
+	phase   int
+	percent int
+	// receivedBefore is the number of bytes received in earlier phases
+	receivedBefore int64
+	// protectWorktree makes runCommand refuse git commands that modify a working tree
+	protectWorktree bool
+	// upToDate is set when the clone already matched origin and was not fetched, and
+	// updated when it was fetched because origin had changed
+	upToDate bool
+	updated  bool
+	// protocol is the protocol a new clone was made over
+	protocol string
+	// transcript, in verbose mode, records everything the commands wrote
+	transcript *transcript
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.parseLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) parseLine(line []byte) {
+	match := gitProgressPattern.FindSubmatch(line)
+	if match == nil {
+		text := strings.TrimSpace(string(line))
+		switch {
+		case submoduleRegisteredPattern.MatchString(text):
+			w.submodules++
+		case text == "" || gitNoisePattern.MatchString(text):
+		case isWarning(text):
+			w.warnings = append(w.warnings, text)
+		default:
+			w.output = append(w.output, text)
+		}
+		return
+	}
+	percent, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return
+	}
+	// A transfer starting over from a lower percentage is the next submodule
+	if percent < w.percent {
+		w.phase++
+		w.receivedBefore = w.received
+	}
+	w.percent = percent
+	if received := parseSize(string(match[2])); received > 0 {
+		w.received = w.receivedBefore + received
+	}
+	if w.report != nil {
+		w.report(w.overall(percent), w.phaseSpeed(string(match[3])), w.received)
+	}
+}
+
+// overall spreads the progress of the current transfer over the repository and its submodules
+func (w *progressWriter) overall(percent int) float64 {
+	phases := max(w.submodules+1, w.phase+1)
+	return (float64(w.phase) + float64(percent)/100) / float64(phases)
+}
+
+// phaseSpeed labels the transfer speed with the submodule being transferred, if any
+func (w *progressWriter) phaseSpeed(speed string) string {
+	switch {
+	case w.phase == 0:
+		return speed
+	case w.submodules >= w.phase:
+		return strings.TrimSpace(fmt.Sprintf("%s submodule %d/%d", speed, w.phase, w.submodules))
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s submodule %d", speed, w.phase))
+	}
+}
+
+// Output returns the non-progress lines written to stderr
+func (w *progressWriter) Output() string {
+	return strings.Join(w.output, "\n")
+}
+
+// parseSize converts a git size such as "1.20 MiB" or "512 bytes" into bytes
+func parseSize(s string) int64 {
+	value, unit, ok := strings.Cut(s, " ")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	switch unit {
+	case "KiB":
+		n *= 1 << 10
+	case "MiB":
+		n *= 1 << 20
+	case "GiB":
+		n *= 1 << 30
+	}
+	return int64(n)
+}
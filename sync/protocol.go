@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Protocols new clones are made over, for Options.Protocol
+const (
+	ProtocolHTTPS = "https"
+	ProtocolSSH   = "ssh"
+	// ProtocolAuto starts with the protocol gh is configured with and falls back to
+	// the other one when authenticating over it fails
+	ProtocolAuto = "auto"
+)
+
+// ValidProtocol reports whether protocol is one of the Protocol constants, or empty
+// for the git_protocol gh is configured with
+func ValidProtocol(protocol string) bool {
+	switch protocol {
+	case "", ProtocolHTTPS, ProtocolSSH, ProtocolAuto:
+		return true
+	default:
+		return false
+	}
+}
+
+// protocolFailures maps substrings of git output to the protocol whose connection or
+// authentication they report a failure of
+var protocolFailures = []struct {
+	protocol string
+	patterns []string
+}{
+	{ProtocolSSH, []string{"permission denied (publickey", "host key verification failed", "no matching host key", "ssh: connect to host", "ssh: could not resolve hostname"}},
+	{ProtocolHTTPS, []string{"authentication failed for 'http", "could not read username", "unable to access 'http", "http 401", "http 403"}},
+}
+
+// failedProtocol returns the protocol a clone that failed with err could not connect
+// or authenticate over, or "" if it failed for another reason
+func failedProtocol(err error) string {
+	text := strings.ToLower(err.Error() + "\n" + ErrorOutput(err))
+	for _, f := range protocolFailures {
+		for _, pattern := range f.patterns {
+			if strings.Contains(text, pattern) {
+				return f.protocol
+			}
+		}
+	}
+	return ""
+}
+
+// protocolOf returns the protocol of a remote URL
+func protocolOf(url string) string {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+		return ProtocolHTTPS
+	}
+	return ProtocolSSH
+}
+
+// cloneSource returns what gh clones repo from over protocol: a URL, or owner/name for
+// gh to pick its configured protocol
+func cloneSource(repo Repository, host, protocol string) string {
+	switch protocol {
+	case ProtocolHTTPS:
+		return fmt.Sprintf("https://%s/%s/%s.git", host, repo.Owner, repo.Name)
+	case ProtocolSSH:
+		return fmt.Sprintf("git@%s:%s/%s.git", host, repo.Owner, repo.Name)
+	default:
+		return repo.Owner + "/" + repo.Name
+	}
+}
+
+// cloneOverProtocol clones repo into dir over Options.Protocol. With ProtocolAuto, a
+// clone that could not connect or authenticate over one protocol is made again over
+// the other. The protocol the clone was made over is recorded in progress.
+func cloneOverProtocol(ctx context.Context, opts Options, repo Repository, dir string, progress *progressWriter) error {
+	protocol := opts.Protocol
+	if protocol == ProtocolAuto {
+		protocol = ""
+	}
+	// Without gh to pick one, git is given the URL gh would use by default
+	if protocol == "" && withoutGH() {
+		protocol = ProtocolHTTPS
+	}
+	err := cloneRepo(ctx, cloneSource(repo, opts.Host, protocol), repo.Name, dir, opts.cloneArgs(repo), progress)
+	if err != nil && opts.Protocol == ProtocolAuto && ctx.Err() == nil {
+		if failed := failedProtocol(err); failed != "" {
+			other := ProtocolHTTPS
+			if failed == ProtocolHTTPS {
+				other = ProtocolSSH
+			}
+			logger.Warn("retrying clone over another protocol", repoAttr(repo), "failed", failed, "protocol", other, "error", err)
+			// git may leave the remains of the failed clone behind
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("failed to clean up the failed clone of %s: %w", repo.Name, err)
+			}
+			err = cloneRepo(ctx, cloneSource(repo, opts.Host, other), repo.Name, dir, opts.cloneArgs(repo), progress)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if url, err := originURL(ctx, dir); err == nil {
+		progress.protocol = protocolOf(url)
+	}
+	return nil
+}
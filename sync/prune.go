@@ -0,0 +1,192 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PinMarker is a file that protects a local repository from pruning and relocation
+const PinMarker = ".orgsync-keep"
+
+// pruneResult reports the outcome of pruning local clones that no longer exist upstream.
+// Spared are those holding work that is on no remote, Declined those the user chose to
+// keep when asked, and Pending those still to be deleted once that is confirmed.
+type pruneResult struct {
+	Pruned   []string
+	Kept     []string
+	Spared   []string
+	Declined []string
+	Pending  []string
+	Err      error
+}
+
+// isPinned reports whether the local repository in dir is protected, either by a
+// marker file or because its name matches one of the configured keep patterns
+func isPinned(dir string, keep []string) bool {
+	if _, err := os.Stat(filepath.Join(dir, PinMarker)); err == nil {
+		return true
+	}
+	return matchesAny(filepath.Base(dir), keep)
+}
+
+// localRoot is the directory holding the local clones for the target
+func (o Options) localRoot() string {
+	if o.Target == TargetGists {
+		return filepath.Join(".", gistsDir)
+	}
+	return "."
+}
+
+// orphanPatterns returns glob patterns matching every place a clone of the target can
+// be. Only the owners being synchronized are covered, which keeps out the clones of
+// other owners when the layout contains {owner}. A layout without it puts every owner
+// in the same place, and findOrphans goes by the origin of the clones instead.
+func (o Options) orphanPatterns(upstream []Repository) []string {
+	if o.Target == TargetGists {
+		return []string{filepath.Join(".", gistsDir, "*")}
+	}
+	owners := slices.Clone(o.AllOwners())
+	for _, repo := range upstream {
+		owners = append(owners, repo.Owner)
+	}
+	return layoutPatterns(o.Layout, owners)
+}
+
+// findOrphans lists local clones whose repositories were not discovered upstream. Of
+// a layout without {owner}, only clones whose origin is one of the owners being
+// synchronized count, so that those of other owners sharing the sync root are kept.
+func findOrphans(opts Options, upstream []Repository) ([]string, error) {
+	// Mirror and working-tree clones of the same repository are both accounted for
+	known := make(map[string]bool, len(upstream))
+	owners := make(map[string]bool)
+	for _, repo := range upstream {
+		known[strings.TrimSuffix(filepath.Clean(opts.repoDir(repo)), ".git")] = true
+		owners[strings.ToLower(repo.Owner)] = true
+	}
+	for _, owner := range opts.AllOwners() {
+		owners[strings.ToLower(owner)] = true
+	}
+	checkOwner := opts.Target != TargetGists && !layoutSeparatesOwners(opts.Layout)
+
+	var orphans []string
+	for _, pattern := range opts.orphanPatterns(upstream) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", pattern, err)
+		}
+		for _, dir := range matches {
+			if hidden(dir) || inArchive(dir) || known[strings.TrimSuffix(dir, ".git")] {
+				continue
+			}
+			// Only directories that are git repositories are candidates for pruning
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() || !isRepository(dir) {
+				continue
+			}
+			if checkOwner && !ownedClone(dir, opts.Host, owners) {
+				continue
+			}
+			orphans = append(orphans, dir)
+		}
+	}
+	return orphans, nil
+}
+
+// ownedClone reports whether the origin of the clone in dir is a repository on host of
+// one of owners, given in lower case. A clone without a readable origin is not.
+func ownedClone(dir, host string, owners map[string]bool) bool {
+	url, err := originURL(context.Background(), dir)
+	if err != nil {
+		return false
+	}
+	originHost, path := parseRemote(url)
+	owner, _, _ := strings.Cut(path, "/")
+	return originHost == strings.ToLower(host) && owners[strings.ToLower(owner)]
+}
+
+// hidden reports whether any element of path starts with a dot, like the staging directory
+func hidden(path string) bool {
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		if strings.HasPrefix(element, ".") && element != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// isBareRepository reports whether the repository in dir has no working tree
+func isBareRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return os.IsNotExist(err)
+}
+
+// isRepository reports whether dir is a git repository, either with a working tree
+// or bare like the <name>.git mirror clones
+func isRepository(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneCandidates finds the local clones not among upstream and sorts them into those
+// to delete, left Pending, and those kept: pinned ones, and unless opts.Force those
+// holding work that is on no remote
+func pruneCandidates(ctx context.Context, opts Options, upstream []Repository) pruneResult {
+	orphans, err := findOrphans(opts, upstream)
+	if err != nil {
+		return pruneResult{Err: err}
+	}
+
+	var result pruneResult
+	for _, dir := range orphans {
+		name, err := filepath.Rel(opts.localRoot(), dir)
+		if err != nil {
+			name = dir
+		}
+		switch {
+		case isPinned(dir, opts.Keep):
+			logger.Info("kept pinned clone", "path", name)
+			result.Kept = append(result.Kept, name)
+		case !opts.Force && holdsWork(ctx, dir):
+			logger.Warn("kept clone with work on no remote", "path", name)
+			result.Spared = append(result.Spared, name)
+		default:
+			result.Pending = append(result.Pending, name)
+		}
+	}
+	return result
+}
+
+// removeOrphans deletes the clones result left pending and reports them as pruned
+func removeOrphans(opts Options, result pruneResult) pruneResult {
+	for _, name := range result.Pending {
+		dir := filepath.Join(opts.localRoot(), name)
+		if err := os.RemoveAll(dir); err != nil {
+			result.Err = fmt.Errorf("failed to prune %s: %w", dir, err)
+			logger.Error("failed to prune clone", "path", name, "error", err)
+			continue
+		}
+		logger.Info("pruned clone", "path", name)
+		result.Pruned = append(result.Pruned, name)
+	}
+	result.Pending = nil
+	return result
+}
+
+// declineOrphans keeps the clones result left pending, as the user chose when asked
+func declineOrphans(result pruneResult) pruneResult {
+	for _, name := range result.Pending {
+		logger.Info("kept clone the user chose not to prune", "path", name)
+	}
+	result.Declined, result.Pending = result.Pending, nil
+	return result
+}
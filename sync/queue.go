@@ -0,0 +1,243 @@
+package sync
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// Bottlenecks reported for a run's queue wait
+const (
+	BottleneckConcurrency = schema.BottleneckConcurrency
+	BottleneckNetwork     = schema.BottleneckNetwork
+)
+
+// Orders in which the queue dispatches repositories, for Options.Order
+const (
+	OrderSizeAsc  = "size-asc"
+	OrderSizeDesc = "size-desc"
+	OrderName     = "name"
+	OrderRandom   = "random"
+)
+
+// ValidOrder reports whether order is one of the Order constants, or empty for the
+// order of discovery
+func ValidOrder(order string) bool {
+	switch order {
+	case "", OrderSizeAsc, OrderSizeDesc, OrderName, OrderRandom:
+		return true
+	default:
+		return false
+	}
+}
+
+// schedule returns repos in the order the queue should dispatch them. Small
+// repositories first give fast feedback, big ones first finish sooner when the
+// concurrency is limited and the biggest would otherwise start last.
+func schedule(repos []Repository, order string) []Repository {
+	repos = slices.Clone(repos)
+	switch order {
+	case OrderSizeAsc:
+		slices.SortStableFunc(repos, func(a, b Repository) int { return cmp.Compare(a.Size, b.Size) })
+	case OrderSizeDesc:
+		slices.SortStableFunc(repos, func(a, b Repository) int { return cmp.Compare(b.Size, a.Size) })
+	case OrderName:
+		slices.SortStableFunc(repos, func(a, b Repository) int { return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+	case OrderRandom:
+		rand.Shuffle(len(repos), func(i, j int) { repos[i], repos[j] = repos[j], repos[i] })
+	}
+	return repos
+}
+
+// pool synchronizes repositories on up to limit workers at once; zero means no limit.
+// Unlike a semaphore sized for the first batch, it keeps accepting repositories for as
+// long as it has work: a worker that finishes one takes the next from the queue.
+type pool struct {
+	limit   int
+	maxSize int64
+	sync    func(repo Repository) syncResult
+	// results delivers the outcome of every repository added
+	results chan syncResult
+
+	mu    gosync.Mutex
+	queue []queuedRepository
+	// busy counts running workers and pending the repositories whose results were not
+	// handled yet. The pool closes once it has none left.
+	busy    int
+	pending int
+	closed  bool
+	// paused holds queued repositories back until resume, and draining refuses any
+	// more repositories for good
+	paused   bool
+	draining bool
+}
+
+// queuedRepository is a repository waiting for a worker since queuedAt
+type queuedRepository struct {
+	repo     Repository
+	queuedAt time.Time
+}
+
+// newPool returns a pool synchronizing repositories with sync. Repositories bigger than
+// maxSize are skipped without taking a worker from the others.
+func newPool(limit int, maxSize int64, sync func(repo Repository) syncResult) *pool {
+	return &pool{limit: limit, maxSize: maxSize, sync: sync, results: make(chan syncResult)}
+}
+
+// add queues repos and starts workers as the limit allows. It reports false, adding
+// nothing, once the pool is closed.
+func (p *pool) add(repos ...Repository) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.draining {
+		return false
+	}
+	p.pending += len(repos)
+	for _, repo := range repos {
+		if err := checkSize(repo, p.maxSize); err != nil {
+			logger.Info("repository skipped", repoAttr(repo), "error", err)
+			go func() { p.results <- syncResult{Repo: repo, Err: err} }()
+			continue
+		}
+		p.queue = append(p.queue, queuedRepository{repo: repo, queuedAt: time.Now()})
+	}
+	p.dispatch()
+	return true
+}
+
+// dispatch starts workers for queued repositories as the limit allows; p.mu must be held
+func (p *pool) dispatch() {
+	for len(p.queue) > 0 && !p.paused && (p.limit <= 0 || p.busy < p.limit) {
+		p.busy++
+		go p.work(p.next())
+	}
+}
+
+// remove takes the repository with the given key off the queue, delivering err as its
+// result. It reports false if the repository is not queued.
+func (p *pool) remove(key string, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, queued := range p.queue {
+		if queued.repo.Key() == key {
+			p.queue = slices.Delete(p.queue, i, i+1)
+			go func() { p.results <- syncResult{Repo: queued.repo, Err: err} }()
+			return true
+		}
+	}
+	return false
+}
+
+// pause stops workers from taking repositories off the queue. Those already syncing
+// carry on.
+func (p *pool) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+}
+
+// drain cancels the queued repositories and refuses new ones, so that the pool is done
+// once the running syncs are
+func (p *pool) drain() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draining = true
+	for _, queued := range p.queue {
+		repo := queued.repo
+		go func() { p.results <- syncResult{Repo: repo, Err: ErrCancelled} }()
+	}
+	p.queue = nil
+}
+
+// resume lets workers take queued repositories again
+func (p *pool) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = false
+	p.dispatch()
+}
+
+// next takes the repository at the head of the queue; p.mu must be held
+func (p *pool) next() Repository {
+	queued := p.queue[0]
+	p.queue = p.queue[1:]
+	queued.repo.QueueWait = time.Since(queued.queuedAt)
+	return queued.repo
+}
+
+// work synchronizes repo and then whatever is queued, until the queue is empty or the
+// pool is paused
+func (p *pool) work(repo Repository) {
+	for {
+		p.results <- p.sync(repo)
+		p.mu.Lock()
+		if len(p.queue) == 0 || p.paused {
+			p.busy--
+			p.mu.Unlock()
+			return
+		}
+		repo = p.next()
+		p.mu.Unlock()
+	}
+}
+
+// done marks one result as handled
+func (p *pool) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending--
+}
+
+// idle reports whether every result was handled, closing the pool if so
+func (p *pool) idle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == 0 {
+		p.closed = true
+	}
+	return p.closed
+}
+
+// percentile returns the p-th percentile (0-1) of durations using the nearest-rank
+// method, or zero when there are none
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// bottleneck decides whether worker slots or the transfers themselves limited the run.
+// When repositories typically wait longer for a worker than a typical sync takes,
+// more concurrency would have finished sooner.
+func bottleneck(queueWaitP95, syncMedian time.Duration) string {
+	if queueWaitP95 > syncMedian && queueWaitP95 >= time.Second {
+		return BottleneckConcurrency
+	}
+	return BottleneckNetwork
+}
+
+// QueueWarning explains a run that was held back by --concurrency, or is empty
+// when the network was the bottleneck
+func QueueWarning(r Report) string {
+	if r.Totals.Bottleneck != BottleneckConcurrency {
+		return ""
+	}
+	return fmt.Sprintf("Repositories waited up to %s (p95) for a free worker, longer than a typical sync took. Raise --concurrency to finish sooner.",
+		seconds(r.Totals.QueueWaitP95Seconds).Round(100*time.Millisecond))
+}
+
+// seconds converts a report duration back into a time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RateLimit is the core REST API quota of the authenticated user
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// String renders the remaining quota for the header, e.g. "API quota 4321/5000"
+func (r RateLimit) String() string {
+	if r.Limit == 0 {
+		return ""
+	}
+	return fmt.Sprintf("API quota %d/%d", r.Remaining, r.Limit)
+}
+
+// fetchRateLimit asks GitHub for the current quota. Querying it does not count against it.
+func fetchRateLimit() (RateLimit, error) {
+	data, err := apiGet("rate_limit", false, nil)
+	if err != nil {
+		return RateLimit{}, fmt.Errorf("failed to fetch rate limit: %w", err)
+	}
+	var answer struct {
+		Resources struct {
+			Core struct {
+				Limit     int
+				Remaining int
+				Reset     int64
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return RateLimit{}, fmt.Errorf("failed to parse rate limit: %w", err)
+	}
+	core := answer.Resources.Core
+	return RateLimit{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
+
+// rateLimitBackoff returns how long to wait before retrying after the given attempt hit
+// a rate limit: until the quota resets when it is exhausted, otherwise an exponential
+// backoff from base for GitHub's secondary limits, which announce no reset time
+func rateLimitBackoff(attempt int, limit RateLimit, base time.Duration, now time.Time) time.Duration {
+	if limit.Limit > 0 && limit.Remaining == 0 && limit.Reset.After(now) {
+		return limit.Reset.Sub(now) + time.Second
+	}
+	return exponentialBackoff(attempt, base)
+}
+
+// sleepContext waits for d, returning false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Conflict handling modes for local directories whose origin points elsewhere
+const (
+	ConflictSkip     = "skip"
+	ConflictAdopt    = "adopt"
+	ConflictRelocate = "relocate"
+)
+
+// ErrConflict marks a local directory whose origin does not match the expected repository
+var ErrConflict = errors.New("conflict")
+
+// originURL returns the URL of the origin remote of the repository in dir as
+// configured, without the url.<base>.insteadOf rewrites `git remote get-url` applies
+func originURL(ctx context.Context, dir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "--get", "remote.origin.url")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	started := time.Now()
+	err := cmd.Run()
+	logCommand(slog.LevelDebug, cmd, started, err, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read origin: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// parseRemote splits a git remote URL into its host and path, accepting the
+// https://host/path, ssh://git@host/path and git@host:path forms
+func parseRemote(url string) (host, path string) {
+	rest := url
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+		if at := strings.Index(rest, "@"); at >= 0 && at < strings.Index(rest+"/", "/") {
+			rest = rest[at+1:]
+		}
+		host, path, _ = strings.Cut(rest, "/")
+	} else {
+		if at := strings.Index(rest, "@"); at >= 0 {
+			rest = rest[at+1:]
+		}
+		host, path, _ = strings.Cut(rest, ":")
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return strings.ToLower(host), strings.ToLower(path)
+}
+
+// remoteMatches reports whether a remote URL points at the given repository on host
+func remoteMatches(url string, repo Repository, host string) bool {
+	remoteHost, path := parseRemote(url)
+	if repo.Gist {
+		// Gists live on gist.<host> and are addressed by ID, optionally under the owner
+		return remoteHost == "gist."+strings.ToLower(host) && (path == strings.ToLower(repo.Name) || strings.HasSuffix(path, "/"+strings.ToLower(repo.Name)))
+	}
+	return remoteHost == strings.ToLower(host) && path == strings.ToLower(repo.Owner+"/"+repo.Name)
+}
+
+// expectedRemote builds the origin URL for repo, keeping the protocol of current
+func expectedRemote(current string, repo Repository, host string) string {
+	return remoteURL(repo, host, protocolOf(current))
+}
+
+// remoteURL builds the URL of repo on host over protocol, ProtocolHTTPS or ProtocolSSH
+func remoteURL(repo Repository, host, protocol string) string {
+	path := repo.Owner + "/" + repo.Name
+	if repo.Gist {
+		host = "gist." + host
+		path = repo.Name
+	}
+	if protocol == ProtocolHTTPS {
+		return fmt.Sprintf("https://%s/%s.git", host, path)
+	}
+	return fmt.Sprintf("git@%s:%s.git", host, path)
+}
+
+// fixRemote points the origin of an existing clone of repo at Options.Host over
+// Options.Protocol when it uses another host or protocol, for --fix-remotes. Without
+// a protocol of https or ssh to move to, the clone keeps the one it has. An origin
+// pointing at another repository is left alone for checkOrigin to deal with, and a
+// working tree with local changes is skipped with ErrDirty unless opts.Force.
+func fixRemote(ctx context.Context, opts Options, repo Repository, repoDir string, progress *progressWriter) error {
+	url, err := originURL(ctx, repoDir)
+	if err != nil {
+		return nil
+	}
+	// Only the path has to match, on whatever host the origin is
+	host, _ := parseRemote(url)
+	if !remoteMatches(url, repo, strings.TrimPrefix(host, "gist.")) {
+		return nil
+	}
+	protocol := opts.Protocol
+	if protocol != ProtocolHTTPS && protocol != ProtocolSSH {
+		protocol = protocolOf(url)
+	}
+	wantHost := strings.ToLower(opts.Host)
+	if repo.Gist {
+		wantHost = "gist." + wantHost
+	}
+	if host == wantHost && protocolOf(url) == protocol {
+		return nil
+	}
+
+	fixed := remoteURL(repo, opts.Host, protocol)
+	if err := guardDirty(ctx, opts, repoDir, "rewriting origin"); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "remote", "set-url", "origin", fixed)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to point origin of %s at %s: %w", repo.Name, fixed, newCommandError(err, progress))
+	}
+	logger.Info("rewrote origin", repoAttr(repo), "from", url, "to", fixed)
+	return nil
+}
+
+// checkOrigin makes sure an existing clone belongs to repo before it is fetched.
+// A mismatch is resolved according to mode: adopt rewrites the origin, relocate moves
+// the directory aside (returning moved=true so it can be cloned afresh) and skip
+// reports the conflict. Like fixRemote, adopt leaves a working tree with local
+// changes alone unless opts.Force.
+func checkOrigin(ctx context.Context, opts Options, repo Repository, repoDir string) (moved bool, err error) {
+	url, err := originURL(ctx, repoDir)
+	if err == nil && remoteMatches(url, repo, opts.Host) {
+		return false, nil
+	}
+	if url == "" {
+		url = "no origin remote"
+	}
+
+	switch opts.OnConflict {
+	case ConflictAdopt:
+		if url == "no origin remote" {
+			break
+		}
+		if err := guardDirty(ctx, opts, repoDir, "adopting "+filepath.Base(repoDir)); err != nil {
+			return false, err
+		}
+		cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "remote", "set-url", "origin", expectedRemote(url, repo, opts.Host))
+		started := time.Now()
+		err := cmd.Run()
+		logCommand(slog.LevelInfo, cmd, started, err, "")
+		if err != nil {
+			return false, fmt.Errorf("failed to adopt %s: %w", repo.Name, err)
+		}
+		return false, nil
+	case ConflictRelocate:
+		if isPinned(repoDir, opts.Keep) {
+			break
+		}
+		target := fmt.Sprintf("%s.conflict-%s", repoDir, time.Now().Format("20060102-150405"))
+		if err := os.Rename(repoDir, target); err != nil {
+			return false, fmt.Errorf("failed to relocate %s: %w", repo.Name, err)
+		}
+		logger.Info("relocated conflicting directory", repoAttr(repo), "origin", url, "path", target)
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %s has origin %s", ErrConflict, filepath.Base(repoDir), url)
+}
@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// redirected describes the local clones whose repositories GitHub now knows under
+// another name
+type redirected struct {
+	// Moved maps repositories renamed upstream, as owner/name, to the directory their clone was moved from
+	Moved map[string]string
+	// Transferred lists clones of repositories transferred to an owner that is not
+	// synchronized, as "dir → owner/name"
+	Transferred []string
+}
+
+// followRedirects finds the local clones that are not among upstream because their
+// repositories were renamed or transferred on GitHub, which redirects their origin.
+// The clone of a renamed repository is moved to where it now belongs, if that is one
+// of repos whose directory does not exist yet, so that it is fetched rather than cloned
+// a second time. The clone of a transferred one is moved into ArchiveDir with
+// --move-archived. Pinned clones stay where they are.
+func followRedirects(ctx context.Context, opts Options, upstream, repos []Repository) redirected {
+	var result redirected
+	if opts.Target == TargetGists {
+		return result
+	}
+	orphans, err := findOrphans(opts, upstream)
+	if err != nil {
+		logger.Warn("failed to look for renamed repositories", "error", err)
+		return result
+	}
+	missing := make(map[string]Repository)
+	for _, repo := range repos {
+		if _, err := os.Stat(opts.repoDir(repo)); os.IsNotExist(err) {
+			missing[strings.ToLower(repo.Owner+"/"+repo.Name)] = repo
+		}
+	}
+	owners := make(map[string]bool)
+	for _, owner := range opts.AllOwners() {
+		owners[strings.ToLower(owner)] = true
+	}
+	for _, repo := range upstream {
+		owners[strings.ToLower(repo.Owner)] = true
+	}
+
+	result.Moved = make(map[string]string)
+	for _, dir := range orphans {
+		if isPinned(dir, opts.Keep) {
+			continue
+		}
+		url, err := originURL(ctx, dir)
+		if err != nil {
+			continue
+		}
+		host, path := parseRemote(url)
+		if host != strings.ToLower(opts.Host) || path == "" {
+			continue
+		}
+		current, err := resolveRepository(path)
+		if err != nil || current == path {
+			continue
+		}
+		if owner, _, _ := strings.Cut(current, "/"); !owners[owner] {
+			transferred := dir + " → " + current
+			if opts.MoveArchived {
+				target, err := moveToArchive(dir)
+				if err != nil {
+					logger.Error("failed to archive transferred repository", "path", dir, "error", err)
+				} else {
+					transferred += " (moved to " + target + ")"
+					logger.Info("archived transferred repository", "path", target, "repo", current)
+				}
+			}
+			result.Transferred = append(result.Transferred, transferred)
+			continue
+		}
+		repo, ok := missing[current]
+		if !ok {
+			continue
+		}
+		delete(missing, current)
+
+		target := opts.repoDir(repo)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			logger.Error("failed to relocate renamed repository", repoAttr(repo), "path", dir, "error", err)
+			continue
+		}
+		if err := os.Rename(dir, target); err != nil {
+			logger.Error("failed to relocate renamed repository", repoAttr(repo), "path", dir, "error", err)
+			continue
+		}
+		cmd := exec.CommandContext(ctx, "git", "-C", target, "remote", "set-url", "origin", expectedRemote(url, repo, opts.Host))
+		started := time.Now()
+		err = cmd.Run()
+		logCommand(slog.LevelInfo, cmd, started, err, "")
+		if err != nil {
+			// The clone is in place either way; checkOrigin reports the stale origin
+			logger.Warn("failed to update origin of renamed repository", repoAttr(repo), "error", err)
+		}
+		logger.Info("relocated renamed repository", repoAttr(repo), "from", dir, "to", target)
+		result.Moved[repo.Key()] = dir
+	}
+	return result
+}
+
+// resolveRepository asks GitHub for the current owner/name of the repository at path,
+// following the redirect GitHub keeps after a rename or transfer
+func resolveRepository(path string) (string, error) {
+	data, err := apiGet("repos/"+path, false, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	var repo struct {
+		FullName string `json:"full_name"`
+	}
+	if err := json.Unmarshal(data, &repo); err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return strings.ToLower(repo.FullName), nil
+}
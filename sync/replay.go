@@ -0,0 +1,462 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// A run can be recorded with --record and played back with --replay, to reproduce what
+// the terminal UI showed for an organization without access to it. The recording holds
+// the events the UI received, when it received them, and the state of the repositories
+// they were about. Playing it back has the Engine go through the same events at the
+// same pace, or faster, without running git or gh or touching the sync root.
+
+// Types of recorded events
+const (
+	recordedDiscoveryProgress = "discovery_progress"
+	recordedRateLimited       = "rate_limited"
+	recordedDiscovered        = "discovered"
+	recordedStarted           = "started"
+	recordedProgress          = "progress"
+	recordedFinished          = "finished"
+	recordedPaused            = "paused"
+	recordedPruned            = "pruned"
+	recordedRunFinished       = "run_finished"
+)
+
+// Recording is a run captured for playing it back
+type Recording struct {
+	Schema    string          `json:"schema"`
+	RunID     string          `json:"run_id"`
+	Options   Options         `json:"options"`
+	StartedAt time.Time       `json:"started_at"`
+	Events    []RecordedEvent `json:"events"`
+}
+
+// RecordedEvent is an Event as the UI received it, At after the run started. Which
+// fields are set depends on its Type.
+type RecordedEvent struct {
+	At   time.Duration `json:"at"`
+	Type string        `json:"type"`
+	// Name, Progress, TransferSpeed and BytesReceived report the transfer of a syncing
+	// repository, named as owner/name; recordings made before that name it alone
+	Name          string  `json:"name,omitempty"`
+	Progress      float64 `json:"progress,omitempty"`
+	TransferSpeed string  `json:"transfer_speed,omitempty"`
+	BytesReceived int64   `json:"bytes_received,omitempty"`
+	// Repository is the repository that started or finished, and Repositories those
+	// discovery found
+	Repository   *RecordedRepository  `json:"repository,omitempty"`
+	Repositories []RecordedRepository `json:"repositories,omitempty"`
+	Transferred  []string             `json:"transferred,omitempty"`
+	// Pages and Listed count what discovery listed so far
+	Pages  int `json:"pages,omitempty"`
+	Listed int `json:"listed,omitempty"`
+	// Wait is how long discovery waited out a rate limit
+	Wait      time.Duration `json:"wait,omitempty"`
+	RateLimit *RateLimit    `json:"rate_limit,omitempty"`
+	// Failures and Category tell why the run paused
+	Failures int      `json:"failures,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Pruned   []string `json:"pruned,omitempty"`
+	Kept     []string `json:"kept,omitempty"`
+	Spared   []string `json:"spared,omitempty"`
+	Declined []string `json:"declined,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// RecordedRepository is a Repository with its error written out
+type RecordedRepository struct {
+	Owner         string        `json:"owner,omitempty"`
+	Name          string        `json:"name"`
+	Gist          bool          `json:"gist,omitempty"`
+	Done          bool          `json:"done,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	ErrorCategory string        `json:"error_category,omitempty"`
+	Output        string        `json:"output,omitempty"`
+	Progress      float64       `json:"progress,omitempty"`
+	Size          int64         `json:"size,omitempty"`
+	Language      string        `json:"language,omitempty"`
+	Topics        []string      `json:"topics,omitempty"`
+	PushedAt      time.Time     `json:"pushed_at"`
+	DefaultBranch string        `json:"default_branch,omitempty"`
+	Visibility    string        `json:"visibility,omitempty"`
+	Archived      bool          `json:"archived,omitempty"`
+	CI            string        `json:"ci,omitempty"`
+	BytesReceived int64         `json:"bytes_received,omitempty"`
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	Attempts      int           `json:"attempts,omitempty"`
+	QueueWait     time.Duration `json:"queue_wait,omitempty"`
+	Warnings      []string      `json:"warnings,omitempty"`
+	UpToDate      bool          `json:"up_to_date,omitempty"`
+	Updated       bool          `json:"updated,omitempty"`
+	Protocol      string        `json:"protocol,omitempty"`
+	Upstream      string        `json:"upstream,omitempty"`
+	Ahead         int           `json:"ahead,omitempty"`
+	Behind        int           `json:"behind,omitempty"`
+	Unpushed      int           `json:"unpushed,omitempty"`
+	LocalBranches []string      `json:"local_branches,omitempty"`
+}
+
+// recordRepository writes out repo for a recording
+func recordRepository(repo Repository) *RecordedRepository {
+	return &RecordedRepository{
+		Owner:         repo.Owner,
+		Name:          repo.Name,
+		Gist:          repo.Gist,
+		Done:          repo.Done,
+		Error:         errorText(repo.Err),
+		ErrorCategory: ClassifyError(repo.Err),
+		Output:        ErrorOutput(repo.Err),
+		Progress:      repo.Progress,
+		Size:          repo.Size,
+		Language:      repo.Language,
+		Topics:        repo.Topics,
+		PushedAt:      repo.PushedAt,
+		DefaultBranch: repo.DefaultBranch,
+		Visibility:    repo.Visibility,
+		Archived:      repo.Archived,
+		CI:            repo.CI,
+		BytesReceived: repo.BytesReceived,
+		StartedAt:     repo.StartedAt,
+		FinishedAt:    repo.FinishedAt,
+		Attempts:      repo.Attempts,
+		QueueWait:     repo.QueueWait,
+		Warnings:      repo.Warnings,
+		UpToDate:      repo.UpToDate,
+		Updated:       repo.Updated,
+		Protocol:      repo.Protocol,
+		Upstream:      repo.Upstream,
+		Ahead:         repo.Ahead,
+		Behind:        repo.Behind,
+		Unpushed:      repo.Unpushed,
+		LocalBranches: repo.LocalBranches,
+	}
+}
+
+// repository turns the recorded repository back into a Repository, moving its times
+// with at onto the timeline of the replay
+func (r RecordedRepository) repository(at func(time.Time) time.Time, speed float64) Repository {
+	repo := Repository{
+		Owner:         r.Owner,
+		Name:          r.Name,
+		Gist:          r.Gist,
+		Done:          r.Done,
+		Progress:      r.Progress,
+		Size:          r.Size,
+		Language:      r.Language,
+		Topics:        r.Topics,
+		PushedAt:      r.PushedAt,
+		DefaultBranch: r.DefaultBranch,
+		Visibility:    r.Visibility,
+		Archived:      r.Archived,
+		CI:            r.CI,
+		BytesReceived: r.BytesReceived,
+		StartedAt:     at(r.StartedAt),
+		FinishedAt:    at(r.FinishedAt),
+		Attempts:      r.Attempts,
+		QueueWait:     time.Duration(float64(r.QueueWait) / speed),
+		Warnings:      r.Warnings,
+		UpToDate:      r.UpToDate,
+		Updated:       r.Updated,
+		Protocol:      r.Protocol,
+		Upstream:      r.Upstream,
+		Ahead:         r.Ahead,
+		Behind:        r.Behind,
+		Unpushed:      r.Unpushed,
+		LocalBranches: r.LocalBranches,
+	}
+	if r.Error != "" {
+		repo.Err = &replayedError{message: r.Error, category: r.ErrorCategory, output: r.Output}
+	}
+	return repo
+}
+
+// errorText returns the message of err, or "" without one
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// replayedError stands in for the error a recorded repository failed with
+type replayedError struct {
+	message  string
+	category string
+	output   string
+}
+
+func (e *replayedError) Error() string {
+	return e.message
+}
+
+// categoryErrors are the errors that ClassifyError recognizes a category by
+var categoryErrors = map[string]error{
+	CategoryCancelled: ErrCancelled,
+	CategoryConflict:  ErrConflict,
+	CategoryDirty:     ErrDirty,
+	CategoryTooLarge:  ErrTooLarge,
+	CategorySkipped:   ErrSkippedByUser,
+	CategoryArchived:  ErrArchived,
+	CategoryCommand:   ErrCommandFailed,
+}
+
+// Is matches the error of its category, so that a repository that was skipped,
+// cancelled or in conflict replays as such
+func (e *replayedError) Is(target error) bool {
+	err, ok := categoryErrors[e.category]
+	return ok && err == target
+}
+
+// Add records the events a frontend just received from the run whose state is now s,
+// along with the state of the repositories they are about. The first events added set
+// which run is recorded.
+func (r *Recording) Add(s State, events []Event) {
+	if r.RunID == "" {
+		r.Schema = schema.RecordingSchema
+		r.RunID = s.RunID
+		r.Options = s.Options
+		r.StartedAt = s.StartedAt
+	}
+	index := make(map[string]int, len(s.Repositories))
+	for i, repo := range s.Repositories {
+		index[repo.Key()] = i
+	}
+	at := time.Since(r.StartedAt)
+	for _, event := range events {
+		recorded := RecordedEvent{At: at}
+		switch event := event.(type) {
+		case *DiscoveryProgressEvent:
+			recorded.Type = recordedDiscoveryProgress
+			recorded.Pages = event.Pages
+			recorded.Listed = event.Repositories
+		case *RateLimitedEvent:
+			recorded.Type = recordedRateLimited
+			recorded.Wait = time.Until(event.Until)
+			recorded.RateLimit = &event.RateLimit
+		case *DiscoveredEvent:
+			recorded.Type = recordedDiscovered
+			recorded.Repositories = make([]RecordedRepository, 0, len(event.Repositories))
+			for _, repo := range event.Repositories {
+				recorded.Repositories = append(recorded.Repositories, *recordRepository(repo))
+			}
+			recorded.Transferred = s.Transferred
+			recorded.Warnings = event.Warnings
+			recorded.RateLimit = &event.RateLimit
+			recorded.Error = errorText(event.Err)
+		case *RepositoryStartedEvent:
+			recorded.Type = recordedStarted
+			repo := event.Repository
+			// The event carries the repository as queued; the state knows when it started
+			if i, ok := index[repo.Key()]; ok {
+				repo.StartedAt = s.Repositories[i].StartedAt
+				repo.QueueWait = s.Repositories[i].QueueWait
+			}
+			recorded.Repository = recordRepository(repo)
+		case *RepositoryProgressEvent:
+			recorded.Type = recordedProgress
+			recorded.Name = event.Key
+			recorded.Progress = event.Progress
+			recorded.TransferSpeed = event.TransferSpeed
+			if i, ok := index[event.Key]; ok {
+				recorded.BytesReceived = s.Repositories[i].BytesReceived
+			}
+		case *RepositoryFinishedEvent:
+			recorded.Type = recordedFinished
+			recorded.Repository = recordRepository(event.Repository)
+		case *PausedEvent:
+			recorded.Type = recordedPaused
+			recorded.Failures = event.Failures
+			recorded.Category = event.Category
+		case *PrunedEvent:
+			recorded.Type = recordedPruned
+			recorded.Pruned = event.Pruned
+			recorded.Kept = event.Kept
+			recorded.Spared = event.Spared
+			recorded.Declined = event.Declined
+			recorded.Error = errorText(event.Err)
+		case *RunFinishedEvent:
+			recorded.Type = recordedRunFinished
+		default:
+			continue
+		}
+		r.Events = append(r.Events, recorded)
+	}
+}
+
+// Save writes the recording to path
+func (r *Recording) Save(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+	if err := WriteFileAtomic(path, data, 0o644, false); err != nil {
+		return fmt.Errorf("failed to save recording: %w", err)
+	}
+	return nil
+}
+
+// LoadRecording reads a recording saved with Save
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	var r Recording
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse recording: %w", err)
+	}
+	if err := schema.Check(r.Schema, schema.RecordingSchema); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return &r, nil
+}
+
+// replay plays opts.Replay back as the run, opts.ReplaySpeed times as fast as it was
+// recorded. Times of the recording are moved onto the timeline of the replay, so that
+// durations and the ETA come out as they did, divided by the speed. A recording cut
+// short by quitting ends the run where it stops.
+func (e *Engine) replay(ctx context.Context, opts Options, events chan<- Event) {
+	defer close(events)
+	recording := opts.Replay
+	speed := opts.ReplaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+	state := e.update(func(s *State) {
+		s.RunID = recording.RunID
+	})
+	at := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+		return state.StartedAt.Add(time.Duration(float64(t.Sub(recording.StartedAt)) / speed))
+	}
+
+	for _, recorded := range recording.Events {
+		if !sleepContext(ctx, time.Until(state.StartedAt.Add(time.Duration(float64(recorded.At)/speed)))) {
+			break
+		}
+		if recorded.Type == recordedRunFinished {
+			break
+		}
+		if event := e.replayEvent(recorded, at, speed); event != nil {
+			events <- event
+		}
+	}
+
+	state = e.update(func(s *State) {
+		s.Done = true
+		s.FinishedAt = time.Now()
+		s.Paused = false
+		s.PausedBy = ""
+		e.running = false
+	})
+	events <- &RunFinishedEvent{Report: state.Report()}
+}
+
+// replayEvent applies a recorded event to the state of the run and returns the event
+// to deliver for it
+func (e *Engine) replayEvent(recorded RecordedEvent, at func(time.Time) time.Time, speed float64) Event {
+	var event Event
+	e.update(func(s *State) {
+		switch recorded.Type {
+		case recordedDiscoveryProgress:
+			s.ListedPages = recorded.Pages
+			s.Listed = recorded.Listed
+			event = &DiscoveryProgressEvent{Pages: recorded.Pages, Repositories: recorded.Listed}
+		case recordedRateLimited:
+			until := time.Now().Add(time.Duration(float64(recorded.Wait) / speed))
+			s.RateLimitedUntil = until
+			s.DiscoveryAttempts++
+			if recorded.RateLimit != nil {
+				s.RateLimit = *recorded.RateLimit
+			}
+			event = &RateLimitedEvent{Until: until, RateLimit: s.RateLimit}
+		case recordedDiscovered:
+			s.Repositories = make([]Repository, 0, len(recorded.Repositories))
+			e.index = make(map[string]int, len(recorded.Repositories))
+			for i, repo := range recorded.Repositories {
+				s.Repositories = append(s.Repositories, repo.repository(at, speed))
+				e.index[s.Repositories[i].Key()] = i
+			}
+			s.Warnings = recorded.Warnings
+			s.Transferred = recorded.Transferred
+			s.RateLimitedUntil = time.Time{}
+			if recorded.RateLimit != nil {
+				s.RateLimit = *recorded.RateLimit
+			}
+			var err error
+			if recorded.Error != "" {
+				err = errors.New(recorded.Error)
+				s.Errors = append(s.Errors, err)
+			}
+			event = &DiscoveredEvent{RunID: s.RunID, Repositories: slices.Clone(s.Repositories), Warnings: s.Warnings, RateLimit: s.RateLimit, Err: err}
+		case recordedStarted, recordedFinished:
+			if recorded.Repository == nil {
+				return
+			}
+			repo := recorded.Repository.repository(at, speed)
+			if i, ok := e.index[repo.Key()]; ok {
+				s.Repositories[i] = repo
+			} else {
+				e.index[repo.Key()] = len(s.Repositories)
+				s.Repositories = append(s.Repositories, repo)
+			}
+			if recorded.Type == recordedFinished {
+				event = &RepositoryFinishedEvent{Repository: repo}
+				return
+			}
+			// Repositories only start again once a paused run was resumed
+			s.Paused = false
+			s.PausedBy = ""
+			event = &RepositoryStartedEvent{Repository: repo}
+		case recordedProgress:
+			i, ok := e.index[recorded.Name]
+			if !ok {
+				// Older recordings name the repository without its owner
+				i = slices.IndexFunc(s.Repositories, func(repo Repository) bool { return repo.Name == recorded.Name })
+				ok = i >= 0
+			}
+			if !ok || s.Repositories[i].Done {
+				return
+			}
+			repo := &s.Repositories[i]
+			repo.Progress = recorded.Progress
+			repo.TransferSpeed = recorded.TransferSpeed
+			delta := recorded.BytesReceived - repo.BytesReceived
+			if delta < 0 {
+				delta = recorded.BytesReceived
+			}
+			repo.BytesReceived = recorded.BytesReceived
+			s.Received += delta
+			e.meter.add(time.Now(), s.Received)
+			event = &RepositoryProgressEvent{Key: s.Repositories[i].Key(), Progress: recorded.Progress, TransferSpeed: recorded.TransferSpeed}
+		case recordedPaused:
+			s.Paused = true
+			s.PausedBy = recorded.Category
+			event = &PausedEvent{Failures: recorded.Failures, Category: recorded.Category}
+		case recordedPruned:
+			s.Pruned = recorded.Pruned
+			s.Kept = recorded.Kept
+			s.Spared = recorded.Spared
+			s.Declined = recorded.Declined
+			var err error
+			if recorded.Error != "" {
+				err = errors.New(recorded.Error)
+			}
+			event = &PrunedEvent{Pruned: recorded.Pruned, Kept: recorded.Kept, Spared: recorded.Spared, Declined: recorded.Declined, Err: err}
+		}
+	})
+	return event
+}
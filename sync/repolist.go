@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ReadRepoList reads a list of repositories, one "repo" or "owner/repo" per line, e.g.
+// for --repos-file. Blank lines and lines starting with # are ignored, and so is
+// whatever follows the name on its line, such as the description and visibility
+// columns that `gh repo list` prints.
+func ReadRepoList(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		names = append(names, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %w", err)
+	}
+	return names, nil
+}
+
+// validateRepos checks the settings of a run synchronizing the repositories Repos
+// names. Nothing is known about them but their names, so the filters and features that
+// need what discovery reports about each repository cannot be used.
+func (o Options) validateRepos() error {
+	if len(o.Repos) == 0 {
+		return nil
+	}
+	switch {
+	case o.Team != "":
+		return errors.New("a team cannot select from an explicit list of repositories")
+	case len(o.Languages) > 0 || strings.Contains(o.Layout, "{language}"):
+		return errors.New("the language of an explicitly listed repository is unknown")
+	case o.MaxSize > 0:
+		return errors.New("the size of an explicitly listed repository is unknown")
+	case o.Profile != "" || !o.Selection.Empty():
+		return errors.New("a profile cannot select from an explicit list of repositories")
+	case o.Prune:
+		return errors.New("pruning needs every repository of the owner to be listed, not an explicit list")
+	case o.MoveArchived:
+		return errors.New("whether an explicitly listed repository is archived is unknown")
+	case o.CI:
+		return errors.New("the default branch of an explicitly listed repository is unknown")
+	}
+	_, err := o.listedRepositories()
+	return err
+}
+
+// listedRepositories returns the repositories Repos names. A bare name belongs to Owner,
+// which is ambiguous when there are several owners, and an owner given with the name
+// must be one of those being synchronized.
+func (o Options) listedRepositories() ([]Repository, error) {
+	owners := o.AllOwners()
+	seen := make(map[string]bool, len(o.Repos))
+	var repos []Repository
+	for _, name := range o.Repos {
+		owner, repo, found := strings.Cut(name, "/")
+		if !found {
+			owner, repo = o.Owner, name
+			if len(owners) > 1 {
+				return nil, fmt.Errorf("repository %s needs its organization, e.g. %s/%s, when several are synchronized", name, owners[0], name)
+			}
+		} else if i := slices.IndexFunc(owners, func(o string) bool { return strings.EqualFold(o, owner) }); i >= 0 {
+			owner = owners[i]
+		} else {
+			return nil, fmt.Errorf("repository %s does not belong to %s", name, strings.Join(owners, ", "))
+		}
+		if repo == "" || strings.ContainsAny(repo, "/\\") || repo == "." || repo == ".." {
+			return nil, fmt.Errorf("invalid repository name %q", name)
+		}
+		if key := strings.ToLower(owner + "/" + repo); !seen[key] {
+			seen[key] = true
+			repos = append(repos, Repository{Owner: owner, Name: repo, Gist: o.Target == TargetGists})
+		}
+	}
+	return repos, nil
+}
+
+// listRepositories selects the repositories Repos names instead of discovering those
+// of the owner. The list is taken as it is: the rules of the ignore file do not apply
+// to it, while the policy still does.
+func listRepositories(opts Options) discoveryResult {
+	repos, err := opts.listedRepositories()
+	if err != nil {
+		return discoveryResult{Err: err}
+	}
+	repos = skipCompleted(filterOnly(filterPermitted(repos, opts.Policy), opts.Only), opts.Completed)
+	logger.Info("listed repositories", "target", opts.label(), "listed", len(opts.Repos), "selected", len(repos))
+	// Without an upstream list, nothing is taken to have been renamed or deleted upstream
+	return discoveryResult{Repositories: repos}
+}
@@ -0,0 +1,189 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// Repository statuses used in reports
+const (
+	StatusSuccess   = schema.StatusSuccess
+	StatusFailed    = schema.StatusFailed
+	StatusCancelled = schema.StatusCancelled
+	StatusConflict  = schema.StatusConflict
+	StatusSkipped   = schema.StatusSkipped
+	StatusPending   = schema.StatusPending
+)
+
+// The report types are defined in the schema package, which documents their
+// compatibility guarantees
+type (
+	Report           = schema.Report
+	ReportTotals     = schema.ReportTotals
+	RepositoryReport = schema.RepositoryReport
+)
+
+// Report summarizes the run so far
+func (s State) Report() Report {
+	report := Report{
+		Schema:      schema.ReportSchema,
+		RunID:       s.RunID,
+		Target:      s.Options.label(),
+		StartedAt:   s.StartedAt,
+		FinishedAt:  time.Now(),
+		Pruned:      s.Pruned,
+		Transferred: s.Transferred,
+		Warnings:    s.Warnings,
+	}
+	for _, err := range s.Errors {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Totals.Warnings = len(s.Warnings)
+	report.Totals.DurationSeconds = report.FinishedAt.Sub(report.StartedAt).Seconds()
+
+	report.Repositories = slices.Grow(report.Repositories, len(s.Repositories))
+	var waits, durations []time.Duration
+	for _, repo := range s.Repositories {
+		r := RepositoryReport{
+			Owner:     repo.Owner,
+			Name:      repo.Name,
+			Status:    StatusPending,
+			StartedAt: repo.StartedAt,
+			Attempts:  repo.Attempts,
+			Bytes:     repo.BytesReceived,
+			Size:      repo.Size,
+			Warnings:  repo.Warnings,
+			CI:        repo.CI,
+			LogFile:   repo.LogFile,
+			Archive:   repo.ArchiveFile,
+			Archived:  repo.Archived,
+			Protocol:  repo.Protocol,
+		}
+		if r.CI != "" {
+			if report.Totals.CI == nil {
+				report.Totals.CI = make(map[string]int)
+			}
+			report.Totals.CI[r.CI]++
+		}
+		if !repo.FinishedAt.IsZero() {
+			r.DurationSeconds = repo.FinishedAt.Sub(repo.StartedAt).Seconds()
+			r.QueueWaitSeconds = repo.QueueWait.Seconds()
+			waits = append(waits, repo.QueueWait)
+			durations = append(durations, repo.FinishedAt.Sub(repo.StartedAt))
+		}
+
+		switch {
+		case errors.Is(repo.Err, ErrCancelled):
+			r.Status = StatusCancelled
+			r.Error = repo.Err.Error()
+			r.ErrorCategory = CategoryCancelled
+			report.Totals.Cancelled++
+		case errors.Is(repo.Err, ErrConflict):
+			r.Status = StatusConflict
+			r.Error = repo.Err.Error()
+			r.ErrorCategory = CategoryConflict
+			r.Hint = Hint(CategoryConflict)
+			report.Totals.Conflicts++
+		case SkipReason(repo.Err) != "":
+			r.Status = StatusSkipped
+			r.Error = repo.Err.Error()
+			r.ErrorCategory = ClassifyError(repo.Err)
+			r.Hint = Hint(r.ErrorCategory)
+			report.Totals.Skipped++
+		case repo.Err != nil:
+			r.Status = StatusFailed
+			r.Error = repo.Err.Error()
+			r.Output = ErrorOutput(repo.Err)
+			r.ErrorCategory = ClassifyError(repo.Err)
+			r.Hint = Hint(r.ErrorCategory)
+			report.Totals.Failed++
+			if report.Totals.ErrorCategories == nil {
+				report.Totals.ErrorCategories = make(map[string]int)
+			}
+			report.Totals.ErrorCategories[r.ErrorCategory]++
+		case repo.Done:
+			r.Status = StatusSuccess
+			r.UpToDate = repo.UpToDate
+			r.Updated = repo.Updated
+			report.Totals.Succeeded++
+			if repo.UpToDate {
+				report.Totals.UpToDate++
+			}
+		default:
+			report.Totals.Pending++
+		}
+		r.Unpushed, r.LocalBranches = repo.Unpushed, repo.LocalBranches
+		if r.Unpushed > 0 || len(r.LocalBranches) > 0 {
+			report.Totals.Unpushed++
+		}
+		if repo.Upstream != "" {
+			ahead, behind := repo.Ahead, repo.Behind
+			r.Upstream, r.Ahead, r.Behind = repo.Upstream, &ahead, &behind
+		}
+		if len(s.Options.Exec) > 0 && !repo.FinishedAt.IsZero() {
+			code := repo.ExitCode
+			r.ExitCode = &code
+			r.Output = repo.ExecOutput()
+		}
+
+		report.Totals.Repositories++
+		report.Totals.Bytes += r.Bytes
+		report.Totals.Warnings += len(r.Warnings)
+		report.Repositories = append(report.Repositories, r)
+	}
+
+	if len(waits) > 0 {
+		p95 := percentile(waits, 0.95)
+		report.Totals.QueueWaitP95Seconds = p95.Seconds()
+		report.Totals.Bottleneck = bottleneck(p95, percentile(durations, 0.5))
+	}
+	return report
+}
+
+func init() {
+	RegisterFormatter("json", FormatterFunc(writeJSON))
+	RegisterFormatter("ndjson", FormatterFunc(writeNDJSON))
+}
+
+// ValidReportFormat reports whether format is supported by Report.Write
+func ValidReportFormat(format string) bool {
+	_, err := LookupFormatter(format)
+	return err == nil
+}
+
+// WriteReport encodes the report in the given registered format, e.g. "json" or "junit"
+func WriteReport(w io.Writer, r Report, format string) error {
+	f, err := LookupFormatter(format)
+	if err != nil {
+		return err
+	}
+	return f.Format(w, r)
+}
+
+// writeJSON writes the whole report as one indented JSON document
+func writeJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// writeNDJSON writes a schema.RepositoryEvent per line for every repository
+func writeNDJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	for _, repo := range r.Repositories {
+		event := schema.RepositoryEvent{Schema: schema.EventSchema, RunID: r.RunID, RepositoryReport: repo}
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	return nil
+}
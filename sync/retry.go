@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// maxRetryBackoff caps the exponential wait between attempts
+const maxRetryBackoff = 15 * time.Minute
+
+// RetryRule is how failures of one error category are retried
+type RetryRule struct {
+	// Attempts is the number of tries including the first, so 1 never retries
+	Attempts int `json:"attempts"`
+	// Backoff is the wait before the first retry, such as "30s", doubling with every
+	// further one. Rate limits wait for the quota to reset instead when it is exhausted.
+	Backoff string `json:"backoff,omitempty"`
+}
+
+// RetryPolicy maps error categories to how their failures are retried. Categories it
+// does not mention fall back to the default policy.
+type RetryPolicy map[string]RetryRule
+
+// defaultRetryPolicy retries rate limits only; every other failure is reported at once
+var defaultRetryPolicy = RetryPolicy{
+	CategoryRateLimit: {Attempts: 5, Backoff: "1m"},
+}
+
+// Validate returns an error if the policy names an unknown category or cannot be followed
+func (p RetryPolicy) Validate() error {
+	for category, rule := range p {
+		switch category {
+		case CategoryAuth, CategoryRateLimit, CategoryNotFound, CategoryNetwork, CategoryDisk, CategoryConflict, CategoryUnknown:
+		case CategoryCancelled, CategoryDirty, CategoryTooLarge:
+			return fmt.Errorf("%s failures are never retried", category)
+		default:
+			return fmt.Errorf("unknown error category %q", category)
+		}
+		if rule.Attempts < 1 {
+			return fmt.Errorf("%s: attempts must be at least 1", category)
+		}
+		if rule.Backoff != "" {
+			if d, err := time.ParseDuration(rule.Backoff); err != nil || d < 0 {
+				return fmt.Errorf("%s: invalid backoff %q", category, rule.Backoff)
+			}
+		}
+	}
+	return nil
+}
+
+// Rule returns how failures of category are retried
+func (p RetryPolicy) Rule(category string) RetryRule {
+	rule, ok := p[category]
+	fallback, hasFallback := defaultRetryPolicy[category]
+	switch {
+	case !ok && hasFallback:
+		return fallback
+	case !ok:
+		return RetryRule{Attempts: 1}
+	case rule.Backoff == "":
+		// Keep the default wait when a rule only changes the number of attempts
+		rule.Backoff = fallback.Backoff
+	}
+	return rule
+}
+
+// retries reports whether a failure of category after the given attempt is tried again
+func (p RetryPolicy) retries(category string, attempt int) bool {
+	return category != "" && category != CategoryCancelled && category != CategoryDirty && category != CategoryTooLarge && attempt < p.Rule(category).Attempts
+}
+
+// backoff returns how long to wait before retrying after the given attempt failed. Rate
+// limits fetch the API quota and wait until it resets when it is exhausted.
+func (p RetryPolicy) backoff(category string, attempt int) (time.Duration, RateLimit) {
+	base, _ := time.ParseDuration(p.Rule(category).Backoff)
+	if category == CategoryRateLimit {
+		limit, _ := fetchRateLimit()
+		return rateLimitBackoff(attempt, limit, base, time.Now()), limit
+	}
+	return exponentialBackoff(attempt, base), RateLimit{}
+}
+
+// exponentialBackoff doubles base for every attempt after the first, up to maxRetryBackoff
+func exponentialBackoff(attempt int, base time.Duration) time.Duration {
+	shift := max(attempt-1, 0)
+	// Once base doubled past the cap it stays there, and shifting any further could
+	// overflow into a wait of zero or less than the cap
+	if base > 0 && shift >= bits.Len64(uint64(maxRetryBackoff/base)) {
+		return maxRetryBackoff
+	}
+	return min(base<<shift, maxRetryBackoff)
+}
@@ -0,0 +1,36 @@
+package sync
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewRunID returns a ULID for a run started at t: 48 bits of millisecond timestamp
+// followed by 80 random bits, so IDs sort by start time across scheduled runs
+func NewRunID(t time.Time) string {
+	var id [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	_, _ = rand.Read(id[6:])
+
+	// Encode the 128 bits as 26 characters, 5 bits at a time from the most significant end
+	var out [26]byte
+	var acc uint32
+	bits := 2 // pad to 130 bits so the first character holds the top 3 bits
+	i := 0
+	for _, b := range id {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[i] = crockford[(acc>>bits)&0x1f]
+			i++
+		}
+	}
+	return string(out[:])
+}
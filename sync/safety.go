@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+)
+
+// guardDirty refuses to go on with action, such as "rewriting origin", in the existing
+// clone at repoDir while its working tree has local changes, unless opts.Force. Bare
+// and mirror clones have no working tree to lose anything from.
+func guardDirty(ctx context.Context, opts Options, repoDir, action string) error {
+	if opts.Force || opts.Mirror || opts.Bare {
+		return nil
+	}
+	changes, err := localChanges(ctx, repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to check the working tree before %s: %w", action, err)
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("%w: not %s with %d local changes, such as %q, without --force", ErrDirty, action, len(changes), changes[0])
+	}
+	return nil
+}
+
+// holdsWork reports whether deleting the clone in dir would lose anything: local
+// changes, or commits and branches that are on no remote. A working tree that cannot
+// be checked counts as holding work. Bare clones are never looked into, since their
+// branches are those of origin rather than local work.
+func holdsWork(ctx context.Context, dir string) bool {
+	if isBareRepository(dir) {
+		return false
+	}
+	if changes, err := localChanges(ctx, dir); err != nil || len(changes) > 0 {
+		return true
+	}
+	commits, branches := unpushedWork(ctx, dir)
+	return commits > 0 || len(branches) > 0
+}
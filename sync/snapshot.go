@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// StatusSyncing marks a repository whose clone or fetch is transferring data
+const StatusSyncing = schema.StatusSyncing
+
+// The snapshot types are defined in the schema package. A RunSnapshot is the single
+// data model for every view of a run from outside the TUI, such as the status file.
+type (
+	RunSnapshot        = schema.RunSnapshot
+	RepositorySnapshot = schema.RepositorySnapshot
+)
+
+// Snapshot captures the current state of the run
+func (s State) Snapshot() RunSnapshot {
+	snapshot := RunSnapshot{
+		Status: Status{
+			RunID:     s.RunID,
+			Target:    s.Options.label(),
+			Total:     len(s.Repositories),
+			Done:      s.Done,
+			UpdatedAt: time.Now(),
+		},
+		Schema:       schema.SnapshotSchema,
+		Owner:        s.Options.Owner,
+		StartedAt:    s.StartedAt,
+		Stopped:      s.Stopped,
+		Repositories: make([]RepositorySnapshot, 0, len(s.Repositories)),
+	}
+
+	for _, repo := range s.Repositories {
+		r := RepositorySnapshot{
+			Owner:         repo.Owner,
+			Name:          repo.Name,
+			State:         StatusPending,
+			Progress:      repo.Progress,
+			TransferSpeed: repo.TransferSpeed,
+			StartedAt:     repo.StartedAt,
+			FinishedAt:    repo.FinishedAt,
+			Attempts:      repo.Attempts,
+			Bytes:         repo.BytesReceived,
+			CI:            repo.CI,
+		}
+		if !repo.FinishedAt.IsZero() {
+			r.DurationSeconds = repo.FinishedAt.Sub(repo.StartedAt).Seconds()
+			r.QueueWaitSeconds = repo.QueueWait.Seconds()
+		}
+		if repo.Err != nil {
+			r.Error = repo.Err.Error()
+			r.ErrorCategory = ClassifyError(repo.Err)
+		}
+
+		switch {
+		case errors.Is(repo.Err, ErrCancelled):
+			r.State = StatusCancelled
+		case errors.Is(repo.Err, ErrConflict):
+			r.State = StatusConflict
+		case SkipReason(repo.Err) != "":
+			r.State = StatusSkipped
+		case repo.Err != nil:
+			r.State = StatusFailed
+		case repo.Done:
+			r.State = StatusSuccess
+		case repo.Progress > 0:
+			r.State = StatusSyncing
+		}
+
+		if repo.Done {
+			snapshot.Completed++
+		}
+		if repo.Err != nil && SkipReason(repo.Err) == "" {
+			snapshot.Failed++
+		}
+		if repo.CI == CIFailure {
+			snapshot.CIFailing++
+		}
+		snapshot.Bytes += repo.BytesReceived
+		snapshot.Repositories = append(snapshot.Repositories, r)
+	}
+	return snapshot
+}
+
+// FormatSnapshot renders the whole snapshot as indented JSON for "snapshot", and its
+// Status summary for any other format
+func FormatSnapshot(s RunSnapshot, format string) (string, error) {
+	if format != "snapshot" {
+		return FormatStatus(s.Status, format)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return string(data), nil
+}
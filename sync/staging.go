@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stagingDir holds in-progress clones inside the sync root. It must live on the
+// same file system as the repositories so the final rename is atomic.
+const stagingDir = ".orgsync-tmp"
+
+// cloneStaged runs clone into a temporary directory and renames the result to repoDir
+// only once it succeeded, so cancelled or failed clones never leave a partial
+// repository behind for the next run to mistake as complete.
+func cloneStaged(repoDir string, clone func(dir string) error) error {
+	staging := filepath.Join(filepath.Dir(repoDir), stagingDir)
+	if err := os.MkdirAll(staging, 0o755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(staging, filepath.Base(repoDir)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	if err := clone(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, repoDir); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to move clone into place: %w", err)
+	}
+	return nil
+}
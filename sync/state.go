@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// StateFile records per-repository progress in the sync root so that an interrupted
+// run can be resumed with --resume
+const StateFile = ".orgsync-state.json"
+
+// RunState is the persisted progress of a run, defined in the schema package
+type RunState = schema.RunState
+
+// Resumes reports whether state was recorded for the same target as o
+func (o Options) Resumes(state RunState) bool {
+	return state.Owner == o.stateOwner() && state.Target == o.Target
+}
+
+// stateOwner is the owner recorded in the run state: Owner, or every owner of a run
+// synchronizing several, separated by commas
+func (o Options) stateOwner() string {
+	return strings.Join(o.AllOwners(), ",")
+}
+
+// LoadState reads the run state from path
+func LoadState(path string) (RunState, error) {
+	var state RunState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, fmt.Errorf("failed to read run state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse run state: %w", err)
+	}
+	if state.Schema == "" || state.Schema == schema.StateSchemaV1 {
+		return upgradeStateV1(state), nil
+	}
+	if err := schema.Check(state.Schema, schema.StateSchema); err != nil {
+		return state, fmt.Errorf("failed to read run state: %w", err)
+	}
+	return state, nil
+}
+
+// upgradeStateV1 qualifies the repositories a version 1 state lists by name only with
+// their owner. The names of a run synchronizing several owners could belong to any of
+// them, so such a run starts over rather than skip a namesake under another owner.
+func upgradeStateV1(state RunState) RunState {
+	state.Schema = schema.StateSchema
+	switch {
+	case strings.Contains(state.Owner, ","):
+		state.Completed = nil
+	case state.Owner != "":
+		for i, name := range state.Completed {
+			if !strings.Contains(name, "/") {
+				state.Completed[i] = state.Owner + "/" + name
+			}
+		}
+	}
+	return state
+}
+
+// state captures the current progress of the run for resuming
+func (s State) state() RunState {
+	state := RunState{
+		Schema:    schema.StateSchema,
+		RunID:     s.RunID,
+		Owner:     s.Options.stateOwner(),
+		Target:    s.Options.Target,
+		Completed: append([]string{}, s.Options.Completed...),
+		Finished:  s.Done,
+		UpdatedAt: time.Now(),
+	}
+	for _, repo := range s.Repositories {
+		switch {
+		case repo.Done && repo.Err == nil:
+			state.Completed = append(state.Completed, repo.Key())
+		case errors.Is(repo.Err, ErrCancelled):
+			state.Finished = false
+		}
+	}
+	return state
+}
+
+// writeState saves the current progress to the state file. The file is replaced
+// atomically, as --resume depends on it being intact. Like the status file, failures
+// are ignored so that bookkeeping never interrupts a sync. An exec run synchronizes
+// nothing, so it leaves the state of the last sync alone.
+func (s State) writeState() {
+	if len(s.Options.Exec) > 0 {
+		return
+	}
+	data, err := json.MarshalIndent(s.state(), "", "  ")
+	if err != nil {
+		return
+	}
+	_ = WriteFileAtomic(StateFile, data, 0o644, s.Options.Fsync)
+}
+
+// skipCompleted drops the repositories an interrupted run already synchronized, listed
+// as owner/name
+func skipCompleted(repos []Repository, completed []string) []Repository {
+	if len(completed) == 0 {
+		return repos
+	}
+	done := make(map[string]bool, len(completed))
+	for _, name := range completed {
+		done[name] = true
+	}
+	var remaining []Repository
+	for _, repo := range repos {
+		if !done[repo.Key()] {
+			remaining = append(remaining, repo)
+		}
+	}
+	return remaining
+}
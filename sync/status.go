@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jdmcgrath/orgsync/schema"
+)
+
+// DefaultStatusFile is where `orgsync status` looks for progress when no file is given
+const DefaultStatusFile = ".orgsync-status.json"
+
+// Status is a point-in-time summary of a run, defined in the schema package
+type Status = schema.Status
+
+// FormatStatus renders the status as "text", "tmux" or "json"
+func FormatStatus(s Status, format string) (string, error) {
+	switch format {
+	case "text":
+		line := fmt.Sprintf("orgsync %s %d%% (%d/%d)", s.Target, s.Percent(), s.Completed, s.Total)
+		if s.Failed > 0 {
+			line += fmt.Sprintf(" %d failed", s.Failed)
+		}
+		if s.CIFailing > 0 {
+			line += fmt.Sprintf(" %d failing CI", s.CIFailing)
+		}
+		if s.Done {
+			line += " done"
+		}
+		return line, nil
+	case "tmux":
+		color := "yellow"
+		if s.Done {
+			color = "green"
+		}
+		line := fmt.Sprintf("#[fg=%s]orgsync %s %d%%#[default]", color, s.Target, s.Percent())
+		if s.Failed > 0 {
+			line += fmt.Sprintf(" #[fg=red]%d failed#[default]", s.Failed)
+		}
+		if s.CIFailing > 0 {
+			line += fmt.Sprintf(" #[fg=red]%d failing CI#[default]", s.CIFailing)
+		}
+		return line, nil
+	case "json":
+		data, err := json.Marshal(s)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode status: %w", err)
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unknown status format %q", format)
+	}
+}
+
+// ReadSnapshot loads the run snapshot written to a status file by a running orgsync
+func ReadSnapshot(path string) (RunSnapshot, error) {
+	var snapshot RunSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to read status: %w", err)
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("failed to parse status: %w", err)
+	}
+	if err := schema.Check(snapshot.Schema, schema.SnapshotSchema); err != nil {
+		return snapshot, fmt.Errorf("failed to read status: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ReadStatus loads the summary of the run snapshot written to a status file
+func ReadStatus(path string) (Status, error) {
+	snapshot, err := ReadSnapshot(path)
+	return snapshot.Status, err
+}
+
+// Status summarizes the current progress of the run
+func (s State) Status() Status {
+	return s.Snapshot().Status
+}
+
+// writeStatus saves a snapshot of the run to the configured status file, if any.
+// Failures are ignored so that a monitoring hiccup never interrupts a sync.
+func (s State) writeStatus() {
+	if s.Options.StatusFile == "" {
+		return
+	}
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return
+	}
+	_ = WriteFileAtomic(s.Options.StatusFile, data, 0o644, false)
+}
@@ -0,0 +1,80 @@
+package sync
+
+// Subscriber is told what happens during a run, to attach reporting such as logging,
+// metrics or a custom UI to it. Subscribers are set in Options and called by the
+// Engine. Calls come from the workers syncing repositories, so they must be safe for
+// concurrent use and return quickly: a slow OnRepoProgress holds up git.
+type Subscriber interface {
+	// OnRepoStart is called when a repository got a worker and starts syncing
+	OnRepoStart(repo Repository)
+	// OnRepoProgress is called as git reports the transfer progress of the repository
+	// with the given key, as owner/name, as a fraction of the objects received across
+	// it and its submodules
+	OnRepoProgress(key string, progress float64, speed string)
+	// OnRepoDone is called with the outcome of a repository, with Done set and Err
+	// telling whether and how it failed. Skipped and cancelled repositories are
+	// done without having started.
+	OnRepoDone(repo Repository)
+	// OnRunComplete is called with the report once every repository is done
+	OnRunComplete(report Report)
+}
+
+// SubscriberFuncs is a Subscriber that calls whichever of its functions are set
+type SubscriberFuncs struct {
+	RepoStart    func(repo Repository)
+	RepoProgress func(key string, progress float64, speed string)
+	RepoDone     func(repo Repository)
+	RunComplete  func(report Report)
+}
+
+func (f SubscriberFuncs) OnRepoStart(repo Repository) {
+	if f.RepoStart != nil {
+		f.RepoStart(repo)
+	}
+}
+
+func (f SubscriberFuncs) OnRepoProgress(key string, progress float64, speed string) {
+	if f.RepoProgress != nil {
+		f.RepoProgress(key, progress, speed)
+	}
+}
+
+func (f SubscriberFuncs) OnRepoDone(repo Repository) {
+	if f.RepoDone != nil {
+		f.RepoDone(repo)
+	}
+}
+
+func (f SubscriberFuncs) OnRunComplete(report Report) {
+	if f.RunComplete != nil {
+		f.RunComplete(report)
+	}
+}
+
+// repoStarted tells the subscribers that repo starts syncing
+func (o Options) repoStarted(repo Repository) {
+	for _, s := range o.Subscribers {
+		s.OnRepoStart(repo)
+	}
+}
+
+// repoProgressed tells the subscribers about the transfer progress of a repository
+func (o Options) repoProgressed(key string, progress float64, speed string) {
+	for _, s := range o.Subscribers {
+		s.OnRepoProgress(key, progress, speed)
+	}
+}
+
+// repoDone tells the subscribers the outcome of repo
+func (o Options) repoDone(repo Repository) {
+	for _, s := range o.Subscribers {
+		s.OnRepoDone(repo)
+	}
+}
+
+// runComplete hands the report of a finished run to the subscribers
+func (o Options) runComplete(report Report) {
+	for _, s := range o.Subscribers {
+		s.OnRunComplete(report)
+	}
+}
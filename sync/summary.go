@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteSummary writes a human-readable summary of the report, including the full
+// details of every failure
+func WriteSummary(w io.Writer, r Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "orgsync %s\n", r.Target)
+	fmt.Fprintf(&b, "Run:      %s\n", r.RunID)
+	fmt.Fprintf(&b, "Started:  %s\n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Finished: %s\n", r.FinishedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Duration: %s\n\n", time.Duration(r.Totals.DurationSeconds*float64(time.Second)).Round(time.Second))
+
+	for _, err := range r.Errors {
+		fmt.Fprintf(&b, "Error: %s\n\n", err)
+	}
+
+	t := r.Totals
+	fmt.Fprintf(&b, "Repositories: %d\n", t.Repositories)
+	fmt.Fprintf(&b, "Succeeded:    %d\n", t.Succeeded)
+	if t.UpToDate > 0 {
+		fmt.Fprintf(&b, "Up to date:   %d\n", t.UpToDate)
+	}
+	fmt.Fprintf(&b, "Failed:       %d\n", t.Failed)
+	if t.Conflicts > 0 {
+		fmt.Fprintf(&b, "Conflicts:    %d\n", t.Conflicts)
+	}
+	if t.Skipped > 0 {
+		fmt.Fprintf(&b, "Skipped:      %d\n", t.Skipped)
+	}
+	if t.Cancelled > 0 {
+		fmt.Fprintf(&b, "Cancelled:    %d\n", t.Cancelled)
+	}
+	if t.Pending > 0 {
+		fmt.Fprintf(&b, "Pending:      %d\n", t.Pending)
+	}
+	fmt.Fprintf(&b, "Transferred:  %s\n", FormatBytes(t.Bytes))
+	if t.Bottleneck != "" {
+		fmt.Fprintf(&b, "Queue wait:   %s p95 (%s-bound)\n", seconds(t.QueueWaitP95Seconds).Round(time.Millisecond), t.Bottleneck)
+	}
+
+	if t.Warnings > 0 {
+		fmt.Fprintf(&b, "Warnings:     %d\n", t.Warnings)
+	}
+	if n := t.CI[CIFailure]; n > 0 {
+		fmt.Fprintf(&b, "Failing CI:   %d\n", n)
+	}
+	if t.Unpushed > 0 {
+		fmt.Fprintf(&b, "Unpushed:     %d\n", t.Unpushed)
+	}
+
+	if warning := QueueWarning(r); warning != "" {
+		fmt.Fprintf(&b, "\n%s\n", warning)
+	}
+
+	if len(r.Pruned) > 0 {
+		fmt.Fprintf(&b, "\nPruned: %s\n", strings.Join(r.Pruned, ", "))
+	}
+	if archived := ArchivedNames(r); len(archived) > 0 {
+		fmt.Fprintf(&b, "\nArchived upstream: %s\n", strings.Join(archived, ", "))
+	}
+	if len(r.Transferred) > 0 {
+		fmt.Fprintf(&b, "\nTransferred to other owners:\n")
+		for _, transferred := range r.Transferred {
+			fmt.Fprintf(&b, "  %s\n", transferred)
+		}
+	}
+
+	if t.Unpushed > 0 {
+		fmt.Fprintf(&b, "\nWork on no remote:\n")
+		for _, repo := range r.Repositories {
+			if repo.Unpushed > 0 || len(repo.LocalBranches) > 0 {
+				fmt.Fprintf(&b, "  %s: %s\n", repo.Name, UnpushedText(Repository{Unpushed: repo.Unpushed, LocalBranches: repo.LocalBranches}))
+			}
+		}
+	}
+
+	for _, repo := range r.Repositories {
+		if repo.Status != StatusFailed && repo.Status != StatusConflict && repo.Status != StatusSkipped {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s [%s]\n", repo.Name, repo.ErrorCategory)
+		fmt.Fprintf(&b, "  %s\n", repo.Error)
+		if repo.Hint != "" {
+			fmt.Fprintf(&b, "  Hint: %s\n", repo.Hint)
+		}
+		for _, line := range strings.Split(repo.Output, "\n") {
+			if line != "" {
+				fmt.Fprintf(&b, "  | %s\n", line)
+			}
+		}
+	}
+
+	if t.Warnings > 0 {
+		fmt.Fprintf(&b, "\nWarnings\n")
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(&b, "  %s\n", warning)
+		}
+		for _, repo := range r.Repositories {
+			for _, warning := range repo.Warnings {
+				fmt.Fprintf(&b, "  %s: %s\n", repo.Name, warning)
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
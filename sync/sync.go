@@ -2,275 +2,1021 @@ package sync
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbles/progress"
-	"github.com/charmbracelet/bubbles/spinner"
-	"github.com/charmbracelet/bubbles/table"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/schema"
 )
 
 type Repository struct {
-	Name string
+	Owner string
+	Name  string
+	// Gist marks the repository as a gist, identified by its ID in Name
+	Gist bool
 	Done bool
 	Err  error
+	// Progress is the fraction of objects received by the running clone or fetch
+	Progress float64
+	// TransferSpeed is git's reported download rate, e.g. "2.40 MiB/s"
+	TransferSpeed string
+	// Size is the disk usage reported by the GitHub API, in bytes
+	Size int64
+	// Language, Topics, PushedAt, DefaultBranch, Visibility and Archived are repository
+	// metadata from the GitHub API. Visibility is "public", "private" or "internal".
+	Language      string
+	Topics        []string
+	PushedAt      time.Time
+	DefaultBranch string
+	Visibility    string
+	Archived      bool
+	// CI is the outcome of the latest workflow run on the default branch, when requested
+	CI string
+	// BytesReceived is the amount of data git reported transferring, kept up to date
+	// while the repository syncs
+	BytesReceived int64
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	Attempts      int
+	// QueueWait is how long the repository waited for a free worker before syncing
+	QueueWait time.Duration
+	// History records every attempt at synchronizing the repository
+	History []Attempt
+	// Warnings are non-fatal notices git or gh printed while syncing
+	Warnings []string
+	// UpToDate marks an existing clone that already matched origin, so nothing was fetched
+	UpToDate bool
+	// Updated marks an existing clone that fetched new commits or refs from origin
+	Updated bool
+	// Protocol is the protocol a new clone was made over, "https" or "ssh"
+	Protocol string
+	// LogFile is where the transcripts of the attempts were saved, if they were
+	LogFile string
+	// ArchiveFile is the archive holding the repository at its current HEAD, when
+	// archives are packed, and Packed marks one that was packed during this run
+	ArchiveFile string
+	Packed      bool
+	// Ahead and Behind count the commits HEAD has that Upstream lacks and the other way
+	// round, once a working tree was fetched; Upstream is the branch of origin it was
+	// compared with, e.g. origin/main, and empty when it was not
+	Upstream string
+	Ahead    int
+	Behind   int
+	// Unpushed counts the commits of HEAD and the local branches that no remote branch
+	// contains, and LocalBranches are the branches no remote has, once a working tree
+	// was fetched or skipped for local changes
+	Unpushed      int
+	LocalBranches []string
+	// Dir is where the clone is relative to the sync root when that is known beforehand,
+	// as for an exec run over the manifest; otherwise it follows from the layout
+	Dir string
+	// ExitCode is the exit status of the command of an exec run, or -1 when it did not
+	// run to completion
+	ExitCode int
 }
 
-type Model struct {
-	Org          string
-	Repositories []Repository
-	Done         bool
-	Errors       []error
-	Progress     progress.Model
-	Spinner      spinner.Model
-	Table        table.Model
-	Width        int
-	Height       int
+// Key identifies the repository within a run as owner/name, since the owners synced
+// together may each have a repository of the same name
+func (r Repository) Key() string {
+	if r.Owner == "" {
+		return r.Name
+	}
+	return r.Owner + "/" + r.Name
+}
+
+// Attempt records one try at synchronizing a repository
+type Attempt struct {
+	// Commands are the git and gh command lines that were run
+	Commands  []string
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
+	// Output is everything the commands wrote to stderr, minus progress chatter
+	Output string
+	// Transcript is the complete stdout and stderr of every command, kept in verbose mode
+	Transcript string
 }
 
+// Target identifies the kind of GitHub account being synchronized
+type Target = schema.Target
+
 const (
-	padding  = 2
-	maxWidth = 80
+	TargetOrg   = schema.TargetOrg
+	TargetUser  = schema.TargetUser
+	TargetGists = schema.TargetGists
 )
 
-var (
-	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFDD00")).Background(lipgloss.Color("#336699"))
-	pendingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500")) // Orange
-	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")) // Red
-	spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
-	normalText   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
-)
+// gistsDir is where gists are cloned, relative to the sync root
+const gistsDir = "gists"
+
+// Options configures what a run synchronizes
+type Options struct {
+	// Owner is empty for TargetGists to select the authenticated user's gists
+	Owner  string `json:"owner"`
+	Target Target `json:"target"`
+	// Owners lists every organization of a run synchronizing several at once, Owner
+	// being the first of them. Their repositories need a Layout with {owner} or {org}.
+	Owners []string `json:"owners,omitempty"`
+	// Collaborations includes repositories the user collaborates on but does not own
+	Collaborations bool `json:"collaborations,omitempty"`
+	// FailFast cancels all remaining work after the first failure
+	FailFast bool `json:"fail_fast,omitempty"`
+	// PauseAfter pauses the run after this many repositories in a row failed with
+	// network or authentication errors, until it is resumed or aborted; 0 never pauses
+	PauseAfter int `json:"pause_after,omitempty"`
+	// StatusFile, when set, receives a JSON Status snapshot whenever progress changes
+	StatusFile string `json:"status_file,omitempty"`
+	// Only restricts the run to the named repositories, as "repo" or "owner/repo"
+	Only []string `json:"only,omitempty"`
+	// Repos, when set, are the repositories to synchronize, as "repo" or "owner/repo",
+	// instead of those discovered by listing the owners. Nothing but their names is
+	// known about them, so filters needing more cannot be combined with it.
+	Repos []string `json:"repos,omitempty"`
+	// Host is the GitHub host being synchronized, e.g. github.com
+	Host string `json:"host,omitempty"`
+	// Policy limits which owners may be synchronized
+	Policy Policy `json:"-"`
+	// Retry decides per error category how often and how patiently failures are retried
+	Retry RetryPolicy `json:"-"`
+	// Hooks are the commands run around each repository and after the run
+	Hooks Hooks `json:"-"`
+	// Notify is where finished runs are reported
+	Notify Notify `json:"-"`
+	// Ignore excludes repositories from discovery, as read from IgnoreFile
+	Ignore IgnoreRules `json:"-"`
+	// Team restricts an organization sync to the repositories of the team with this slug
+	Team string `json:"team,omitempty"`
+	// Languages keeps only repositories whose primary language is one of these,
+	// compared case-insensitively
+	Languages []string `json:"languages,omitempty"`
+	// MaxSize skips repositories whose reported disk usage exceeds it, in bytes. 0
+	// allows any size.
+	MaxSize int64 `json:"max_size,omitempty"`
+	// Watch keeps the program running and starts the next run this long after each
+	// run is done; 0 runs once
+	Watch time.Duration `json:"watch,omitempty"`
+	// MetricsAddr is where watch mode serves Prometheus metrics, if anywhere
+	MetricsAddr string `json:"metrics_addr,omitempty"`
+	// Verbose keeps the complete output of every git and gh command for the detail pane
+	Verbose bool `json:"verbose,omitempty"`
+	// SaveLogs also writes that output to a log file per repository under .orgsync/logs
+	SaveLogs bool `json:"save_logs,omitempty"`
+	// Prune deletes local clones whose repositories no longer exist upstream
+	Prune bool `json:"prune,omitempty"`
+	// Confirm has the run wait for Engine.ConfirmPrune before it prunes anything, so
+	// that an interactive frontend can ask first
+	Confirm bool `json:"confirm,omitempty"`
+	// MoveArchived moves the clones of archived repositories, and of those transferred
+	// to another owner, into ArchiveDir instead of fetching them
+	MoveArchived bool `json:"move_archived,omitempty"`
+	// FixRemotes rewrites the origin of an existing clone whose host differs from Host,
+	// or whose protocol differs from Protocol when that is https or ssh, before fetching
+	FixRemotes bool `json:"fix_remotes,omitempty"`
+	// OnConflict decides what to do with a local directory whose origin is a different
+	// repository: ConflictSkip (the default), ConflictAdopt or ConflictRelocate
+	OnConflict string `json:"on_conflict,omitempty"`
+	// Checkout switches clean working trees to the default branch and fast-forwards
+	// it after fetching
+	Checkout bool `json:"checkout,omitempty"`
+	// Stash saves local changes before --checkout or --recurse-submodules touch a working
+	// tree and restores them afterwards, instead of skipping the repository
+	Stash bool `json:"stash,omitempty"`
+	// NoTouchWorktree guarantees that existing working trees are never modified: only
+	// new clones and fetches are allowed, enforced for every git command that is run
+	NoTouchWorktree bool `json:"no_touch_worktree,omitempty"`
+	// Force lets checkouts, submodule updates and origin rewrites go ahead in working
+	// trees with local changes, and prunes clones holding work that is on no remote.
+	// It is never saved for rerun, so that every forced run is asked for explicitly.
+	Force bool `json:"-"`
+	// Layout places each clone at a path relative to the sync root, expanded from
+	// {owner}, {org}, {language} and {repo}; empty means DefaultLayout
+	Layout string `json:"layout,omitempty"`
+	// IgnoreDiskSpace starts new clones even when they look too big for the free disk
+	// space, reporting a warning instead
+	IgnoreDiskSpace bool `json:"ignore_disk_space,omitempty"`
+	// FetchPrune deletes remote-tracking branches whose upstream branch was deleted
+	FetchPrune bool `json:"fetch_prune,omitempty"`
+	// FetchTags fetches every tag, not only those reachable from fetched branches
+	FetchTags bool `json:"fetch_tags,omitempty"`
+	// SingleBranch has clones track only the default branch instead of every branch
+	SingleBranch bool `json:"single_branch,omitempty"`
+	// Branches pins repositories, by name or owner/name, to a branch or tag their
+	// clones track alone, as read from the config file
+	Branches map[string]string `json:"-"`
+	// Maintenance runs `git maintenance run --auto`, or `git gc --auto` with an older
+	// git, in each clone that was not already up to date. MaintenanceRegister also
+	// registers every clone for git's background maintenance, in the global git config.
+	Maintenance         bool `json:"maintenance,omitempty"`
+	MaintenanceRegister bool `json:"maintenance_register,omitempty"`
+	// CI records the latest workflow run outcome of each repository during discovery
+	CI bool `json:"ci,omitempty"`
+	// Fsync makes the state and last run files durable before carrying on, at the cost
+	// of an fsync for every completed repository
+	Fsync bool `json:"fsync,omitempty"`
+	// Mirror keeps <name>.git mirror clones with every ref and no working tree, for backups
+	Mirror bool `json:"mirror,omitempty"`
+	// Bare keeps <name>.git bare clones of the branches, without working trees
+	Bare bool `json:"bare,omitempty"`
+	// Submodules clones and updates submodules along with each repository
+	Submodules bool `json:"submodules,omitempty"`
+	// Concurrency limits how many repositories sync at once; zero means no limit
+	Concurrency int `json:"concurrency,omitempty"`
+	// Order is the order in which repositories start syncing, one of the Order
+	// constants; empty keeps the order of discovery
+	Order string `json:"order,omitempty"`
+	// Protocol is the protocol new clones are made over, one of the Protocol
+	// constants; empty uses the git_protocol gh is configured with
+	Protocol string `json:"protocol,omitempty"`
+	// Profile names the config profile selecting which repositories to sync, and
+	// Selection is its content
+	Profile   string  `json:"profile,omitempty"`
+	Selection Profile `json:"-"`
+	// Completed lists repositories an interrupted run already synchronized; they are
+	// skipped when resuming it
+	Completed []string `json:"-"`
+	// Replay, if set, has the run play back a recording instead of synchronizing
+	// anything, ReplaySpeed times as fast as it was recorded
+	Replay      *Recording `json:"-"`
+	ReplaySpeed float64    `json:"-"`
+	// Baseline is what earlier runs synchronized, for the completion screen to tell what
+	// changed since the last one; nil without a history
+	Baseline *Baseline `json:"-"`
+	// Keep lists name patterns of local repositories that must never be pruned or relocated
+	Keep []string `json:"-"`
+	// Subscribers are told about every repository and run as it happens
+	Subscribers []Subscriber `json:"-"`
+	// Plain prints a line of text per finished repository instead of drawing the
+	// terminal UI, for screen readers and dumb terminals
+	Plain bool `json:"plain,omitempty"`
+	// Quiet neither draws the terminal UI nor prints progress. Only a run that did not
+	// go well prints a line of totals and its failures, to stderr, e.g. for cron.
+	Quiet bool `json:"quiet,omitempty"`
+	// Archive packs every repository that synchronized successfully into an archive
+	// of this format, one of the Archive constants, under ArchiveOutput (by default
+	// DefaultArchiveOutput). Empty packs none.
+	Archive       string `json:"archive,omitempty"`
+	ArchiveOutput string `json:"archive_output,omitempty"`
+	// Exec, when set, is a command and its arguments to run in every clone recorded in
+	// the manifest instead of synchronizing anything, e.g. for `orgsync exec`. The
+	// arguments may refer to the repository as templates, e.g. {{.Name}}.
+	Exec []string `json:"exec,omitempty"`
+}
 
-func NewModel(org string) Model {
-	progressBar := progress.New(progress.WithDefaultGradient(), progress.WithScaledGradient("#FFA500", "#00FF00"))
-	spn := spinner.New()
-	spn.Style = spinnerStyle
+// discoveryResult is the outcome of discovering the repositories of a run
+type discoveryResult struct {
+	Repositories []Repository
+	// Upstream is every repository discovered, before any filtering
+	Upstream []Repository
+	Warnings []string
+	// RateLimit is the API quota remaining after discovery
+	RateLimit RateLimit
+	Err       error
+	// RetryAt is set when discovery hit a rate limit the retry policy waits out, to be
+	// tried again then as attempt number Attempt
+	RetryAt time.Time
+	Attempt int
+}
+
+// syncResult is the outcome of synchronizing a repository
+type syncResult struct {
+	Repo Repository
+	Err  error
+	// RateLimit is the API quota seen while waiting out a rate limit, if any
+	RateLimit RateLimit
+}
 
-	columns := []table.Column{
-		{Title: "Repository", Width: 30},
-		{Title: "Status", Width: 30},
+// discoverRepositories lists the repositories of the target, unless Options.Repos names
+// them or Options.Exec runs over the clones of the manifest, and selects those to sync.
+// When the attempts so far hit a rate limit and the retry policy allows another, the
+// result only tells when to retry.
+func discoverRepositories(opts Options, attempts int, listed func(pages, repos int)) discoveryResult {
+	if len(opts.Exec) > 0 {
+		return execRepositories(opts)
+	}
+	if len(opts.Repos) > 0 {
+		return listRepositories(opts)
+	}
+	stderr := &progressWriter{}
+	repos, err := fetchRepos(opts, stderr, listed)
+	// The quota is informational, so a failure to read it is not worth reporting
+	limit, _ := fetchRateLimit()
+	if err != nil {
+		// Wait out rate limits rather than failing the whole run
+		if attempt := attempts + 1; ClassifyError(err) == CategoryRateLimit && opts.Retry.retries(CategoryRateLimit, attempt) {
+			wait, limit := opts.Retry.backoff(CategoryRateLimit, attempt)
+			logger.Warn("discovery rate limited", "target", opts.label(), "attempt", attempt, "wait", wait)
+			return discoveryResult{Attempt: attempt, RetryAt: time.Now().Add(wait), RateLimit: limit}
+		}
+		logger.Error("discovery failed", "target", opts.label(), "error", err)
+		return discoveryResult{Warnings: stderr.warnings, RateLimit: limit, Err: err}
+	}
+	upstream := repos
+	if opts.Team != "" {
+		team, err := fetchTeamRepos(opts.Owner, opts.Team, stderr)
+		if err != nil {
+			logger.Error("discovery failed", "target", opts.label(), "team", opts.Team, "error", err)
+			return discoveryResult{Warnings: stderr.warnings, RateLimit: limit, Err: err}
+		}
+		// filterOnly keeps everything for an empty list, but a team without repositories selects none
+		if len(team) == 0 {
+			repos = nil
+		}
+		repos = filterOnly(repos, team)
+	}
+	repos = filterLanguages(repos, opts.Languages)
+	repos = opts.Selection.Filter(filterIgnored(filterPermitted(repos, opts.Policy), opts.Ignore))
+	repos = skipCompleted(filterOnly(repos, opts.Only), opts.Completed)
+	warnings := stderr.warnings
+	if err := checkDiskSpace(opts, repos); err != nil {
+		if !opts.IgnoreDiskSpace {
+			logger.Error("not starting the run", "target", opts.label(), "error", err)
+			return discoveryResult{Warnings: warnings, RateLimit: limit, Err: err}
+		}
+		warnings = append(warnings, err.Error())
 	}
+	if opts.CI {
+		fetchCIStatuses(repos)
+	}
+	logger.Info("discovered repositories", "target", opts.label(), "upstream", len(upstream), "selected", len(repos))
+	return discoveryResult{Repositories: repos, Upstream: upstream, Warnings: warnings, RateLimit: limit}
+}
 
-	tbl := table.New(
-		table.WithColumns(columns),
-		table.WithHeight(10),
+// syncRepository synchronizes repo once it has a worker, running its hooks and
+// retrying failures as the retry policy allows, and reports transfer progress to
+// report.
+func syncRepository(ctx context.Context, opts Options, repo Repository, report func(progress float64, speed string, received int64)) syncResult {
+	var (
+		limit RateLimit
+		err   error
 	)
-
-	return Model{
-		Org:      org,
-		Progress: progressBar,
-		Spinner:  spn,
-		Table:    tbl,
-	}
-}
-
-func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.fetchRepositories, m.Spinner.Tick)
-}
-
-// Update processes messages and updates the state of the Model
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		if msg.String() == "q" {
-			return m, tea.Quit
-		}
-	case tea.WindowSizeMsg:
-		m.Width = msg.Width
-		m.Height = msg.Height
-		m.Progress.Width = msg.Width - padding*2 - 4
-		if m.Progress.Width > maxWidth {
-			m.Progress.Width = maxWidth
-		}
-		return m, nil
-	case repositoriesFetchedMsg:
-		m.Repositories = msg.Repositories
-		rows := make([]table.Row, len(m.Repositories))
-		for i, repo := range m.Repositories {
-			rows[i] = table.Row{repo.Name, pendingStyle.Render("Pending")}
-		}
-		m.Table.SetRows(rows)
-		return m, tea.Batch(m.syncRepositories()...)
-	case repositoryProcessedMsg:
-		// Update repository details in the model
-		for i := range m.Repositories {
-			if m.Repositories[i].Name == msg.Repo.Name {
-				m.Repositories[i].Done = true
-				m.Repositories[i].Err = msg.Err
-				break
+	repo.StartedAt = time.Now()
+	logger.Info("repository started", repoAttr(repo), "queue_wait", repo.QueueWait)
+	if len(opts.Exec) > 0 {
+		return execRepository(ctx, opts, repo)
+	}
+	if opts.MoveArchived && repo.Archived {
+		if err := archiveRepository(opts, repo); err != nil {
+			repo.FinishedAt = time.Now()
+			logRepositoryFinished(repo, err)
+			return syncResult{Repo: repo, Err: err}
+		}
+	}
+	if opts.Hooks.PreRepo != "" {
+		if err := runRepoHook(ctx, "pre_repo", opts.Hooks.PreRepo, opts, repo, ""); err != nil {
+			if ctx.Err() != nil {
+				err = cancelled(ctx)
 			}
+			repo.FinishedAt = time.Now()
+			logRepositoryFinished(repo, err)
+			return syncResult{Repo: repo, Err: err}
+		}
+	}
+	for {
+		progress := &progressWriter{report: report, protectWorktree: opts.NoTouchWorktree}
+		if opts.Verbose || opts.SaveLogs {
+			progress.transcript = &transcript{}
+		}
+		attemptStarted := time.Now()
+		repo.Attempts++
+		err = syncRepo(ctx, opts, repo, progress)
+		if err != nil && ctx.Err() != nil {
+			err = cancelled(ctx)
+		}
+		if err == nil && opts.Maintenance {
+			maintainRepository(ctx, opts.repoDir(repo), opts.MaintenanceRegister, progress)
+		}
+		repo.BytesReceived = progress.received
+		repo.UpToDate = progress.upToDate
+		repo.Updated = progress.updated
+		if progress.protocol != "" {
+			repo.Protocol = progress.protocol
+		}
+		repo.Warnings = append(repo.Warnings, progress.warnings...)
+		repo.History = append(repo.History, Attempt{
+			Commands:  progress.commands,
+			StartedAt: attemptStarted,
+			Duration:  time.Since(attemptStarted),
+			Err:       err,
+			Output:    progress.Output(),
+		})
+		if progress.transcript != nil {
+			repo.History[len(repo.History)-1].Transcript = progress.transcript.String()
 		}
 
-		// Update the table
-		rows := m.Table.Rows()
-		for i, row := range rows {
-			if row[0] == msg.Repo.Name {
-				if msg.Err != nil {
-					rows[i][1] = errorStyle.Render(fmt.Sprintf("Error: %v", msg.Err))
-				}
-				break
-			}
+		// Try again as the retry policy for this kind of failure allows
+		category := ClassifyError(err)
+		if !opts.Retry.retries(category, repo.Attempts) {
+			break
+		}
+		var wait time.Duration
+		wait, limit = opts.Retry.backoff(category, repo.Attempts)
+		logger.Warn("retrying repository", repoAttr(repo), "attempt", repo.Attempts, "category", category, "wait", wait, "error", err)
+		if !sleepContext(ctx, wait) {
+			err = cancelled(ctx)
+			break
+		}
+	}
+	// Only a working tree has a HEAD of its own to compare with origin
+	if err == nil && !opts.Mirror && !opts.Bare {
+		repo.Upstream, repo.Ahead, repo.Behind = aheadBehind(ctx, opts, repo)
+	}
+	// Work that exists only in this clone matters most where it left the tree dirty
+	if (err == nil || errors.Is(err, ErrDirty)) && !opts.Mirror && !opts.Bare {
+		repo.Unpushed, repo.LocalBranches = unpushedWork(ctx, opts.repoDir(repo))
+	}
+	// The archive is packed before the post_repo hook, which may ship it somewhere
+	if opts.Archive != "" && err == nil {
+		repo.ArchiveFile, repo.Packed, err = packRepository(ctx, opts, repo)
+		if err != nil && ctx.Err() != nil {
+			err = cancelled(ctx)
 		}
-		m.Table.SetRows(rows)
+	}
+	if opts.Hooks.PostRepo != "" && ctx.Err() == nil {
+		if err := runRepoHook(ctx, "post_repo", opts.Hooks.PostRepo, opts, repo, outcome(err)); err != nil {
+			repo.Warnings = append(repo.Warnings, err.Error())
+		}
+	}
+	if opts.SaveLogs {
+		if path, err := saveTranscript(opts, repo); err != nil {
+			repo.Warnings = append(repo.Warnings, err.Error())
+		} else {
+			repo.LogFile = path
+		}
+	}
+	repo.FinishedAt = time.Now()
+	logRepositoryFinished(repo, err)
+	return syncResult{Repo: repo, Err: err, RateLimit: limit}
+}
 
-		// Remove completed repositories from the table
-		if msg.Err == nil {
-			m.Table.SetRows(removeRow(m.Table.Rows(), msg.Repo.Name))
+// Header describes the synchronization target for the UI
+func (o Options) Header() string {
+	switch {
+	case len(o.Exec) > 0:
+		return fmt.Sprintf("Running: %s", strings.Join(o.Exec, " "))
+	case o.Target == TargetUser && o.Collaborations:
+		return fmt.Sprintf("User: %s (including collaborations)", o.Owner)
+	case o.Target == TargetUser:
+		return fmt.Sprintf("User: %s", o.Owner)
+	case o.Target == TargetGists && o.Owner == "":
+		return "Gists: authenticated user"
+	case o.Target == TargetGists:
+		return fmt.Sprintf("Gists: %s", o.Owner)
+	case len(o.Owners) > 1:
+		return fmt.Sprintf("Organizations: %s", strings.Join(o.Owners, ", "))
+	default:
+		return fmt.Sprintf("Organization: %s", o.Owner)
+	}
+}
+
+// label is a short name for the synchronization target
+func (o Options) label() string {
+	if len(o.Exec) > 0 {
+		return "exec"
+	}
+	if o.Target == TargetGists && o.Owner == "" {
+		return "gists"
+	}
+	return strings.Join(o.AllOwners(), ",")
+}
+
+// Discover lists the repositories of the target of opts that the policy permits,
+// without selecting among them as a run does, e.g. to explore an organization before
+// syncing it
+func Discover(opts Options) ([]Repository, error) {
+	repos, err := fetchRepos(opts, &progressWriter{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return filterPermitted(repos, opts.Policy), nil
+}
+
+// fetchRepos lists the repositories to synchronize for the configured target. listed,
+// if set, is told about every page of repositories listed so far, where there are pages.
+func fetchRepos(opts Options, stderr *progressWriter, listed func(pages, repos int)) ([]Repository, error) {
+	switch {
+	case opts.Target == TargetGists:
+		return fetchGists(opts.Owner, stderr)
+	case opts.Target == TargetUser && opts.Collaborations:
+		return fetchReposForUser(opts.Owner, stderr)
+	case len(opts.Owners) > 1:
+		return fetchReposInOrgs(opts.Owners, stderr, listed)
+	default:
+		return fetchReposInOrg(opts.Owner, stderr, listed)
+	}
+}
+
+// reposQuery pages through the repositories an organization or user owns, collecting
+// the metadata every filter and feature needs in a single request per 100 repositories
+const reposQuery = `query($owner: String!, $endCursor: String) {
+  repositoryOwner(login: $owner) {
+    repositories(first: 100, after: $endCursor, ownerAffiliations: OWNER) {
+      nodes {
+        name
+        diskUsage
+        primaryLanguage { name }
+        repositoryTopics(first: 100) { nodes { topic { name } } }
+        pushedAt
+        defaultBranchRef { name }
+        visibility
+        isArchived
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// graphQLRepository is a node of reposQuery
+type graphQLRepository struct {
+	Name             string
+	DiskUsage        int64
+	PrimaryLanguage  *struct{ Name string }
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct{ Name string }
 		}
+	}
+	PushedAt         time.Time
+	DefaultBranchRef *struct{ Name string }
+	Visibility       string
+	IsArchived       bool
+}
 
-		// Calculate the number of completed repositories
-		completed := 0
-		for _, repo := range m.Repositories {
-			if repo.Done {
-				completed++
+// repository converts a node of reposQuery owned by owner
+func (r graphQLRepository) repository(owner string) Repository {
+	repo := Repository{
+		Owner:      owner,
+		Name:       r.Name,
+		Size:       r.DiskUsage * 1024,
+		PushedAt:   r.PushedAt,
+		Visibility: strings.ToLower(r.Visibility),
+		Archived:   r.IsArchived,
+	}
+	if r.PrimaryLanguage != nil {
+		repo.Language = r.PrimaryLanguage.Name
+	}
+	for _, node := range r.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	if r.DefaultBranchRef != nil {
+		repo.DefaultBranch = r.DefaultBranchRef.Name
+	}
+	return repo
+}
+
+// fetchReposInOrg lists every repository an organization or user owns, following the
+// cursor from page to page and telling listed, if set, how far it got after each
+func fetchReposInOrg(org string, stderr *progressWriter, listed func(pages, repos int)) ([]Repository, error) {
+	var repos []Repository
+	for pages, cursor := 1, ""; ; pages++ {
+		variables := map[string]string{"owner": org}
+		if cursor != "" {
+			variables["endCursor"] = cursor
+		}
+		data, err := apiGraphQL(reposQuery, variables, stderr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch repos: %w", err)
+		}
+		var page struct {
+			Data struct {
+				RepositoryOwner *struct {
+					Repositories struct {
+						Nodes    []graphQLRepository
+						PageInfo struct {
+							HasNextPage bool
+							EndCursor   string
+						}
+					}
+				}
 			}
 		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse page %d of repos: %w", pages, err)
+		}
+		// GitHub answers an unknown login with a null owner rather than an error
+		owner := page.Data.RepositoryOwner
+		if owner == nil {
+			return nil, fmt.Errorf("failed to fetch repos: could not resolve to an organization or user with the login of '%s'", org)
+		}
 
-		// Determine if all repositories are done and quit if true
-		if m.Done = completed == len(m.Repositories); m.Done {
-			return m, tea.Batch(m.Progress.SetPercent(100))
+		for _, node := range owner.Repositories.Nodes {
+			repos = append(repos, node.repository(org))
+		}
+		if listed != nil {
+			listed(pages, len(repos))
+		}
+		info := owner.Repositories.PageInfo
+		if !info.HasNextPage || info.EndCursor == "" {
+			return repos, nil
 		}
-		return m, m.Progress.SetPercent(float64(completed) / float64(len(m.Repositories)))
+		cursor = info.EndCursor
+	}
+}
 
-	case spinner.TickMsg:
-		var cmd tea.Cmd
-		m.Spinner, cmd = m.Spinner.Update(msg)
-		return m, cmd
-	case progress.FrameMsg:
-		// Handle progress bar animation
-		progressModel, cmd := m.Progress.Update(msg)
-		m.Progress = progressModel.(progress.Model)
-		return m, cmd
+// fetchTeamRepos lists the names of the repositories the team with the given slug has
+// access to in org
+func fetchTeamRepos(org, team string, stderr *progressWriter) ([]string, error) {
+	data, err := apiGet(fmt.Sprintf("orgs/%s/teams/%s/repos?per_page=100", org, team), true, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos of team %s: %w", team, err)
+	}
+	repos, err := decodePages[struct{ Name string }](data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos of team %s: %w", team, err)
+	}
+	names := make([]string, len(repos))
+	for i, repo := range repos {
+		names[i] = repo.Name
 	}
+	return names, nil
+}
 
-	return m, nil
+// restRepository is a repository as the REST API describes it
+type restRepository struct {
+	FullName      string `json:"full_name"`
+	Size          int64
+	Language      string
+	Topics        []string
+	PushedAt      time.Time `json:"pushed_at"`
+	DefaultBranch string    `json:"default_branch"`
+	Visibility    string
+	Archived      bool
 }
 
-func (m Model) View() string {
-	var builder strings.Builder
-	title := titleStyle.Render("OrgSync")
-	orgInfo := normalText.Render(fmt.Sprintf("Organization: %s", m.Org))
-	progressBar := m.Progress.View()
-	loadingSpinner := m.Spinner.View() + " Loading..."
-	tableView := m.Table.View()
+// fetchReposForUser lists every repository a user owns or collaborates on. The GraphQL
+// listing only returns owned repositories, so this goes through the REST API.
+func fetchReposForUser(user string, stderr *progressWriter) ([]Repository, error) {
+	data, err := apiGet(fmt.Sprintf("users/%s/repos?type=all&per_page=100", user), true, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	}
+	listing, err := decodePages[restRepository](data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	}
 
-	center := func(s string) string {
-		return lipgloss.Place(m.Width, len(strings.Split(s, "\n")), lipgloss.Center, lipgloss.Center, s)
+	var repos []Repository
+	for _, r := range listing {
+		owner, name, ok := strings.Cut(r.FullName, "/")
+		if !ok {
+			continue
+		}
+		repos = append(repos, Repository{
+			Owner:         owner,
+			Name:          name,
+			Size:          r.Size * 1024,
+			Language:      r.Language,
+			Topics:        r.Topics,
+			PushedAt:      r.PushedAt,
+			DefaultBranch: r.DefaultBranch,
+			Visibility:    strings.ToLower(r.Visibility),
+			Archived:      r.Archived,
+		})
 	}
+	return repos, nil
+}
 
-	builder.WriteString(center(title) + "\n\n")
-	builder.WriteString(center(orgInfo) + "\n\n")
-	builder.WriteString(center(progressBar) + "\n\n")
+// fetchGists lists the gists of a user, or of the authenticated user when user is empty
+func fetchGists(user string, stderr *progressWriter) ([]Repository, error) {
+	endpoint := "gists?per_page=100"
+	if user != "" {
+		endpoint = fmt.Sprintf("users/%s/gists?per_page=100", user)
+	}
+	data, err := apiGet(endpoint, true, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gists: %w", err)
+	}
+	gists, err := decodePages[struct {
+		ID    string
+		Files map[string]struct{ Size int64 }
+	}](data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gists: %w", err)
+	}
 
-	if m.Done {
-		builder.WriteString(center("All operations completed. Press 'q' to quit.") + "\n")
-	} else {
-		builder.WriteString(center(loadingSpinner) + "\n\n")
-		builder.WriteString(center(tableView) + "\n")
-		builder.WriteString(center("Press 'q' to quit.") + "\n")
+	var repos []Repository
+	for _, gist := range gists {
+		var size int64
+		for _, file := range gist.Files {
+			size += file.Size
+		}
+		repos = append(repos, Repository{Owner: user, Name: gist.ID, Gist: true, Size: size})
 	}
+	return repos, nil
+}
 
-	return builder.String()
+// FormatBytes renders a byte count for display, e.g. "1.5 MB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
-// repositoriesFetchedMsg contains the fetched repositories
-type repositoriesFetchedMsg struct {
-	Repositories []Repository
+// TotalSize sums the reported size of all repositories
+func (s State) TotalSize() int64 {
+	var total int64
+	for _, repo := range s.Repositories {
+		total += repo.Size
+	}
+	return total
 }
 
-// repositoryProcessedMsg contains the processed repository status
-type repositoryProcessedMsg struct {
-	Repo Repository
-	Err  error
+// filterOnly keeps the repositories named in only, as name or owner/name, or all of them
+// when only is empty
+func filterOnly(repos []Repository, only []string) []Repository {
+	if len(only) == 0 {
+		return repos
+	}
+	wanted := make(map[string]bool, len(only))
+	for _, name := range only {
+		wanted[name] = true
+	}
+	var filtered []Repository
+	for _, repo := range repos {
+		if wanted[repo.Key()] || wanted[repo.Name] {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
 }
 
-// fetchRepositories retrieves repositories and returns a message containing the result
-func (m Model) fetchRepositories() tea.Msg {
-	repos, err := fetchReposInOrg(m.Org)
-	if err != nil {
-		return repositoriesFetchedMsg{Repositories: []Repository{{Name: "Error fetching repos"}}}
+// filterLanguages keeps the repositories whose primary language is in languages
+func filterLanguages(repos []Repository, languages []string) []Repository {
+	if len(languages) == 0 {
+		return repos
 	}
-	repositories := make([]Repository, len(repos))
-	for i, repo := range repos {
-		repositories[i] = Repository{Name: repo}
+	var filtered []Repository
+	for _, repo := range repos {
+		for _, language := range languages {
+			if strings.EqualFold(repo.Language, language) {
+				filtered = append(filtered, repo)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// splitLines returns the non-empty lines of command output
+func splitLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// repoExists reports whether something is already in the way at repoDir. With bare
+// set a working-tree clone there is reported as a conflict rather than fetched as if
+// it were bare, and vice versa.
+func repoExists(repoDir string, bare bool) (bool, error) {
+	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
+		return false, nil
+	}
+	if isRepository(repoDir) && isBareRepository(repoDir) != bare {
+		layout := "a working tree"
+		if !bare {
+			layout = "no working tree"
+		}
+		return true, fmt.Errorf("%w: %s is a clone with %s", ErrConflict, filepath.Base(repoDir), layout)
 	}
-	return repositoriesFetchedMsg{Repositories: repositories}
+	return true, nil
 }
 
-// syncRepositories triggers commands to clone or fetch each repository
-func (m Model) syncRepositories() []tea.Cmd {
-	cmds := make([]tea.Cmd, len(m.Repositories))
-	for i, repo := range m.Repositories {
-		cmds[i] = syncRepositoryCmd(m.Org, repo)
+// runCommand runs a git or gh command with its stderr parsed by progress,
+// recording the command line so it can be shown in the detail view
+func runCommand(cmd *exec.Cmd, progress *progressWriter) error {
+	if progress.protectWorktree && modifiesWorktree(cmd.Args) {
+		return fmt.Errorf("%w: %s", ErrWorktreeProtected, strings.Join(cmd.Args, " "))
 	}
-	return cmds
+	cmd.Stderr = progress
+	if cmd.Env == nil && cmd.Args[0] == "git" {
+		cmd.Env = gitEnv()
+	}
+	progress.commands = append(progress.commands, strings.Join(cmd.Args, " "))
+	// stdout is added to the transcript after stderr, since the two are copied concurrently
+	var stdout bytes.Buffer
+	if t := progress.transcript; t != nil {
+		t.command(cmd.Args)
+		cmd.Stderr = io.MultiWriter(progress, t)
+		if cmd.Stdout != nil {
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, &stdout)
+		} else {
+			cmd.Stdout = &stdout
+		}
+	}
+	started := time.Now()
+	err := cmd.Run()
+	if t := progress.transcript; t != nil {
+		t.flush()
+		t.Write(stdout.Bytes())
+		t.finish(err)
+	}
+	logCommand(slog.LevelInfo, cmd, started, err, progress.Output())
+	return err
 }
 
-func syncRepositoryCmd(org string, repo Repository) tea.Cmd {
-	return func() tea.Msg {
-		time.Sleep(1 * time.Second) // simulate some delay
-		err := syncRepo(org, repo.Name)
-		return repositoryProcessedMsg{Repo: repo, Err: err}
+// cloneArgs returns the extra git clone arguments for the configured clone mode
+func (o Options) cloneArgs(repo Repository) []string {
+	args := []string{"--progress"}
+	switch {
+	case o.Mirror:
+		args = append(args, "--mirror")
+	case o.Bare:
+		args = append(args, "--bare")
+	case o.Submodules && !repo.Gist:
+		args = append(args, "--recurse-submodules")
+	}
+	if o.singleBranch(repo) {
+		args = append(args, "--single-branch")
+		if branch := o.pinnedBranch(repo); branch != "" {
+			args = append(args, "--branch", branch)
+		}
 	}
+	return args
 }
 
-func fetchReposInOrg(org string) ([]string, error) {
-	cmd := exec.Command("gh", "repo", "list", org, "--json", "name", "--jq", ".[] | .name", "--limit", "1000")
-	var out bytes.Buffer
-	cmd.Stdout = &out
+// cloneRepo clones source, a URL or owner/name, into repoDir. Without gh, source must
+// be a URL for git to clone.
+func cloneRepo(ctx context.Context, source, repo, repoDir string, gitArgs []string, progress *progressWriter) error {
+	args := append([]string{"repo", "clone", source, repoDir, "--"}, gitArgs...)
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	if withoutGH() {
+		cmd = exec.CommandContext(ctx, "git", append(append([]string{"clone"}, gitArgs...), "--", source, repoDir)...)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to fetch repos: %w", err)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to clone %s: %w", repo, newCommandError(err, progress))
 	}
+	return nil
+}
 
-	repos := strings.Split(strings.TrimSpace(out.String()), "\n")
-	return repos, nil
+func cloneGist(ctx context.Context, id, repoDir string, gitArgs []string, progress *progressWriter) error {
+	args := append([]string{"gist", "clone", id, repoDir, "--"}, gitArgs...)
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	if withoutGH() {
+		source := fmt.Sprintf("https://gist.%s/%s.git", apiHost(), id)
+		cmd = exec.CommandContext(ctx, "git", append(append([]string{"clone"}, gitArgs...), "--", source, repoDir)...)
+	}
+
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to clone gist %s: %w", id, newCommandError(err, progress))
+	}
+	return nil
+}
+
+// fetchArgs returns the git fetch arguments for updating an existing working-tree clone
+func (o Options) fetchArgs(repo Repository) []string {
+	args := []string{"--progress"}
+	if o.FetchPrune {
+		args = append(args, "--prune")
+	}
+	if o.FetchTags {
+		args = append(args, "--tags")
+	}
+	if o.Submodules && !repo.Gist {
+		args = append(args, "--recurse-submodules=on-demand")
+	}
+	return args
 }
 
-func repoExists(repoDir string) bool {
-	_, err := os.Stat(repoDir)
-	return !os.IsNotExist(err)
+func fetchRepo(ctx context.Context, repoDir, repo string, gitArgs []string, progress *progressWriter) error {
+	args := append([]string{"-C", repoDir, "fetch"}, gitArgs...)
+	cmd := exec.CommandContext(ctx, "git", append(args, "origin")...)
+
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", repo, newCommandError(err, progress))
+	}
+	return nil
 }
 
-func cloneRepo(org, repo, repoDir string) error {
-	cmd := exec.Command("gh", "repo", "clone", fmt.Sprintf("%s/%s", org, repo), repoDir)
+// updateMirror brings a mirror clone up to date with every ref of its origin,
+// deleting refs that were removed upstream
+func updateMirror(ctx context.Context, repoDir, repo string, progress *progressWriter) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "remote", "update", "--prune")
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to clone %s: %w", repo, err)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to update mirror %s: %w", repo, newCommandError(err, progress))
 	}
 	return nil
 }
 
-func fetchRepo(repoDir, repo string) error {
-	cmd := exec.Command("git", "-C", repoDir, "fetch", "origin")
+// fetchBare updates the branches of a bare clone. Bare clones have no remote-tracking
+// refspec, so the branches are fetched onto the local ones, dropping deleted branches.
+func fetchBare(ctx context.Context, repoDir, repo string, progress *progressWriter) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "fetch", "--progress", "--prune", "--tags", "origin", "+refs/heads/*:refs/heads/*")
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to fetch %s: %w", repo, err)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", repo, newCommandError(err, progress))
 	}
 	return nil
 }
 
-func syncRepo(org, repo string) error {
-	repoDir := filepath.Join(".", repo)
+// updateSubmodules clones submodules that were added upstream or never initialized,
+// and checks out the commits the repository records for the rest
+func updateSubmodules(ctx context.Context, repoDir, repo string, progress *progressWriter) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "submodule", "update", "--init", "--recursive", "--progress")
 
-	if repoExists(repoDir) {
-		return fetchRepo(repoDir, repo)
-	} else {
-		return cloneRepo(org, repo, repoDir)
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to update submodules of %s: %w", repo, newCommandError(err, progress))
 	}
+	return nil
 }
 
-func removeRow(rows []table.Row, repoName string) []table.Row {
-	for i, row := range rows {
-		if row[0] == repoName {
-			return append(rows[:i], rows[i+1:]...)
+// repoDir is where the local clone of repo lives
+func (o Options) repoDir(repo Repository) string {
+	if repo.Dir != "" {
+		return repo.Dir
+	}
+	name := repo.Name
+	if o.Mirror || o.Bare {
+		name += ".git"
+	}
+	if repo.Gist {
+		return filepath.Join(".", gistsDir, name)
+	}
+	return filepath.Join(".", expandLayout(o.Layout, repo.Owner, repo.Language, name))
+}
+
+func syncRepo(ctx context.Context, opts Options, repo Repository, progress *progressWriter) error {
+	repoDir := opts.repoDir(repo)
+
+	exists, err := repoExists(repoDir, opts.Mirror || opts.Bare)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if opts.FixRemotes {
+			if err := fixRemote(ctx, opts, repo, repoDir, progress); err != nil {
+				return err
+			}
+		}
+		moved, err := checkOrigin(ctx, opts, repo, repoDir)
+		if err != nil {
+			return err
+		}
+		exists = !moved
+	}
+
+	switch {
+	case exists && opts.Mirror:
+		return updateMirror(ctx, repoDir, repo.Name, progress)
+	case exists && opts.Bare && opts.singleBranch(repo):
+		return fetchTracked(ctx, opts, repoDir, repo, "refs/heads/", []string{"--progress"}, progress)
+	case exists && opts.Bare:
+		current, err := upToDate(ctx, repoDir, "refs/heads/", true, true, progress)
+		if err != nil || current {
+			progress.upToDate = current
+			return err
+		}
+		if err := fetchBare(ctx, repoDir, repo.Name, progress); err != nil {
+			return err
+		}
+		progress.updated = true
+		return nil
+	case exists && opts.singleBranch(repo):
+		if err := fetchTracked(ctx, opts, repoDir, repo, "refs/remotes/origin/", opts.fetchArgs(repo), progress); err != nil {
+			return err
+		}
+		return updateWorktree(ctx, opts, repoDir, repo, progress)
+	case exists:
+		current, err := upToDate(ctx, repoDir, "refs/remotes/origin/", opts.FetchTags, opts.FetchPrune, progress)
+		if err != nil {
+			return err
+		}
+		progress.upToDate = current
+		if !current {
+			if err := fetchRepo(ctx, repoDir, repo.Name, opts.fetchArgs(repo), progress); err != nil {
+				return err
+			}
+			progress.updated = true
 		}
+		// The working tree may still lag behind origin, e.g. after an earlier run
+		// without --checkout
+		return updateWorktree(ctx, opts, repoDir, repo, progress)
+	case repo.Gist:
+		return cloneStaged(repoDir, func(dir string) error {
+			return cloneGist(ctx, repo.Name, dir, opts.cloneArgs(repo), progress)
+		})
+	default:
+		return cloneStaged(repoDir, func(dir string) error {
+			return cloneOverProtocol(ctx, opts, repo, dir, progress)
+		})
 	}
-	return rows
 }
@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Theme is the color palette of the TUI. Colors are hex values or ANSI color numbers.
+type Theme struct {
+	Title           string `json:"title,omitempty"`
+	TitleBackground string `json:"title_background,omitempty"`
+	Text            string `json:"text,omitempty"`
+	Pending         string `json:"pending,omitempty"`
+	Error           string `json:"error,omitempty"`
+	Success         string `json:"success,omitempty"`
+	Selected        string `json:"selected,omitempty"`
+	Border          string `json:"border,omitempty"`
+	GradientStart   string `json:"gradient_start,omitempty"`
+	GradientEnd     string `json:"gradient_end,omitempty"`
+}
+
+// ThemeCustom selects the colors given in the config file, on top of the default theme
+const ThemeCustom = "custom"
+
+// Themes are the built-in palettes selectable by name
+var Themes = map[string]Theme{
+	"dark": {
+		Title:           "#FFDD00",
+		TitleBackground: "#336699",
+		Text:            "#FFFFFF",
+		Pending:         "#FFA500",
+		Error:           "#FF0000",
+		Success:         "#00FF00",
+		Selected:        "212",
+		Border:          "#336699",
+		GradientStart:   "#FFA500",
+		GradientEnd:     "#00FF00",
+	},
+	"light": {
+		Title:           "#FFFFFF",
+		TitleBackground: "#1F4E79",
+		Text:            "#1A1A1A",
+		Pending:         "#B35900",
+		Error:           "#C00000",
+		Success:         "#006400",
+		Selected:        "#1F4E79",
+		Border:          "#1F4E79",
+		GradientStart:   "#B35900",
+		GradientEnd:     "#006400",
+	},
+	"solarized": {
+		Title:           "#FDF6E3",
+		TitleBackground: "#268BD2",
+		Text:            "#839496",
+		Pending:         "#B58900",
+		Error:           "#DC322F",
+		Success:         "#859900",
+		Selected:        "#2AA198",
+		Border:          "#268BD2",
+		GradientStart:   "#B58900",
+		GradientEnd:     "#859900",
+	},
+}
+
+// DefaultTheme is used when the config does not pick one
+const DefaultTheme = "dark"
+
+// ThemeNames lists the accepted values of the theme setting
+func ThemeNames() []string {
+	names := []string{ThemeCustom}
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme resolves a theme name from the config. The custom theme fills any color
+// it leaves out from the default theme.
+func LookupTheme(name string, custom Theme) (Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	if name != ThemeCustom {
+		t, ok := Themes[name]
+		if !ok {
+			return Theme{}, fmt.Errorf("unknown theme %q", name)
+		}
+		return t, nil
+	}
+
+	t := Themes[DefaultTheme]
+	for _, c := range []struct {
+		dst *string
+		src string
+	}{
+		{&t.Title, custom.Title},
+		{&t.TitleBackground, custom.TitleBackground},
+		{&t.Text, custom.Text},
+		{&t.Pending, custom.Pending},
+		{&t.Error, custom.Error},
+		{&t.Success, custom.Success},
+		{&t.Selected, custom.Selected},
+		{&t.Border, custom.Border},
+		{&t.GradientStart, custom.GradientStart},
+		{&t.GradientEnd, custom.GradientEnd},
+	} {
+		if c.src != "" {
+			*c.dst = c.src
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"time"
+)
+
+// rateWindow is how far back the aggregate transfer rate looks, long enough to smooth
+// over git reporting in bursts and short enough to notice a stall
+const rateWindow = 10 * time.Second
+
+// rateResolution is how close together samples are kept; reports in between update the
+// latest sample instead
+const rateResolution = 250 * time.Millisecond
+
+// rateSample is the running byte count at one point in time
+type rateSample struct {
+	at    time.Time
+	total int64
+}
+
+// rateMeter measures the rolling rate of a running byte count
+type rateMeter struct {
+	samples []rateSample
+}
+
+// add records that total bytes were received by at
+func (r *rateMeter) add(at time.Time, total int64) {
+	if n := len(r.samples); n > 0 && at.Sub(r.samples[n-1].at) < rateResolution {
+		r.samples[n-1].total = total
+		return
+	}
+	r.samples = append(r.samples, rateSample{at: at, total: total})
+	// One sample from before the window is kept as its baseline
+	drop := 0
+	for drop+1 < len(r.samples) && r.samples[drop+1].at.Before(at.Add(-rateWindow)) {
+		drop++
+	}
+	r.samples = r.samples[drop:]
+}
+
+// rate returns the bytes received per second over the window ending at now, or over the
+// time since the first sample when that is shorter
+func (r *rateMeter) rate(now time.Time) float64 {
+	if len(r.samples) == 0 {
+		return 0
+	}
+	start := now.Add(-rateWindow)
+	base := r.samples[0]
+	for _, sample := range r.samples {
+		if sample.at.After(start) {
+			break
+		}
+		base = sample
+	}
+	if base.at.After(start) {
+		start = base.at
+	}
+	elapsed := now.Sub(start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(r.samples[len(r.samples)-1].total-base.total) / elapsed
+}
+
+// TransferRate returns the bytes received per second across every syncing repository
+// of the run, averaged over the last few seconds
+func (e *Engine) TransferRate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state.Done {
+		return 0
+	}
+	return e.meter.rate(time.Now())
+}
+
+// FormatRate renders a transfer rate in bytes per second, e.g. "12.3 MB/s"
+func FormatRate(rate float64) string {
+	return FormatBytes(int64(rate)) + "/s"
+}
@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// logsDir holds the per-repository command transcripts written with SaveLogs, relative
+// to the sync root
+var logsDir = filepath.Join(".orgsync", "logs")
+
+// transcript keeps the complete stdout and stderr of the commands run for a repository
+// in verbose mode. Like a terminal, a carriage return starts the line over, so progress
+// meters leave only their final state rather than hundreds of redraws, and the spaces
+// git pads them with are trimmed.
+type transcript struct {
+	text []byte
+	line []byte
+	// cr is set after a carriage return, which only clears the line if more follows
+	cr bool
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	for _, c := range p {
+		switch {
+		case c == '\n':
+			t.text = append(append(t.text, bytes.TrimRight(t.line, " ")...), '\n')
+			t.line = t.line[:0]
+			t.cr = false
+		case c == '\r':
+			t.cr = true
+		default:
+			if t.cr {
+				t.line = t.line[:0]
+				t.cr = false
+			}
+			t.line = append(t.line, c)
+		}
+	}
+	return len(p), nil
+}
+
+// command starts the transcript of a command line
+func (t *transcript) command(args []string) {
+	t.flush()
+	fmt.Fprintf(t, "$ %s\n", strings.Join(args, " "))
+}
+
+// finish ends the transcript of a command with its exit status if it failed
+func (t *transcript) finish(err error) {
+	t.flush()
+	if err != nil {
+		fmt.Fprintf(t, "[%v]\n", err)
+	}
+}
+
+// flush ends a last line written without a newline
+func (t *transcript) flush() {
+	if len(t.line) > 0 {
+		t.Write([]byte{'\n'})
+	}
+}
+
+func (t *transcript) String() string {
+	return string(t.text) + string(t.line)
+}
+
+// logPath is where SaveLogs writes the transcript of repo, mirroring its clone directory
+func (o Options) logPath(repo Repository) string {
+	dir := strings.TrimSuffix(o.repoDir(repo), ".git")
+	return filepath.Join(logsDir, dir+".log")
+}
+
+// saveTranscript writes the transcripts of every attempt at repo to its log file,
+// replacing the one from the previous run, and returns the path
+func saveTranscript(opts Options, repo Repository) (string, error) {
+	path := opts.logPath(repo)
+	var b strings.Builder
+	for n, attempt := range repo.History {
+		fmt.Fprintf(&b, "# Attempt %d at %s (%s)\n", n+1, attempt.StartedAt.Format(time.RFC3339), attempt.Duration.Round(time.Millisecond))
+		b.WriteString(attempt.Transcript)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write the log of %s: %w", repo.Name, err)
+	}
+	return path, nil
+}
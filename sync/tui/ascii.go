@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/mattn/go-runewidth"
+)
+
+// glyphSet holds the characters the UI draws with that are not plain letters, so
+// that terminals which cannot show Unicode get ASCII ones instead
+type glyphSet struct {
+	ascii bool
+	// separator joins the parts of the header line, e.g. " · "
+	separator string
+	ellipsis  string
+	arrow     string
+	// expanded and collapsed mark the header rows of table sections
+	expanded  string
+	collapsed string
+	// up and down name the arrow keys in key hints
+	up   string
+	down string
+	// border frames the detail pane and the help overlay
+	border lipgloss.Border
+	// barFull and barEmpty fill the progress bars
+	barFull  rune
+	barEmpty rune
+}
+
+var (
+	unicodeGlyphs = glyphSet{
+		separator: " · ",
+		ellipsis:  "…",
+		arrow:     "→",
+		expanded:  "▾",
+		collapsed: "▸",
+		up:        "↑",
+		down:      "↓",
+		border:    lipgloss.RoundedBorder(),
+		barFull:   '█',
+		barEmpty:  '░',
+	}
+	asciiGlyphs = glyphSet{
+		ascii:     true,
+		separator: " | ",
+		ellipsis:  "...",
+		arrow:     "->",
+		expanded:  "v",
+		collapsed: ">",
+		up:        "up",
+		down:      "down",
+		border: lipgloss.Border{
+			Top: "-", Bottom: "-", Left: "|", Right: "|",
+			TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+		},
+		barFull:  '#',
+		barEmpty: '-',
+	}
+)
+
+// glyphs are the characters of every model created after ApplyASCII, ASCII ones when
+// the environment looks like it cannot show Unicode
+var glyphs = glyphsFor(sync.DetectASCII())
+
+// glyphsFor returns the ASCII glyphs or the Unicode ones
+func glyphsFor(ascii bool) glyphSet {
+	if ascii {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// ApplyASCII selects ASCII-only rendering, or Unicode, for everything drawn afterwards,
+// including the text output of the engine. It overrides sync.DetectASCII and must come
+// before ApplyTheme and LookupKeys, which draw borders, progress bars and key names
+// with the selected characters.
+func ApplyASCII(ascii bool) {
+	sync.ApplyASCII(ascii)
+	glyphs = glyphsFor(ascii)
+	keys = DefaultKeyMap()
+}
+
+// asciiText replaces the Unicode a string may carry from elsewhere, such as the arrow
+// of a transferred repository, with ASCII in ASCII mode
+func asciiText(s string) string {
+	if !glyphs.ascii {
+		return s
+	}
+	return strings.ReplaceAll(s, unicodeGlyphs.arrow, asciiGlyphs.arrow)
+}
+
+// fitRows shortens the cells too wide for their column in ASCII mode, where the table
+// would otherwise cut them off with a Unicode ellipsis
+func fitRows(rows []table.Row, columns []table.Column) []table.Row {
+	if !glyphs.ascii {
+		return rows
+	}
+	fitted := make([]table.Row, len(rows))
+	for i, row := range rows {
+		fitted[i] = make(table.Row, len(row))
+		for j, cell := range row {
+			if j < len(columns) {
+				cell = runewidth.Truncate(cell, columns[j].Width, glyphs.ellipsis)
+			}
+			fitted[i][j] = cell
+		}
+	}
+	return fitted
+}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/muesli/termenv"
+)
+
+// clipboardTools are platform clipboard commands tried in order; OSC 52 is
+// always emitted as well so that copying also works over SSH
+var clipboardTools = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// copyToClipboard copies text via OSC 52 and the first available platform tool
+func copyToClipboard(text string) {
+	termenv.Copy(text)
+	for _, tool := range clipboardTools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool[0], tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if cmd.Run() == nil {
+			return
+		}
+	}
+}
+
+// copySelectedFailure copies the name and full error text of the repository selected in the table
+func (m Model) copySelectedFailure() tea.Cmd {
+	i, ok := m.selectedRepository()
+	if !ok {
+		return nil
+	}
+	return m.copyFailure(m.Repositories[i].Key())
+}
+
+// copyFailure copies the name and full error text of the repository with the given key,
+// including git's output
+func (m Model) copyFailure(key string) tea.Cmd {
+	i, ok := m.index[key]
+	if !ok || m.Repositories[i].Err == nil {
+		return nil
+	}
+	repo := m.Repositories[i]
+	text := fmt.Sprintf("%s: %v", repo.Name, repo.Err)
+	if output := sync.ErrorOutput(repo.Err); output != "" {
+		text += "\n" + output
+	}
+	return func() tea.Msg {
+		copyToClipboard(text)
+		return noticeMsg{Text: fmt.Sprintf("Copied error for %s to clipboard", repo.Name)}
+	}
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// openDetail shows the detail pane for the repository selected in the table
+func (m Model) openDetail() (tea.Model, tea.Cmd) {
+	i, ok := m.selectedRepository()
+	if !ok {
+		return m, nil
+	}
+
+	width := min(max(m.Width-padding*2, 40), maxWidth+20)
+	height := max(m.Height-chromeHeight/2, minTableHeight)
+	m.Detail = viewport.New(width, height)
+	m.Detail.KeyMap = m.keys.viewportKeyMap()
+	m.Detail.SetContent(renderDetail(m.Repositories[i], width-detailStyle.GetHorizontalFrameSize()))
+	m.detailRepo = m.Repositories[i].Key()
+	return m, nil
+}
+
+// updateDetail handles keys while the detail pane is open
+func (m Model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back, m.keys.Details):
+		m.detailRepo = ""
+		return m, nil
+	case key.Matches(msg, m.keys.Copy):
+		return m, m.copyFailure(m.detailRepo)
+	case key.Matches(msg, m.keys.Quit):
+		m.cancel()
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.Detail, cmd = m.Detail.Update(msg)
+	return m, cmd
+}
+
+// renderDetail describes everything known about a repository's sync
+func renderDetail(repo sync.Repository, width int) string {
+	var b strings.Builder
+	wrap := lipgloss.NewStyle().Width(width)
+
+	fmt.Fprintf(&b, "%s\n\n", titleStyle.Render(" "+repo.Key()+" "))
+
+	status := "Pending"
+	switch {
+	case sync.SkipReason(repo.Err) != "":
+		status = "Skipped (" + sync.SkipReason(repo.Err) + ")"
+	case repo.Err != nil:
+		status = fmt.Sprintf("Failed (%s)", sync.ClassifyError(repo.Err))
+	case repo.Done && repo.UpToDate:
+		status = "Up to date"
+	case repo.Done:
+		status = "Done"
+	case repo.Progress > 0:
+		status = fmt.Sprintf("Syncing %.0f%%", repo.Progress*100)
+	}
+	fmt.Fprintf(&b, "Status:   %s\n", status)
+	fmt.Fprintf(&b, "Size:     %s (received %s)\n", sync.FormatBytes(repo.Size), sync.FormatBytes(repo.BytesReceived))
+	if repo.CI != "" {
+		fmt.Fprintf(&b, "CI:       %s\n", repo.CI)
+	}
+	if repo.Upstream != "" {
+		fmt.Fprintf(&b, "HEAD:     %d ahead, %d behind %s\n", repo.Ahead, repo.Behind, repo.Upstream)
+	}
+	if text := sync.UnpushedText(repo); text != "" {
+		fmt.Fprintf(&b, "%s\n", pendingStyle.Render("Unpushed: "+text))
+	}
+	if !repo.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "Started:  %s\n", repo.StartedAt.Format(time.TimeOnly))
+	}
+	if !repo.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, "Duration: %s\n", repo.FinishedAt.Sub(repo.StartedAt).Round(time.Millisecond))
+	}
+	if repo.Err != nil {
+		fmt.Fprintf(&b, "\n%s\n", wrap.Render(errorStyle.Render(repo.Err.Error())))
+		if hint := sync.Hint(sync.ClassifyError(repo.Err)); hint != "" {
+			fmt.Fprintf(&b, "%s\n", wrap.Render(pendingStyle.Render("Hint: "+hint)))
+		}
+	}
+
+	for _, warning := range repo.Warnings {
+		fmt.Fprintf(&b, "%s\n", wrap.Render(pendingStyle.Render("Warning: "+warning)))
+	}
+
+	for n, attempt := range repo.History {
+		fmt.Fprintf(&b, "\nAttempt %d at %s (%s)\n", n+1, attempt.StartedAt.Format(time.TimeOnly), attempt.Duration.Round(time.Millisecond))
+		// In verbose mode the transcript shows each command followed by its output
+		if attempt.Transcript != "" {
+			fmt.Fprintf(&b, "%s\n", wrap.Render(strings.TrimSuffix(attempt.Transcript, "\n")))
+		} else {
+			for _, command := range attempt.Commands {
+				fmt.Fprintf(&b, "%s\n", wrap.Render("$ "+command))
+			}
+		}
+		if attempt.Err != nil {
+			fmt.Fprintf(&b, "%s\n", wrap.Render(errorStyle.Render(attempt.Err.Error())))
+		}
+		if attempt.Output != "" && attempt.Transcript == "" {
+			fmt.Fprintf(&b, "%s\n", wrap.Render(attempt.Output))
+		}
+	}
+	if repo.ArchiveFile != "" {
+		fmt.Fprintf(&b, "\nArchive: %s\n", repo.ArchiveFile)
+	}
+	if repo.LogFile != "" {
+		fmt.Fprintf(&b, "\nSaved to %s\n", repo.LogFile)
+	}
+	return b.String()
+}
+
+// detailView renders the open detail pane
+func (m Model) detailView() string {
+	return detailStyle.Render(m.Detail.View()) + "\n" + fmt.Sprintf("%s and %s to scroll, '%s' to copy the error, %s to go back", keyOf(m.keys.Up), keyOf(m.keys.Down), keyOf(m.keys.Copy), keyOf(m.keys.Back))
+}
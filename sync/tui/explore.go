@@ -0,0 +1,325 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// What the explore input is collecting
+const (
+	editInclude = "include"
+	editExclude = "exclude"
+	editSave    = "save"
+)
+
+// ExploreModel browses the repositories of a target with their metadata and lets the
+// user compose a Profile of include and exclude rules, which is saved to the config
+type ExploreModel struct {
+	Options sync.Options
+	// ConfigPath is where profiles are saved
+	ConfigPath   string
+	Repositories []sync.Repository
+	// Profile holds the rules composed so far
+	Profile sync.Profile
+	Table   table.Model
+	Input   textinput.Model
+	Spinner spinner.Model
+	Width   int
+	Height  int
+	Notice  string
+	Err     error
+
+	loaded bool
+	// editing is what the input is collecting, or empty when it is closed
+	editing string
+	// added records whether each rule was an include, so backspace can undo the last one
+	added []bool
+}
+
+// exploreFetchedMsg carries the repositories discovered for exploring
+type exploreFetchedMsg struct {
+	Repositories []sync.Repository
+	Err          error
+}
+
+// exploreColumns are the columns of the explore table
+var exploreColumns = []table.Column{
+	{Title: "Repository", Width: 28},
+	{Title: "Size", Width: 10},
+	{Title: "Language", Width: 12},
+	{Title: "Last push", Width: 10},
+	{Title: "Topics", Width: 24},
+}
+
+// NewExploreModel starts exploring the target of opts, beginning with the rules of
+// opts.Selection and offering opts.Profile as the name to save under
+func NewExploreModel(opts sync.Options, configPath string) ExploreModel {
+	spn := spinner.New()
+	spn.Style = spinnerStyle
+
+	tbl := table.New(
+		table.WithColumns(exploreColumns),
+		table.WithHeight(10),
+		table.WithFocused(true),
+		table.WithStyles(tableStyles()),
+	)
+
+	m := ExploreModel{
+		Options:    opts,
+		ConfigPath: configPath,
+		Profile:    opts.Selection,
+		Table:      tbl,
+		Input:      textinput.New(),
+		Spinner:    spn,
+	}
+	for range opts.Selection.Include {
+		m.added = append(m.added, true)
+	}
+	for range opts.Selection.Exclude {
+		m.added = append(m.added, false)
+	}
+	return m
+}
+
+func (m ExploreModel) Init() tea.Cmd {
+	return tea.Batch(m.fetch, m.Spinner.Tick)
+}
+
+// fetch discovers the repositories to explore
+func (m ExploreModel) fetch() tea.Msg {
+	repos, err := sync.Discover(m.Options)
+	return exploreFetchedMsg{Repositories: repos, Err: err}
+}
+
+// Update processes messages and updates the state of the ExploreModel
+func (m ExploreModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.editing != "" {
+			return m.updateInput(msg)
+		}
+		m.Notice = ""
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "+":
+			return m.openInput(editInclude, "include rule: ", "")
+		case "-":
+			return m.openInput(editExclude, "exclude rule: ", "")
+		case "s":
+			return m.openInput(editSave, "save as profile: ", m.Options.Profile)
+		case "l", "L":
+			if repo, ok := m.selected(); ok && repo.Language != "" {
+				m.addRule("language:"+repo.Language, msg.String() == "l")
+			}
+			return m, nil
+		case "x":
+			if repo, ok := m.selected(); ok {
+				m.addRule("name:"+repo.Name, false)
+			}
+			return m, nil
+		case "backspace":
+			m.removeLastRule()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.Table, cmd = m.Table.Update(msg)
+		return m, cmd
+	case exploreFetchedMsg:
+		m.loaded = true
+		m.Repositories = msg.Repositories
+		m.Err = msg.Err
+		m.refreshTable()
+		return m, nil
+	case noticeMsg:
+		m.Notice = msg.Text
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.Width = msg.Width
+		m.Height = msg.Height
+		m.Table.SetHeight(max(msg.Height-chromeHeight, minTableHeight))
+		return m, nil
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.Spinner, cmd = m.Spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// openInput focuses the input to collect a rule or profile name
+func (m ExploreModel) openInput(editing, prompt, value string) (tea.Model, tea.Cmd) {
+	m.editing = editing
+	m.Input.Prompt = prompt
+	m.Input.SetValue(value)
+	m.Input.CursorEnd()
+	m.Table.Blur()
+	return m, m.Input.Focus()
+}
+
+// updateInput handles keys while the input is open
+func (m ExploreModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.closeInput()
+		return m, nil
+	case "enter":
+		value := strings.TrimSpace(m.Input.Value())
+		editing := m.editing
+		m.closeInput()
+		if value == "" {
+			return m, nil
+		}
+		if editing == editSave {
+			m.Options.Profile = value
+			return m, m.save(value)
+		}
+		if err := sync.ValidateRule(value); err != nil {
+			m.Notice = err.Error()
+			return m, nil
+		}
+		m.addRule(value, editing == editInclude)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.Input, cmd = m.Input.Update(msg)
+	return m, cmd
+}
+
+func (m *ExploreModel) closeInput() {
+	m.editing = ""
+	m.Input.Blur()
+	m.Input.Reset()
+	m.Table.Focus()
+}
+
+// addRule adds an include or exclude rule and updates the table
+func (m *ExploreModel) addRule(rule string, include bool) {
+	if include {
+		m.Profile.Include = append(m.Profile.Include, rule)
+	} else {
+		m.Profile.Exclude = append(m.Profile.Exclude, rule)
+	}
+	m.added = append(m.added, include)
+	m.refreshTable()
+}
+
+// removeLastRule undoes the most recently added rule
+func (m *ExploreModel) removeLastRule() {
+	if len(m.added) == 0 {
+		return
+	}
+	include := m.added[len(m.added)-1]
+	m.added = m.added[:len(m.added)-1]
+	if include {
+		m.Profile.Include = m.Profile.Include[:len(m.Profile.Include)-1]
+	} else {
+		m.Profile.Exclude = m.Profile.Exclude[:len(m.Profile.Exclude)-1]
+	}
+	m.refreshTable()
+}
+
+// save stores the composed profile in the config file
+func (m ExploreModel) save(name string) tea.Cmd {
+	profile, path := m.Profile, m.ConfigPath
+	return func() tea.Msg {
+		if err := sync.SaveProfile(path, name, profile); err != nil {
+			return noticeMsg{Text: err.Error()}
+		}
+		return noticeMsg{Text: fmt.Sprintf("Saved profile %q to %s. Sync it with --profile %s.", name, path, name)}
+	}
+}
+
+// selected returns the repository under the table cursor
+func (m ExploreModel) selected() (sync.Repository, bool) {
+	row := m.Table.SelectedRow()
+	if row == nil {
+		return sync.Repository{}, false
+	}
+	for _, repo := range m.Repositories {
+		if repo.Name == row[colName] {
+			return repo, true
+		}
+	}
+	return sync.Repository{}, false
+}
+
+// matching returns the repositories the composed profile selects
+func (m ExploreModel) matching() []sync.Repository {
+	return m.Profile.Filter(m.Repositories)
+}
+
+// refreshTable shows the repositories the profile currently selects
+func (m *ExploreModel) refreshTable() {
+	repos := m.matching()
+	rows := make([]table.Row, len(repos))
+	for i, repo := range repos {
+		pushed := ""
+		if !repo.PushedAt.IsZero() {
+			pushed = repo.PushedAt.Format(time.DateOnly)
+		}
+		rows[i] = table.Row{repo.Name, sync.FormatBytes(repo.Size), repo.Language, pushed, strings.Join(repo.Topics, ", ")}
+	}
+	m.Table.SetRows(fitRows(rows, exploreColumns))
+	if cursor := m.Table.Cursor(); cursor >= len(rows) {
+		m.Table.SetCursor(max(len(rows)-1, 0))
+	}
+}
+
+func (m ExploreModel) View() string {
+	var builder strings.Builder
+	center := func(s string) string {
+		return lipgloss.Place(m.Width, len(strings.Split(s, "\n")), lipgloss.Center, lipgloss.Center, s)
+	}
+
+	builder.WriteString(center(titleStyle.Render("OrgSync Explore")) + "\n\n")
+	info := m.Options.Header()
+	if m.loaded {
+		info = fmt.Sprintf("%s%s%d of %d repositories selected", info, glyphs.separator, len(m.Table.Rows()), len(m.Repositories))
+	}
+	builder.WriteString(center(normalText.Render(info)) + "\n\n")
+
+	switch {
+	case !m.loaded:
+		builder.WriteString(center(m.Spinner.View()+" Loading...") + "\n")
+		return builder.String()
+	case m.Err != nil:
+		builder.WriteString(center(errorStyle.Render(m.Err.Error())) + "\n\n")
+		builder.WriteString(center("Press 'q' to quit.") + "\n")
+		return builder.String()
+	}
+
+	include, exclude := "everything", "nothing"
+	if len(m.Profile.Include) > 0 {
+		include = strings.Join(m.Profile.Include, ", ")
+	}
+	if len(m.Profile.Exclude) > 0 {
+		exclude = strings.Join(m.Profile.Exclude, ", ")
+	}
+	builder.WriteString(center(successStyle.Render("Include: "+include)) + "\n")
+	builder.WriteString(center(errorStyle.Render("Exclude: "+exclude)) + "\n\n")
+
+	builder.WriteString(center(m.Table.View()) + "\n\n")
+
+	if m.editing != "" {
+		builder.WriteString(center(m.Input.View()) + "\n")
+		builder.WriteString(center("Rules: name:<glob>, language:<glob>, topic:<glob> or pushed:<days>d. Press enter to add, esc to cancel.") + "\n")
+	} else {
+		builder.WriteString(center("'+'/'-' add an include/exclude rule, 'l'/'L' include/exclude the selected language, 'x' exclude the selected repository,") + "\n")
+		builder.WriteString(center("backspace removes the last rule, 's' saves the profile, 'q' quits.") + "\n")
+	}
+
+	if m.Notice != "" {
+		builder.WriteString("\n" + center(pendingStyle.Render(m.Notice)) + "\n")
+	}
+	return builder.String()
+}
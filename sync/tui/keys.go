@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// KeyMap holds the key bindings of the terminal UI. The hints on the screen name the
+// keys from the help of each binding, so they stay right when the keys change.
+type KeyMap struct {
+	Up           key.Binding
+	Down         key.Binding
+	PageUp       key.Binding
+	PageDown     key.Binding
+	HalfPageUp   key.Binding
+	HalfPageDown key.Binding
+	Top          key.Binding
+	Bottom       key.Binding
+
+	Details key.Binding
+	// Toggle collapses or expands the section whose header row is selected
+	Toggle key.Binding
+	// Back closes the detail pane or the help, and clears the filter
+	Back   key.Binding
+	Filter key.Binding
+	Group  key.Binding
+	Skip   key.Binding
+	Copy   key.Binding
+	// Log opens or closes the pane showing the latest log records below the table
+	Log key.Binding
+
+	Save        key.Binding
+	Rerun       key.Binding
+	RetryFailed key.Binding
+	Continue    key.Binding
+	Abort       key.Binding
+	// Confirm and Decline answer whether to go ahead with pruning
+	Confirm key.Binding
+	Decline key.Binding
+	Help    key.Binding
+	Quit    key.Binding
+}
+
+// DefaultKeyMap returns the bindings used unless the config file changes them
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up:           key.NewBinding(key.WithKeys("up", "k"), key.WithHelp(glyphs.up+"/k", "move up")),
+		Down:         key.NewBinding(key.WithKeys("down", "j"), key.WithHelp(glyphs.down+"/j", "move down")),
+		PageUp:       key.NewBinding(key.WithKeys("pgup", "b"), key.WithHelp("pgup/b", "page up")),
+		PageDown:     key.NewBinding(key.WithKeys("pgdown", " "), key.WithHelp("pgdn/space", "page down")),
+		HalfPageUp:   key.NewBinding(key.WithKeys("u", "ctrl+u"), key.WithHelp("u", "move half a page up")),
+		HalfPageDown: key.NewBinding(key.WithKeys("d", "ctrl+d"), key.WithHelp("d", "move half a page down")),
+		Top:          key.NewBinding(key.WithKeys("home"), key.WithHelp("home", "go to the top")),
+		Bottom:       key.NewBinding(key.WithKeys("end", "G"), key.WithHelp("end/G", "go to the bottom")),
+
+		Details: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "show details")),
+		Toggle:  key.NewBinding(key.WithKeys("enter", " "), key.WithHelp("enter/space", "collapse or expand a group")),
+		Back:    key.NewBinding(key.WithKeys("esc", "backspace"), key.WithHelp("esc", "go back or clear the filter")),
+		Filter:  key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter repositories")),
+		Group:   key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "group the table")),
+		Skip:    key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "skip the selected repository")),
+		Copy:    key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy the selected error")),
+		Log:     key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "show or hide the log")),
+
+		Save:        key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "save a summary")),
+		Rerun:       key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "run again")),
+		RetryFailed: key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "retry failures")),
+		Continue:    key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "continue a paused run")),
+		Abort:       key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "abort a paused run")),
+		Confirm:     key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "confirm pruning")),
+		Decline:     key.NewBinding(key.WithKeys("n", "N"), key.WithHelp("n", "decline pruning")),
+		Help:        key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "list all keys")),
+		Quit:        key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+	}
+}
+
+// keys are the bindings of every model created after ApplyKeys
+var keys = DefaultKeyMap()
+
+// ApplyKeys sets the bindings used by every model created afterwards
+func ApplyKeys(k KeyMap) {
+	keys = k
+}
+
+// bindings maps the names of the bindings in the config file to them
+func (k *KeyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up":             &k.Up,
+		"down":           &k.Down,
+		"page_up":        &k.PageUp,
+		"page_down":      &k.PageDown,
+		"half_page_up":   &k.HalfPageUp,
+		"half_page_down": &k.HalfPageDown,
+		"top":            &k.Top,
+		"bottom":         &k.Bottom,
+		"details":        &k.Details,
+		"toggle_group":   &k.Toggle,
+		"back":           &k.Back,
+		"filter":         &k.Filter,
+		"group":          &k.Group,
+		"skip":           &k.Skip,
+		"copy":           &k.Copy,
+		"log":            &k.Log,
+		"save":           &k.Save,
+		"rerun":          &k.Rerun,
+		"retry_failed":   &k.RetryFailed,
+		"continue":       &k.Continue,
+		"abort":          &k.Abort,
+		"confirm":        &k.Confirm,
+		"decline":        &k.Decline,
+		"help":           &k.Help,
+		"quit":           &k.Quit,
+	}
+}
+
+// KeyNames lists the names of the bindings the config file can change
+func KeyNames() []string {
+	var k KeyMap
+	names := make([]string, 0, len(k.bindings()))
+	for name := range k.bindings() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LookupKeys returns the default bindings with the keys the config file sets, by
+// binding name. A binding keeps its description and is shown with its first key.
+func LookupKeys(config map[string][]string) (KeyMap, error) {
+	k := DefaultKeyMap()
+	bindings := k.bindings()
+	for name, values := range config {
+		binding, ok := bindings[name]
+		if !ok {
+			return KeyMap{}, fmt.Errorf("unknown key binding %q (expected one of %s)", name, strings.Join(KeyNames(), ", "))
+		}
+		if len(values) == 0 || slices.Contains(values, "") {
+			return KeyMap{}, fmt.Errorf("key binding %q needs at least one key", name)
+		}
+		binding.SetKeys(values...)
+		binding.SetHelp(keyLabel(values[0]), binding.Help().Desc)
+	}
+	return k, nil
+}
+
+// keyLabel shows a key as the UI names it
+func keyLabel(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return k
+}
+
+// tableKeyMap returns the bindings that move around the table
+func (k KeyMap) tableKeyMap() table.KeyMap {
+	return table.KeyMap{
+		LineUp:       k.Up,
+		LineDown:     k.Down,
+		PageUp:       k.PageUp,
+		PageDown:     k.PageDown,
+		HalfPageUp:   k.HalfPageUp,
+		HalfPageDown: k.HalfPageDown,
+		GotoTop:      k.Top,
+		GotoBottom:   k.Bottom,
+	}
+}
+
+// viewportKeyMap returns the bindings that scroll the detail pane
+func (k KeyMap) viewportKeyMap() viewport.KeyMap {
+	return viewport.KeyMap{
+		Up:           k.Up,
+		Down:         k.Down,
+		PageUp:       k.PageUp,
+		PageDown:     k.PageDown,
+		HalfPageUp:   k.HalfPageUp,
+		HalfPageDown: k.HalfPageDown,
+	}
+}
+
+// FullHelp returns every binding in columns, for the help overlay
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.PageUp, k.PageDown, k.HalfPageUp, k.HalfPageDown, k.Top, k.Bottom},
+		{k.Details, k.Toggle, k.Back, k.Filter, k.Group, k.Skip, k.Copy, k.Log},
+		{k.Save, k.Rerun, k.RetryFailed, k.Continue, k.Abort, k.Confirm, k.Decline, k.Help, k.Quit},
+	}
+}
+
+// keyOf names the key of a binding for a hint on the screen, e.g. "q"
+func keyOf(b key.Binding) string {
+	return b.Help().Key
+}
+
+// helpView renders the help overlay listing every binding
+func (m Model) helpView() string {
+	h := help.New()
+	h.FullSeparator = "    "
+	h.Styles.FullKey = pendingStyle
+	h.Styles.FullDesc = normalText
+	h.Styles.FullSeparator = normalText
+	return detailStyle.Render(h.FullHelpView(m.keys.FullHelp())) + "\n" + fmt.Sprintf("Press '%s' or '%s' to close.", keyOf(m.keys.Help), keyOf(m.keys.Back))
+}
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rerun starts the same run again in place, optionally restricted to the failed repositories
+func (m Model) rerun(failedOnly bool) (tea.Model, tea.Cmd) {
+	opts := m.Options
+	// Running again means running everything, not just what the resumed run left over
+	opts.Completed = nil
+	if failedOnly {
+		opts.Only = m.Failed()
+	}
+	// The next run tells what changed since this one
+	if m.Done {
+		opts.Baseline = opts.Baseline.Next(m.Report())
+	}
+
+	next := NewModel(opts)
+	next.AfterRun = m.AfterRun
+	next.History = m.History
+	if m.Done {
+		next.History = append(next.History, m.summary())
+		if len(next.History) > maxRunHistory {
+			next.History = next.History[len(next.History)-maxRunHistory:]
+		}
+	}
+	next.Width = m.Width
+	next.Height = m.Height
+	next.Progress.Width = m.Progress.Width
+	next.run = m.run + 1
+	return next, next.Init()
+}
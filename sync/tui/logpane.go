@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// logPaneLines is how many of the latest log records the log pane shows
+	logPaneLines = 8
+	// logPaneHeight is the number of lines the log pane takes from the table
+	logPaneHeight = logPaneLines + 3
+	// logPaneRefresh is how often the open log pane shows new records, as records
+	// such as retries are written while no event arrives
+	logPaneRefresh = time.Second
+)
+
+// logTickMsg has the open log pane show the records written since the last render
+type logTickMsg struct{}
+
+// logTick returns the command refreshing the log pane
+func logTick() tea.Cmd {
+	return tea.Tick(logPaneRefresh, func(time.Time) tea.Msg { return logTickMsg{} })
+}
+
+// toggleLog opens or closes the log pane below the table. The refresh keeps ticking
+// until the pane is found closed, so reopening it right away needs no second one.
+func (m Model) toggleLog() (tea.Model, tea.Cmd) {
+	m.showLog = !m.showLog
+	m.resizeTable()
+	if m.showLog && !m.logTicking {
+		m.logTicking = true
+		return m, logTick()
+	}
+	return m, nil
+}
+
+// resizeTable lets the table use whatever vertical space the rest of the view leaves
+func (m *Model) resizeTable() {
+	height := m.Height - chromeHeight
+	if m.showLog {
+		height -= logPaneHeight
+	}
+	m.Table.SetHeight(max(height, minTableHeight))
+}
+
+// logView renders the log pane: the latest records of the engine, cut to the width
+// of the screen
+func (m Model) logView() string {
+	width := min(max(m.Width-padding*2, 40), maxWidth+20) - detailStyle.GetHorizontalFrameSize()
+	lines := sync.RecentLogs(logPaneLines)
+	if len(lines) == 0 {
+		lines = []string{"Nothing logged yet."}
+	}
+	for i, line := range lines {
+		lines[i] = runewidth.Truncate(line, width, glyphs.ellipsis)
+	}
+	for len(lines) < logPaneLines {
+		lines = append(lines, "")
+	}
+	title := fmt.Sprintf("Log. Press '%s' to hide it.", keyOf(m.keys.Log))
+	return title + "\n" + detailStyle.Width(width+detailStyle.GetHorizontalPadding()).Render(strings.Join(lines, "\n"))
+}
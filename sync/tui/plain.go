@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// plainOutput is where plain mode writes its lines, and quietOutput where quiet mode
+// writes what went wrong
+var (
+	plainOutput io.Writer = os.Stdout
+	quietOutput io.Writer = os.Stderr
+)
+
+// InterruptMsg tells the model that the user interrupted the program, e.g. with Ctrl+C
+// while it reads no keys in plain mode. It is handled like the quit key: the first one
+// lets the running syncs finish, the second stops them. A paused run is aborted.
+type InterruptMsg struct{}
+
+// plainLines renders what happened in events as lines of plain text, one per finished
+// repository, for screen readers and terminals that cannot redraw the screen
+func (m *Model) plainLines(events []sync.Event) []string {
+	var lines []string
+	for _, event := range events {
+		switch event := event.(type) {
+		case *sync.DiscoveredEvent:
+			if event.Err != nil {
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s, %d repositories, %s", m.Options.Header(), len(m.Repositories), sync.FormatBytes(m.TotalSize())))
+			for _, warning := range event.Warnings {
+				lines = append(lines, "Warning: "+warning)
+			}
+		case *sync.RateLimitedEvent:
+			if status := m.rateLimitStatus(); status != "" {
+				lines = append(lines, status)
+			}
+		case *sync.RepositoryFinishedEvent:
+			m.reported++
+			lines = append(lines, fmt.Sprintf("[%d/%d] %s: %s", m.reported, len(m.Repositories), event.Repository.Name, sync.PlainStatus(event.Repository)))
+			if len(m.Options.Exec) > 0 {
+				lines = append(lines, sync.IndentOutput(event.Repository.ExecOutput())...)
+			}
+		case *sync.PausedEvent:
+			lines = append(lines, fmt.Sprintf("Paused after %d repositories in a row failed with %s errors.", event.Failures, event.Category))
+			if hint := sync.Hint(event.Category); hint != "" {
+				lines = append(lines, "Hint: "+hint)
+			}
+			lines = append(lines, "Interrupt the program, e.g. with Ctrl+C, to abort the run.")
+		case *sync.PrunedEvent:
+			if event.Err != nil {
+				lines = append(lines, "Error: "+event.Err.Error())
+			}
+		case *sync.RunFinishedEvent:
+			lines = append(lines, m.plainSummary()...)
+		}
+	}
+	return lines
+}
+
+// plainSummary renders the outcome of a finished run as lines of plain text
+func (m Model) plainSummary() []string {
+	var lines []string
+	if m.Stopped {
+		lines = append(lines, "Stopped after the first failure (--fail-fast).")
+	}
+	for _, err := range m.Errors {
+		lines = append(lines, "Error: "+err.Error())
+		if hint := sync.Hint(sync.ClassifyError(err)); hint != "" {
+			lines = append(lines, "Hint: "+hint)
+		}
+	}
+
+	report := m.Report()
+	t := report.Totals
+	finished := fmt.Sprintf("Finished in %s: %s, %s transferred.", m.FinishedAt.Sub(m.StartedAt).Round(time.Second), plainTotals(t), sync.FormatBytes(t.Bytes))
+	if len(m.Options.Exec) > 0 {
+		// An exec run transfers nothing
+		finished = fmt.Sprintf("Finished in %s: %s.", m.FinishedAt.Sub(m.StartedAt).Round(time.Second), plainTotals(t))
+	}
+	lines = append(lines, finished)
+	for _, hint := range failureHints(report) {
+		lines = append(lines, "Hint: "+hint)
+	}
+
+	for _, summary := range []string{sync.QueueWarning(report), m.pruneSummary(), m.archiveSummary(report), m.packSummary(), m.unpushedSummary(), m.divergenceSummary(), m.deltaSummary()} {
+		if summary != "" {
+			lines = append(lines, summary)
+		}
+	}
+	return lines
+}
+
+// plainTotals counts the outcomes of a run in words, e.g. "2 succeeded, 1 failed"
+func plainTotals(t sync.ReportTotals) string {
+	succeeded := fmt.Sprintf("%d succeeded", t.Succeeded)
+	if t.UpToDate > 0 {
+		succeeded += fmt.Sprintf(" (%d up to date)", t.UpToDate)
+	}
+	parts := []string{succeeded}
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{t.Failed + t.Conflicts, "failed"},
+		{t.Skipped, "skipped"},
+		{t.Cancelled + t.Pending, "not synchronized"},
+		{t.Warnings, "warnings"},
+	} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quietLines renders a finished run for quiet mode: nothing when it went well, and
+// otherwise a line of totals followed by every error and failed repository
+func (m Model) quietLines() []string {
+	r := m.Report()
+	t := r.Totals
+	if len(r.Errors) == 0 && t.Failed+t.Conflicts+t.Cancelled+t.Pending == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("orgsync %s: %s in %s (run %s)", r.Target, plainTotals(t), time.Duration(t.DurationSeconds*float64(time.Second)).Round(time.Second), r.RunID)}
+	for _, err := range r.Errors {
+		lines = append(lines, "Error: "+err)
+	}
+	for _, repo := range r.Repositories {
+		if repo.Status == sync.StatusFailed || repo.Status == sync.StatusConflict {
+			lines = append(lines, fmt.Sprintf("%s [%s]: %s", repo.Key(), repo.ErrorCategory, strings.ReplaceAll(repo.Error, "\n", " ")))
+		}
+	}
+	return lines
+}
+
+// headless reports whether a run with opts prints lines of text rather than drawing
+// the terminal UI
+func headless(opts sync.Options) bool {
+	return opts.Plain || opts.Quiet
+}
+
+// printLines writes lines where the headless mode of the run puts them
+func (m Model) printLines(lines []string) {
+	if m.Options.Quiet {
+		for _, line := range lines {
+			fmt.Fprintln(quietOutput, line)
+		}
+		return
+	}
+	printPlain(lines)
+}
+
+// printPlain writes lines to the plain output. They are written as the events arrive
+// rather than by a command, which could reorder them.
+func printPlain(lines []string) {
+	for _, line := range lines {
+		fmt.Fprintln(plainOutput, line)
+	}
+}
+
+// plainRunDone returns the command that follows a finished run in plain or quiet mode.
+// There is no completion screen to stay on, so a single run quits once it is finished.
+func (m *Model) plainRunDone() tea.Cmd {
+	if m.Options.Watch == 0 {
+		return m.quitAfterRun()
+	}
+	cmd := m.finishRun()
+	if m.Options.Plain {
+		printPlain([]string{fmt.Sprintf("Next run at %s.", m.NextRunAt.Format(time.TimeOnly))})
+	}
+	return cmd
+}
@@ -0,0 +1,252 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// The table can be grouped, with a header row per section carrying its own progress
+// bar and counts. Sections collapse to their header, so that thousands of repositories
+// stay readable. Runs synchronizing several organizations start grouped by owner, and
+// 'g' cycles through the groupings.
+
+// Groupings of the table
+const (
+	groupNone   = ""
+	groupOwner  = "organization"
+	groupStatus = "status"
+	groupPrefix = "name prefix"
+	groupTopic  = "topic"
+)
+
+// Section titles for repositories that fit no group of their kind
+const (
+	noPrefix = "(no prefix)"
+	noTopic  = "(no topic)"
+)
+
+// statusGroups are the titles of the status sections, in the order they are shown
+var statusGroups = []string{"Failed", "Skipped", "Syncing", "Queued", "Done"}
+
+// section is the part of the table holding one group of repositories
+type section struct {
+	Title string
+	// repos are the positions of its repositories in Model.Repositories
+	repos []int
+}
+
+// groupings lists the groupings 'g' cycles through for the run, starting with the one
+// the table has at first
+func (m Model) groupings() []string {
+	if len(m.Options.Owners) > 1 {
+		return []string{groupOwner, groupStatus, groupPrefix, groupTopic, groupNone}
+	}
+	return []string{groupNone, groupStatus, groupPrefix, groupTopic}
+}
+
+// cycleGrouping switches the table to the next grouping
+func (m *Model) cycleGrouping() {
+	groupings := m.groupings()
+	next := (slices.Index(groupings, m.grouping) + 1) % len(groupings)
+	m.grouping = groupings[next]
+	m.Table.SetCursor(0)
+	m.refreshTable()
+}
+
+// statusGroup returns the title of the status section of repo
+func statusGroup(repo sync.Repository) string {
+	switch {
+	case sync.SkipReason(repo.Err) != "":
+		return "Skipped"
+	case repo.Err != nil:
+		return "Failed"
+	case repo.Done:
+		return "Done"
+	case !repo.StartedAt.IsZero():
+		return "Syncing"
+	default:
+		return "Queued"
+	}
+}
+
+// namePrefix returns the part of name before its first -, _ or ., e.g. "payments" for
+// payments-api, or "" if it has none
+func namePrefix(name string) string {
+	if i := strings.IndexAny(name, "-_."); i > 0 {
+		return strings.ToLower(name[:i])
+	}
+	return ""
+}
+
+// groupSections splits the repositories into the sections of the current grouping. A
+// repository with several topics is in the section of each.
+func (m Model) groupSections() []section {
+	var (
+		sections  []section
+		positions = make(map[string]int)
+	)
+	add := func(title string, i int) {
+		p, ok := positions[title]
+		if !ok {
+			p = len(sections)
+			positions[title] = p
+			sections = append(sections, section{Title: title})
+		}
+		if i >= 0 {
+			sections[p].repos = append(sections[p].repos, i)
+		}
+	}
+
+	switch m.grouping {
+	case groupOwner:
+		// In the order the owners were given; owners of repositories enqueued from
+		// elsewhere follow
+		for _, owner := range m.Options.Owners {
+			add(owner, -1)
+		}
+		for i, repo := range m.Repositories {
+			owner := repo.Owner
+			for _, o := range m.Options.Owners {
+				if strings.EqualFold(o, owner) {
+					owner = o
+				}
+			}
+			add(owner, i)
+		}
+	case groupStatus:
+		for _, title := range statusGroups {
+			add(title, -1)
+		}
+		for i, repo := range m.Repositories {
+			add(statusGroup(repo), i)
+		}
+	case groupPrefix:
+		for i, repo := range m.Repositories {
+			prefix := namePrefix(repo.Name)
+			if prefix == "" {
+				prefix = noPrefix
+			}
+			add(prefix, i)
+		}
+		sortSections(sections, noPrefix)
+	case groupTopic:
+		for i, repo := range m.Repositories {
+			if len(repo.Topics) == 0 {
+				add(noTopic, i)
+			}
+			for _, topic := range repo.Topics {
+				add(topic, i)
+			}
+		}
+		sortSections(sections, noTopic)
+	}
+	return slices.DeleteFunc(sections, func(s section) bool { return len(s.repos) == 0 })
+}
+
+// sortSections orders sections by title, with the one titled last at the end
+func sortSections(sections []section, last string) {
+	slices.SortFunc(sections, func(a, b section) int {
+		switch {
+		case a.Title == last:
+			return 1
+		case b.Title == last:
+			return -1
+		default:
+			return strings.Compare(a.Title, b.Title)
+		}
+	})
+}
+
+// sectionRow renders the header row of a section: whether it is collapsed, the size of
+// its repositories, and a progress bar with how many are done and failed
+func (m Model) sectionRow(s section) table.Row {
+	var done, failed int
+	var size int64
+	for _, i := range s.repos {
+		repo := m.Repositories[i]
+		size += repo.Size
+		if repo.Done {
+			done++
+		}
+		if repo.Err != nil && sync.SkipReason(repo.Err) == "" {
+			failed++
+		}
+	}
+	marker := glyphs.expanded
+	if m.collapsed[m.grouping+":"+s.Title] {
+		marker = glyphs.collapsed
+	}
+	status := fmt.Sprintf("%s %d/%d", miniBar.ViewAs(float64(done)/float64(len(s.repos))), done, len(s.repos))
+	if failed > 0 {
+		status += " " + errorStyle.Render(fmt.Sprintf("%d failed", failed))
+	}
+	return table.Row{fmt.Sprintf("%s %s (%d)", marker, s.Title, len(s.repos)), sync.FormatBytes(size), status}
+}
+
+// sectionRows selects the visible rows section by section, each under its header row.
+// The repositories of a collapsed section are left out, and so are sections without a
+// repository matching the filter.
+func (m *Model) sectionRows(filter string) []table.Row {
+	m.sections = m.groupSections()
+	rows := make([]table.Row, 0, len(m.rows)+len(m.sections))
+	m.shown = make([]int, 0, len(m.rows)+len(m.sections))
+	m.headers = make(map[int]string, len(m.sections))
+	for _, s := range m.sections {
+		var members []int
+		for _, i := range s.repos {
+			if visible(m.Repositories[i], filter) {
+				members = append(members, i)
+			}
+		}
+		if filter != "" && len(members) == 0 {
+			continue
+		}
+		m.headers[len(rows)] = s.Title
+		rows = append(rows, m.sectionRow(s))
+		m.shown = append(m.shown, -1)
+		if !m.collapsed[m.grouping+":"+s.Title] {
+			for _, i := range members {
+				rows = append(rows, m.rows[i])
+			}
+			m.shown = append(m.shown, members...)
+		}
+	}
+	return rows
+}
+
+// selectedSection returns the title of the section whose header row is selected in the
+// table, if one is
+func (m Model) selectedSection() string {
+	if m.grouping == groupNone {
+		return ""
+	}
+	return m.headers[m.Table.Cursor()]
+}
+
+// toggleSection collapses or expands the section titled title, keeping its header
+// selected
+func (m *Model) toggleSection(title string) {
+	if m.collapsed == nil {
+		m.collapsed = make(map[string]bool)
+	}
+	key := m.grouping + ":" + title
+	m.collapsed[key] = !m.collapsed[key]
+	m.refreshTable()
+	for row, header := range m.headers {
+		if header == title {
+			m.Table.SetCursor(row)
+		}
+	}
+}
+
+// groupingStatus names the grouping of the table, or is empty when it is not grouped
+func (m Model) groupingStatus() string {
+	if m.grouping == groupNone {
+		return ""
+	}
+	return "Grouped by " + m.grouping
+}
@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// archiveSummary points out archived and transferred repositories on the completion
+// screen, since nothing changes upstream for them anymore. report is the Report of m.
+func (m Model) archiveSummary(report sync.Report) string {
+	var parts []string
+	if archived := sync.ArchivedNames(report); len(archived) > 0 {
+		parts = append(parts, fmt.Sprintf("%d archived upstream: %s", len(archived), strings.Join(archived, ", ")))
+	}
+	if len(m.Transferred) > 0 {
+		parts = append(parts, fmt.Sprintf("%d transferred out: %s", len(m.Transferred), asciiText(strings.Join(m.Transferred, ", "))))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// divergenceSummary tells on the completion screen which clones have commits origin
+// lacks and how many are behind it, or is empty when every one matches origin
+func (m Model) divergenceSummary() string {
+	var ahead []string
+	behind := 0
+	for _, repo := range m.Repositories {
+		if repo.Ahead > 0 {
+			ahead = append(ahead, repo.Name)
+		}
+		if repo.Behind > 0 {
+			behind++
+		}
+	}
+	var parts []string
+	if len(ahead) > 0 {
+		parts = append(parts, sync.DeltaList("with local commits", ahead))
+	}
+	if behind > 0 {
+		parts = append(parts, fmt.Sprintf("%d behind origin", behind))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Checkouts: " + strings.Join(parts, "; ")
+}
+
+// deltaSummary tells on the completion screen what changed since the last run: new
+// repositories, clones that gained commits, repositories that started failing and
+// repositories gone upstream
+func (m Model) deltaSummary() string {
+	d, ok := m.Delta()
+	if !ok {
+		return ""
+	}
+	var parts []string
+	if len(d.Appeared) > 0 {
+		parts = append(parts, sync.DeltaList("new", d.Appeared))
+	}
+	if len(d.Updated) > 0 {
+		parts = append(parts, sync.DeltaList("updated", d.Updated))
+	}
+	if len(d.Failed) > 0 {
+		parts = append(parts, sync.DeltaList("newly failing", d.Failed))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, sync.DeltaList("removed upstream", d.Removed))
+	}
+	since := m.Options.Baseline.StartedAt.Local().Format("Jan 2 15:04")
+	if len(parts) == 0 {
+		return fmt.Sprintf("Nothing changed since the last run (%s)", since)
+	}
+	return fmt.Sprintf("Since the last run (%s): %s", since, strings.Join(parts, "; "))
+}
+
+// packSummary tells on the completion screen how many repositories were packed into
+// archives
+func (m Model) packSummary() string {
+	if m.Options.Archive == "" {
+		return ""
+	}
+	var packed, unchanged int
+	for _, repo := range m.Repositories {
+		switch {
+		case repo.Packed:
+			packed++
+		case repo.ArchiveFile != "":
+			unchanged++
+		}
+	}
+	summary := fmt.Sprintf("Packed %d %s archives into %s", packed, m.Options.Archive, m.Options.ArchiveFolder())
+	if unchanged > 0 {
+		summary += fmt.Sprintf("; %d unchanged since they were last packed", unchanged)
+	}
+	return summary
+}
+
+// pruneSummary describes the outcome of pruning for the completion screen
+func (m Model) pruneSummary() string {
+	if len(m.Pruned) == 0 && len(m.Kept) == 0 && len(m.Spared) == 0 && len(m.Declined) == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("Pruned %d repositories no longer upstream", len(m.Pruned))
+	if len(m.Kept) > 0 {
+		summary += fmt.Sprintf("; kept %d pinned: %s", len(m.Kept), strings.Join(m.Kept, ", "))
+	}
+	if len(m.Spared) > 0 {
+		summary += fmt.Sprintf("; kept %d with work on no remote (--force prunes them): %s", len(m.Spared), strings.Join(m.Spared, ", "))
+	}
+	if len(m.Declined) > 0 {
+		summary += fmt.Sprintf("; kept %d as asked: %s", len(m.Declined), strings.Join(m.Declined, ", "))
+	}
+	return summary
+}
+
+// rateLimitStatus describes a pending rate-limit wait for the header
+func (m Model) rateLimitStatus() string {
+	if m.RateLimitedUntil.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("Rate limited by GitHub, retrying at %s (attempt %d of %d)", m.RateLimitedUntil.Format(time.TimeOnly), m.DiscoveryAttempts+1, m.Options.Retry.Rule(sync.CategoryRateLimit).Attempts)
+}
+
+// unpushedSummary warns on the completion screen about the clones holding commits or
+// branches that are on no remote, which deleting them would lose
+func (m Model) unpushedSummary() string {
+	var clones []string
+	for _, repo := range m.Repositories {
+		if text := sync.UnpushedText(repo); text != "" {
+			clones = append(clones, fmt.Sprintf("%s (%s)", repo.Name, text))
+		}
+	}
+	switch len(clones) {
+	case 0:
+		return ""
+	case 1:
+		return sync.DeltaList("clone has work on no remote", clones)
+	default:
+		return sync.DeltaList("clones have work on no remote", clones)
+	}
+}
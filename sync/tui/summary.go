@@ -0,0 +1,26 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// exportSummary saves the run summary to a timestamped file in the sync root
+func (m Model) exportSummary() tea.Cmd {
+	report := m.Report()
+	return func() tea.Msg {
+		path := filepath.Join(".", fmt.Sprintf("orgsync-summary-%s.txt", report.FinishedAt.Format("20060102-150405")))
+		var b strings.Builder
+		if err := sync.WriteSummary(&b, report); err != nil {
+			return noticeMsg{Text: err.Error()}
+		}
+		if err := sync.WriteFileAtomic(path, []byte(b.String()), 0o644, false); err != nil {
+			return noticeMsg{Text: fmt.Sprintf("Failed to save summary: %v", err)}
+		}
+		return noticeMsg{Text: fmt.Sprintf("Saved summary to %s", path)}
+	}
+}
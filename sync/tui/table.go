@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// repoStatus renders the Status column for a repository
+func repoStatus(repo sync.Repository) string {
+	switch {
+	case errors.Is(repo.Err, sync.ErrConflict):
+		return pendingStyle.Render(fmt.Sprintf("Conflict: %v", repo.Err))
+	case sync.SkipReason(repo.Err) != "":
+		return pendingStyle.Render("Skipped (" + sync.SkipReason(repo.Err) + ")")
+	case repo.Err != nil:
+		return errorStyle.Render(fmt.Sprintf("Error: %v", repo.Err))
+	case repo.Done && repo.Archived:
+		return archivedCell
+	case repo.Done && len(repo.Warnings) > 0:
+		return doneWarningCell + divergenceCell(repo)
+	case repo.Done && repo.UpToDate:
+		return upToDateCell + divergenceCell(repo)
+	case repo.Done:
+		return doneCell + divergenceCell(repo)
+	case repo.Progress > 0 || repo.TransferSpeed != "":
+		return progressStatus(repo.Progress, repo.TransferSpeed)
+	default:
+		return pendingCell
+	}
+}
+
+// divergenceCell renders how HEAD compares with origin after the outcome in the Status
+// column, or nothing when they match
+func divergenceCell(repo sync.Repository) string {
+	if d := sync.Divergence(repo); d != "" {
+		return pendingStyle.Render(", " + d)
+	}
+	return ""
+}
+
+// rowFor renders the table row for a repository
+func rowFor(repo sync.Repository) table.Row {
+	return table.Row{repo.Name, sync.FormatBytes(repo.Size), repoStatus(repo)}
+}
+
+// indexRepositories renders a row for every repository and indexes them by Key.
+// Rows are then re-rendered one at a time as repositories change, which keeps
+// updates cheap with thousands of repositories.
+func (m *Model) indexRepositories() {
+	m.rows = make([]table.Row, len(m.Repositories))
+	m.index = make(map[string]int, len(m.Repositories))
+	for i, repo := range m.Repositories {
+		m.rows[i] = rowFor(repo)
+		m.index[repo.Key()] = i
+	}
+}
+
+// updateRow re-renders the cached row of the repository at i
+func (m *Model) updateRow(i int) {
+	m.rows[i] = rowFor(m.Repositories[i])
+}
+
+// rowChanged reports whether the row of a repository needs to be rendered again. The
+// outcome of a repository is only ever set along with Done.
+func rowChanged(before, after sync.Repository) bool {
+	return before.Done != after.Done || before.Progress != after.Progress || before.TransferSpeed != after.TransferSpeed
+}
+
+// visible decides whether a repository has a row in the table. Successfully synced
+// repositories drop out of the table, unless a filter is active: then every matching
+// repository is shown so that the one being searched for can always be found.
+// filter must already be lower-cased.
+func visible(repo sync.Repository, filter string) bool {
+	if filter != "" {
+		return strings.Contains(strings.ToLower(repo.Name), filter)
+	}
+	return !repo.Done || repo.Err != nil
+}
+
+// refreshTable selects the visible rows from the cache
+func (m *Model) refreshTable() {
+	filter := strings.ToLower(strings.TrimSpace(m.Filter.Value()))
+	var rows []table.Row
+	if m.grouping != groupNone {
+		rows = m.sectionRows(filter)
+	} else {
+		rows = make([]table.Row, 0, len(m.rows))
+		m.shown = make([]int, 0, len(m.rows))
+		for i, repo := range m.Repositories {
+			if visible(repo, filter) {
+				rows = append(rows, m.rows[i])
+				m.shown = append(m.shown, i)
+			}
+		}
+	}
+	m.Table.SetRows(fitRows(rows, repoColumns))
+	if cursor := m.Table.Cursor(); cursor >= len(rows) {
+		m.Table.SetCursor(max(len(rows)-1, 0))
+	}
+}
+
+// selectedRepository returns the position in Repositories of the repository selected in
+// the table, reporting false when nothing or a section header is selected
+func (m Model) selectedRepository() (int, bool) {
+	cursor := m.Table.Cursor()
+	if m.Table.SelectedRow() == nil || cursor >= len(m.shown) || m.shown[cursor] < 0 {
+		return 0, false
+	}
+	return m.shown[cursor], true
+}
+
+// updateFilter handles keys while the filter input has focus
+func (m Model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.Filter.Blur()
+		m.Table.Focus()
+		return m, nil
+	case "esc":
+		m.Filter.Reset()
+		m.Filter.Blur()
+		m.Table.Focus()
+		m.refreshTable()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.Filter, cmd = m.Filter.Update(msg)
+	m.Table.SetCursor(0)
+	m.refreshTable()
+	return m, cmd
+}
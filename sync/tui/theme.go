@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/sync"
+	"github.com/muesli/termenv"
+)
+
+var (
+	theme        sync.Theme
+	colorProfile = termenv.ColorProfile()
+
+	titleStyle   lipgloss.Style
+	pendingStyle lipgloss.Style
+	errorStyle   lipgloss.Style
+	successStyle lipgloss.Style
+	spinnerStyle lipgloss.Style
+	normalText   lipgloss.Style
+	detailStyle  lipgloss.Style
+
+	// pendingCell and doneCell are rendered once rather than for every row on every refresh
+	pendingCell     string
+	doneCell        string
+	doneWarningCell string
+	upToDateCell    string
+	archivedCell    string
+
+	miniBar progress.Model
+)
+
+func init() {
+	ApplyTheme(sync.Themes[sync.DefaultTheme], false)
+}
+
+// ApplyTheme sets the palette used by every model created afterwards. With noColor
+// all output is plain text.
+func ApplyTheme(t sync.Theme, noColor bool) {
+	theme = t
+	colorProfile = termenv.ColorProfile()
+	if noColor {
+		colorProfile = termenv.Ascii
+		lipgloss.SetColorProfile(colorProfile)
+	}
+
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(t.Title)).Background(lipgloss.Color(t.TitleBackground))
+	pendingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Pending))
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Success))
+	spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Text))
+	normalText = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Text))
+	detailStyle = lipgloss.NewStyle().Border(glyphs.border).BorderForeground(lipgloss.Color(t.Border)).Padding(0, 1)
+
+	pendingCell = pendingStyle.Render("Pending")
+	doneCell = successStyle.Render("Done")
+	doneWarningCell = pendingStyle.Render("Done with warnings")
+	upToDateCell = successStyle.Render("Up to date")
+	archivedCell = pendingStyle.Render("Done (archived upstream)")
+
+	miniBar = newProgressBar(progress.WithoutPercentage(), progress.WithWidth(miniBarWidth))
+}
+
+// newProgressBar returns a progress bar in the current theme
+func newProgressBar(opts ...progress.Option) progress.Model {
+	opts = append([]progress.Option{progress.WithScaledGradient(theme.GradientStart, theme.GradientEnd), progress.WithColorProfile(colorProfile)}, opts...)
+	bar := progress.New(opts...)
+	bar.Full = glyphs.barFull
+	bar.Empty = glyphs.barEmpty
+	return bar
+}
+
+// tableStyles returns the table styles in the current theme
+func tableStyles() table.Styles {
+	styles := table.DefaultStyles()
+	styles.Selected = styles.Selected.Foreground(lipgloss.Color(theme.Selected))
+	return styles
+}
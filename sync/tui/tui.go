@@ -0,0 +1,709 @@
+// Package tui is the terminal UI of orgsync, drawn with Bubble Tea on top of the sync
+// engine. It is kept apart so that programs embedding the engine do not pull it in.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// Model is the terminal UI of a run. The run itself is carried out by a sync.Engine,
+// and the embedded State is the copy of its state the UI last rendered.
+type Model struct {
+	sync.State
+	Progress progress.Model
+	Spinner  spinner.Model
+	Table    table.Model
+	Width    int
+	Height   int
+	// Filter narrows the table to repositories whose name contains its value
+	Filter textinput.Model
+	// Detail is the scrollable detail pane for a single repository
+	Detail viewport.Model
+	// Notice is a transient message shown below the table, e.g. after copying
+	Notice string
+
+	// AfterRun, if set, is called with the model once each run is done, e.g. to write
+	// a report for every run of watch mode. An error is shown as a notice.
+	AfterRun func(Model) error
+	// Record, if set, collects the events of the first run for playing it back
+	Record *sync.Recording
+	// NextRunAt is when watch mode starts the next run, zero until this one is done
+	NextRunAt time.Time
+	// History summarizes the earlier runs of this program, oldest first
+	History []RunSummary
+
+	// keys are the key bindings, and showHelp is set while the overlay listing them is open
+	keys     KeyMap
+	showHelp bool
+	// showLog is set while the log pane is open, and logTicking while it is refreshed
+	showLog    bool
+	logTicking bool
+	// engine carries out the run and events delivers what happens in it
+	engine *sync.Engine
+	events <-chan sync.Event
+	// detailRepo is the Key of the repository shown in the detail pane, if it is open
+	detailRepo string
+	// run identifies the current run among reruns within one program
+	run int
+	// reported counts the repositories plain mode printed as finished
+	reported int
+	// rows caches the rendered table row of each repository and index maps the Key of
+	// each repository to its position in Repositories and rows. shown holds the position
+	// of the repository in each visible row of the table, or -1 for a section header.
+	rows  []table.Row
+	index map[string]int
+	shown []int
+	// grouping is how the table is grouped into sections, one of the group constants.
+	// headers maps the visible rows that are section headers to their title, and
+	// collapsed lists the sections, by grouping and title, that show their header only.
+	grouping  string
+	sections  []section
+	headers   map[int]string
+	collapsed map[string]bool
+	// completion is what the completion screen shows, worked out once the run is done
+	completion *completion
+	// ctx is cancelled to abort running git commands on quit
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+const (
+	padding  = 2
+	maxWidth = 80
+
+	// colName is the index of the repository name in a table row
+	colName = 0
+
+	// chromeHeight is the number of lines the View uses around the table
+	chromeHeight = 16
+	// minTableHeight keeps the table usable in very small terminals
+	minTableHeight = 3
+
+	// miniBarWidth is the width of the per-repository progress bars in the table
+	miniBarWidth = 12
+)
+
+// completion is what the completion screen shows about a finished run. It goes over
+// every repository, so it is worked out once when the run is done rather than by View.
+type completion struct {
+	// failed counts the failed repositories and hints suggest what to do about them
+	failed int
+	hints  []string
+	// summaries are the rendered lines shown below the table
+	summaries []string
+}
+
+// complete works out the completion screen of the finished run
+func (m *Model) complete() {
+	report := m.Report()
+	m.completion = &completion{failed: len(m.Failed()), hints: failureHints(report)}
+	for _, summary := range []struct {
+		text  string
+		style lipgloss.Style
+	}{
+		{m.warningSummary(), pendingStyle},
+		{sync.QueueWarning(report), pendingStyle},
+		{m.pruneSummary(), lipgloss.NewStyle()},
+		{m.archiveSummary(report), pendingStyle},
+		{m.packSummary(), normalText},
+		{m.unpushedSummary(), pendingStyle},
+		{m.divergenceSummary(), normalText},
+		{m.deltaSummary(), normalText},
+	} {
+		if summary.text != "" {
+			m.completion.summaries = append(m.completion.summaries, summary.style.Render(summary.text))
+		}
+	}
+}
+
+// repoColumns are the columns of the repository table
+var repoColumns = []table.Column{
+	{Title: "Repository", Width: 30},
+	{Title: "Size", Width: 10},
+	{Title: "Status", Width: 30},
+}
+
+func NewModel(opts sync.Options) Model {
+	progressBar := newProgressBar()
+	spn := spinner.New()
+	spn.Style = spinnerStyle
+
+	tbl := table.New(
+		table.WithColumns(repoColumns),
+		table.WithHeight(10),
+		table.WithFocused(true),
+		table.WithStyles(tableStyles()),
+		table.WithKeyMap(keys.tableKeyMap()),
+	)
+
+	filter := textinput.New()
+	filter.Prompt = "/"
+	filter.Placeholder = "filter repositories"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := time.Now()
+
+	var grouping string
+	if len(opts.Owners) > 1 {
+		grouping = groupOwner
+	}
+
+	return Model{
+		State:    sync.State{RunID: sync.NewRunID(started), Options: opts, StartedAt: started},
+		grouping: grouping,
+		Progress: progressBar,
+		Spinner:  spn,
+		Table:    tbl,
+		Filter:   filter,
+		keys:     keys,
+		engine:   &sync.Engine{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (m Model) Init() tea.Cmd {
+	// Plain and quiet mode have no spinner to animate
+	if headless(m.Options) {
+		return m.startRun
+	}
+	return tea.Batch(m.startRun, m.Spinner.Tick)
+}
+
+// Update processes messages and updates the state of the Model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.Filter.Focused() {
+			return m.updateFilter(msg)
+		}
+		if m.showHelp {
+			if key.Matches(msg, m.keys.Help, m.keys.Back) {
+				m.showHelp = false
+			}
+			return m, nil
+		}
+		if m.detailRepo != "" {
+			return m.updateDetail(msg)
+		}
+		if title := m.selectedSection(); title != "" {
+			switch {
+			case key.Matches(msg, m.keys.Toggle):
+				m.toggleSection(title)
+				return m, nil
+			case key.Matches(msg, m.keys.Skip, m.keys.Copy):
+				return m, nil
+			}
+		}
+		switch {
+		case key.Matches(msg, m.keys.Details):
+			return m.openDetail()
+		case key.Matches(msg, m.keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, m.keys.Filter):
+			m.Table.Blur()
+			return m, m.Filter.Focus()
+		case key.Matches(msg, m.keys.Group):
+			m.cycleGrouping()
+			return m, nil
+		case key.Matches(msg, m.keys.Log):
+			return m.toggleLog()
+		case key.Matches(msg, m.keys.Back):
+			m.Filter.Reset()
+			m.refreshTable()
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Copy):
+			return m, m.copySelectedFailure()
+		case key.Matches(msg, m.keys.Skip):
+			if i, ok := m.selectedRepository(); ok && !m.Done {
+				if err := m.engine.Skip(m.Repositories[i].Key()); err != nil {
+					m.Notice = "Error: " + err.Error()
+				} else {
+					m.Notice = "Skipping " + m.Repositories[i].Key()
+				}
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.Rerun):
+			if m.Done {
+				return m.rerun(false)
+			}
+		case key.Matches(msg, m.keys.RetryFailed):
+			if m.Done && len(m.Failed()) > 0 {
+				return m.rerun(true)
+			}
+		case key.Matches(msg, m.keys.Save):
+			if m.Done {
+				return m, m.exportSummary()
+			}
+		case key.Matches(msg, m.keys.Continue):
+			if m.Paused {
+				if err := m.engine.Resume(); err != nil {
+					m.Notice = "Error: " + err.Error()
+				}
+				m.State = m.engine.State()
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.Abort):
+			if m.Paused {
+				// The queued repositories finish as cancelled and the run is done
+				m.cancel()
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.Confirm, m.keys.Decline):
+			if len(m.Confirming) > 0 {
+				if err := m.engine.ConfirmPrune(key.Matches(msg, m.keys.Confirm)); err != nil {
+					m.Notice = "Error: " + err.Error()
+				}
+				m.State = m.engine.State()
+				return m, nil
+			}
+		}
+		var cmd tea.Cmd
+		m.Table, cmd = m.Table.Update(msg)
+		return m, cmd
+	case InterruptMsg:
+		if m.Paused && !m.Done {
+			if m.Options.Plain {
+				printPlain([]string{"Aborting the run."})
+			}
+			m.cancel()
+			return m, nil
+		}
+		return m.quit()
+	case noticeMsg:
+		m.Notice = msg.Text
+		if headless(m.Options) {
+			m.printLines([]string{msg.Text})
+		}
+		return m, nil
+	case logTickMsg:
+		if !m.showLog {
+			m.logTicking = false
+			return m, nil
+		}
+		return m, logTick()
+	case nextRunMsg:
+		if msg.Run == m.run && m.Done {
+			return m.rerun(false)
+		}
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.Width = msg.Width
+		m.Height = msg.Height
+		m.Progress.Width = msg.Width - padding*2 - 4
+		if m.Progress.Width > maxWidth {
+			m.Progress.Width = maxWidth
+		}
+		m.resizeTable()
+		return m, nil
+	case runStartedMsg:
+		if msg.Run != m.run {
+			return m, nil
+		}
+		if msg.Err != nil {
+			m.Errors = append(m.Errors, msg.Err)
+			m.Done = true
+			m.FinishedAt = time.Now()
+			m.complete()
+			switch {
+			case m.Options.Plain:
+				printPlain(m.plainSummary())
+			case m.Options.Quiet:
+				m.printLines(m.quietLines())
+			}
+			if headless(m.Options) {
+				cmd := m.plainRunDone()
+				return m, cmd
+			}
+			return m, m.finishRun()
+		}
+		m.events = msg.Events
+		return m, m.listenForEvents
+	case eventsMsg:
+		if msg.Run != m.run {
+			return m, nil
+		}
+		return m.applyEvents(msg.Events)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.Spinner, cmd = m.Spinner.Update(msg)
+		return m, cmd
+	case progress.FrameMsg:
+		// Handle progress bar animation
+		progressModel, cmd := m.Progress.Update(msg)
+		m.Progress = progressModel.(progress.Model)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// quit handles the quit key: the first press lets the running syncs finish, the
+// second kills them
+func (m Model) quit() (tea.Model, tea.Cmd) {
+	if !m.Draining && !m.Done && m.engine.Drain() == nil {
+		m.State = m.engine.State()
+		if m.Options.Plain {
+			printPlain([]string{fmt.Sprintf("Waiting for %d running syncs to finish. Interrupt again to stop them now.", m.running())})
+		}
+		return m, nil
+	}
+	m.cancel()
+	return m, tea.Quit
+}
+
+func (m Model) View() string {
+	var builder strings.Builder
+	title := titleStyle.Render("OrgSync")
+	info := m.Options.Header()
+	if len(m.Repositories) > 0 {
+		info = fmt.Sprintf("%s%s%d repositories%s%s", info, glyphs.separator, len(m.Repositories), glyphs.separator, sync.FormatBytes(m.TotalSize()))
+	}
+	if n := len(m.Options.Completed); n > 0 {
+		info += fmt.Sprintf("%s%d already synced", glyphs.separator, n)
+	}
+	if rate := m.engine.TransferRate(); rate > 0 {
+		info += glyphs.separator + sync.FormatRate(rate)
+	}
+	if eta := m.ETAStatus(time.Now()); eta != "" {
+		info += glyphs.separator + eta
+	}
+	if quota := m.RateLimit.String(); quota != "" {
+		info += glyphs.separator + quota
+	}
+	orgInfo := normalText.Render(info)
+	progressBar := m.Progress.View()
+	loadingSpinner := m.Spinner.View() + " Loading..."
+	if len(m.Repositories) == 0 && m.ListedPages > 0 {
+		loadingSpinner = m.Spinner.View() + fmt.Sprintf(" Listing repositories... %d so far (page %d)", m.Listed, m.ListedPages)
+	}
+	tableView := m.Table.View()
+	done := m.completion
+	if done == nil {
+		done = &completion{}
+	}
+
+	center := func(s string) string {
+		return lipgloss.Place(m.Width, len(strings.Split(s, "\n")), lipgloss.Center, lipgloss.Center, s)
+	}
+
+	builder.WriteString(center(title) + "\n\n")
+	builder.WriteString(center(orgInfo) + "\n\n")
+	builder.WriteString(center(progressBar) + "\n\n")
+
+	if m.showHelp {
+		builder.WriteString(center(m.helpView()) + "\n")
+		return builder.String()
+	}
+
+	if m.detailRepo != "" {
+		builder.WriteString(center(m.detailView()) + "\n")
+		return builder.String()
+	}
+
+	if m.Stopped {
+		builder.WriteString(center(errorStyle.Render("Stopped after the first failure (--fail-fast).")) + "\n\n")
+	}
+
+	if m.Draining && !m.Done {
+		builder.WriteString(center(pendingStyle.Render(fmt.Sprintf("Draining%s waiting for %d running syncs to finish. Press '%s' again to stop them now.", glyphs.ellipsis, m.running(), keyOf(m.keys.Quit)))) + "\n\n")
+	}
+
+	if m.Paused && !m.Done {
+		builder.WriteString(center(errorStyle.Render(fmt.Sprintf("Paused after %d repositories in a row failed with %s errors.", m.Options.PauseAfter, m.PausedBy))) + "\n\n")
+		if hint := sync.Hint(m.PausedBy); hint != "" {
+			builder.WriteString(center(pendingStyle.Render("Hint: "+hint)) + "\n\n")
+		}
+		builder.WriteString(center(fmt.Sprintf("Press '%s' to continue once that is fixed, '%s' to abort the run.", keyOf(m.keys.Continue), keyOf(m.keys.Abort))) + "\n\n")
+	}
+
+	if len(m.Confirming) > 0 && !m.Done {
+		builder.WriteString(center(errorStyle.Render("Prune "+sync.DeltaList("clones no longer upstream", m.Confirming)+"?")) + "\n\n")
+		builder.WriteString(center(fmt.Sprintf("Press '%s' to delete them, '%s' to keep them.", keyOf(m.keys.Confirm), keyOf(m.keys.Decline))) + "\n\n")
+	}
+
+	switch {
+	case m.Done && len(m.Errors) > 0:
+		for _, err := range m.Errors {
+			builder.WriteString(center(errorStyle.Render("Error: "+err.Error())) + "\n\n")
+			if hint := sync.Hint(sync.ClassifyError(err)); hint != "" {
+				builder.WriteString(center(pendingStyle.Render("Hint: "+hint)) + "\n\n")
+			}
+		}
+		builder.WriteString(center(fmt.Sprintf("Press '%s' to run again, '%s' to quit.", keyOf(m.keys.Rerun), keyOf(m.keys.Quit))) + "\n")
+	case m.Done && done.failed > 0:
+		// Only failed repositories remain in the table once everything is done
+		builder.WriteString(center(errorStyle.Render(fmt.Sprintf("Completed with %d failures.", done.failed))) + "\n\n")
+		for _, hint := range done.hints {
+			builder.WriteString(center(pendingStyle.Render("Hint: "+hint)) + "\n\n")
+		}
+		builder.WriteString(center(tableView) + "\n")
+		builder.WriteString(center(m.tableStatus()) + "\n")
+		builder.WriteString(center(fmt.Sprintf("Press '%s' for details, '%s' to group, '%s' to copy the selected error, '%s' to save a summary, '%s' to retry failures, '%s' to run again, '%s' for all keys, '%s' to quit.", keyOf(m.keys.Details), keyOf(m.keys.Group), keyOf(m.keys.Copy), keyOf(m.keys.Save), keyOf(m.keys.RetryFailed), keyOf(m.keys.Rerun), keyOf(m.keys.Help), keyOf(m.keys.Quit))) + "\n")
+	case m.Done && m.Filter.Value() == "":
+		builder.WriteString(center(fmt.Sprintf("All operations completed. Press '%s' to save a summary, '%s' to run again, '%s' to quit.", keyOf(m.keys.Save), keyOf(m.keys.Rerun), keyOf(m.keys.Quit))) + "\n")
+	case m.Done:
+		builder.WriteString(center("All operations completed.") + "\n\n")
+		builder.WriteString(center(tableView) + "\n")
+		builder.WriteString(center(m.tableStatus()) + "\n")
+		builder.WriteString(center(fmt.Sprintf("Press '%s' to clear the filter, '%s' to run again, '%s' to quit.", keyOf(m.keys.Back), keyOf(m.keys.Rerun), keyOf(m.keys.Quit))) + "\n")
+	default:
+		builder.WriteString(center(loadingSpinner) + "\n\n")
+		if status := m.rateLimitStatus(); status != "" {
+			builder.WriteString(center(pendingStyle.Render(status)) + "\n\n")
+		}
+		builder.WriteString(center(tableView) + "\n")
+		builder.WriteString(center(m.tableStatus()) + "\n")
+		details := fmt.Sprintf("'%s' for details", keyOf(m.keys.Details))
+		if m.grouping != groupNone {
+			details = fmt.Sprintf("'%s' on a group to collapse or expand it, '%s' on a repository for details", keyOf(m.keys.Toggle), keyOf(m.keys.Details))
+		}
+		help := fmt.Sprintf("Use %s and %s to scroll, '%s' to filter, '%s' to group. Press %s, '%s' to skip the selected repository, '%s' to copy the selected error, '%s' for the log, '%s' for all keys, '%s' to quit.", keyOf(m.keys.Up), keyOf(m.keys.Down), keyOf(m.keys.Filter), keyOf(m.keys.Group), details, keyOf(m.keys.Skip), keyOf(m.keys.Copy), keyOf(m.keys.Log), keyOf(m.keys.Help), keyOf(m.keys.Quit))
+		builder.WriteString(center(help) + "\n")
+	}
+
+	if m.Filter.Focused() || m.Filter.Value() != "" {
+		builder.WriteString("\n" + center(m.Filter.View()) + "\n")
+	}
+
+	if m.showLog {
+		builder.WriteString("\n" + center(m.logView()) + "\n")
+	}
+
+	for _, summary := range done.summaries {
+		builder.WriteString("\n" + center(summary) + "\n")
+	}
+	// The countdown to the next run changes by the second
+	if status := m.watchStatus(); m.Done && status != "" {
+		builder.WriteString("\n" + center(normalText.Render(status)) + "\n")
+	}
+
+	if m.Notice != "" {
+		builder.WriteString("\n" + center(m.Notice) + "\n")
+	}
+
+	return builder.String()
+}
+
+// running counts the repositories that are syncing
+func (m Model) running() int {
+	n := 0
+	for _, repo := range m.Repositories {
+		if !repo.StartedAt.IsZero() && !repo.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// scrollPosition shows where the table cursor is when not every row fits on screen
+func (m Model) scrollPosition() string {
+	rows := len(m.Table.Rows())
+	if rows <= m.Table.Height() {
+		return ""
+	}
+	return fmt.Sprintf("Row %d of %d", m.Table.Cursor()+1, rows)
+}
+
+// tableStatus shows the scroll position and the grouping of the table, where they apply
+func (m Model) tableStatus() string {
+	var parts []string
+	for _, part := range []string{m.scrollPosition(), m.groupingStatus()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, glyphs.separator)
+}
+
+// failureHints returns a remediation hint for each distinct category among the failures
+// of report, which classifies them already
+func failureHints(report sync.Report) []string {
+	var hints []string
+	seen := make(map[string]bool)
+	for _, repo := range report.Repositories {
+		if category := repo.ErrorCategory; repo.Hint != "" && !seen[category] {
+			seen[category] = true
+			hints = append(hints, fmt.Sprintf("%s errors: %s", category, repo.Hint))
+		}
+	}
+	return hints
+}
+
+// warningSummary counts the non-fatal warnings of the run, or is empty when there were none
+func (m Model) warningSummary() string {
+	n := len(m.Warnings)
+	for _, repo := range m.Repositories {
+		n += len(repo.Warnings)
+	}
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("1 warning. Press '%s' to save a summary with the details.", keyOf(m.keys.Save))
+	default:
+		return fmt.Sprintf("%d warnings. Press '%s' to save a summary with the details.", n, keyOf(m.keys.Save))
+	}
+}
+
+// windowTitle summarizes progress for the terminal tab or window title (OSC 2),
+// e.g. "orgsync my-org 63% 12 failed"
+func (m Model) windowTitle() string {
+	status := m.Status()
+	title := fmt.Sprintf("orgsync %s %d%%", status.Target, status.Percent())
+	if status.Failed > 0 {
+		title += fmt.Sprintf(" %d failed", status.Failed)
+	}
+	return title
+}
+
+// noticeMsg carries a transient message for the user, e.g. after copying or exporting
+type noticeMsg struct {
+	Text string
+}
+
+// runStartedMsg hands the events of a run the engine started to the UI
+type runStartedMsg struct {
+	Run    int
+	Events <-chan sync.Event
+	Err    error
+}
+
+// eventsMsg carries events of a run that happened since the UI last rendered it
+type eventsMsg struct {
+	Run    int
+	Events []sync.Event
+}
+
+// startRun has the engine start the run
+func (m Model) startRun() tea.Msg {
+	events, err := m.engine.Start(m.ctx, m.Options, m.RunID)
+	return runStartedMsg{Run: m.run, Events: events, Err: err}
+}
+
+// listenForEvents waits for the next event of the run and takes whatever else is
+// queued up behind it, so that a burst of events is rendered once
+func (m Model) listenForEvents() tea.Msg {
+	event, ok := <-m.events
+	if !ok {
+		return nil
+	}
+	msg := eventsMsg{Run: m.run, Events: []sync.Event{event}}
+	for {
+		select {
+		case event, ok := <-m.events:
+			if !ok {
+				return msg
+			}
+			msg.Events = append(msg.Events, event)
+		default:
+			return msg
+		}
+	}
+}
+
+// applyEvents renders the state of the run after events happened in it. Only the
+// rows of repositories that changed since the last render are rendered again.
+func (m Model) applyEvents(events []sync.Event) (tea.Model, tea.Cmd) {
+	previous := m.Repositories
+	m.State = m.engine.State()
+
+	discovered, completed, finished := false, false, false
+	for _, event := range events {
+		switch event := event.(type) {
+		case *sync.DiscoveredEvent:
+			discovered = true
+		case *sync.RepositoryFinishedEvent:
+			completed = true
+		case *sync.PrunedEvent:
+			if event.Err != nil {
+				m.Notice = event.Err.Error()
+			}
+		case *sync.RunFinishedEvent:
+			finished = true
+		}
+	}
+
+	changed := false
+	if discovered || len(previous) != len(m.Repositories) {
+		m.indexRepositories()
+	} else {
+		for i, repo := range m.Repositories {
+			if !rowChanged(previous[i], repo) {
+				continue
+			}
+			changed = true
+			m.updateRow(i)
+			if m.detailRepo == repo.Key() {
+				m.Detail.SetContent(renderDetail(repo, m.Detail.Width-detailStyle.GetHorizontalFrameSize()))
+			}
+		}
+	}
+	// Only the first run of the program is recorded
+	if m.Record != nil && m.run == 0 {
+		m.Record.Add(m.State, events)
+	}
+	if headless(m.Options) {
+		switch {
+		case m.Options.Plain:
+			printPlain(m.plainLines(events))
+		case finished:
+			m.printLines(m.quietLines())
+		}
+		switch {
+		case finished && m.Draining:
+			return m, tea.Batch(m.listenForEvents, m.quitAfterRun())
+		case finished:
+			cmd := m.plainRunDone()
+			return m, tea.Batch(m.listenForEvents, cmd)
+		}
+		return m, m.listenForEvents
+	}
+	// Completed repositories drop out of the table
+	m.refreshTable()
+	if m.Done && m.completion == nil {
+		m.complete()
+	}
+
+	cmds := []tea.Cmd{m.listenForEvents}
+	if discovered || completed || finished {
+		cmds = append(cmds, tea.SetWindowTitle(m.windowTitle()))
+	}
+	// The bar moves with the bytes transferred, so that a giant repository holds it
+	// back for as long as it takes rather than counting as one of many
+	if (completed || changed) && len(m.Repositories) > 0 {
+		cmds = append(cmds, m.Progress.SetPercent(m.DoneFraction()))
+	}
+	switch {
+	case finished && m.Draining:
+		cmds = append(cmds, m.quitAfterRun())
+	case finished:
+		cmds = append(cmds, m.finishRun())
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// progressStatus renders a mini progress bar for the Status column
+func progressStatus(percent float64, speed string) string {
+	status := fmt.Sprintf("%s %3.0f%%", miniBar.ViewAs(percent), percent*100)
+	if speed != "" {
+		status += " " + speed
+	}
+	return status
+}
@@ -0,0 +1,323 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// keyPress returns the message of pressing k, named as in the key bindings
+func keyPress(k string) tea.KeyMsg {
+	switch k {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEscape}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	default:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+}
+
+// press sends the keys to m one after the other
+func press[M tea.Model](m M, keys ...string) M {
+	for _, k := range keys {
+		updated, _ := m.Update(keyPress(k))
+		m = updated.(M)
+	}
+	return m
+}
+
+// newTestModel returns the model of a run of owners with repos under way, none of
+// them done, on a 120 by 50 screen
+func newTestModel(owners []string, repos ...sync.Repository) Model {
+	m := NewModel(sync.Options{Owners: owners})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+	m = updated.(Model)
+	m.Repositories = repos
+	m.indexRepositories()
+	m.refreshTable()
+	return m
+}
+
+// names returns the first column of the rows of the table
+func names(m Model) []string {
+	var names []string
+	for _, row := range m.Table.Rows() {
+		names = append(names, row[colName])
+	}
+	return names
+}
+
+func TestGroupingAndFolding(t *testing.T) {
+	m := newTestModel([]string{"acme"},
+		sync.Repository{Owner: "acme", Name: "payments-api"},
+		sync.Repository{Owner: "acme", Name: "search"},
+		sync.Repository{Owner: "acme", Name: "payments-web"},
+		sync.Repository{Owner: "acme", Name: "infra-tools"},
+	)
+	if m.grouping != groupNone || len(m.Table.Rows()) != 4 {
+		t.Fatalf("a single owner starts grouped by %q with %d rows, want ungrouped with 4", m.grouping, len(m.Table.Rows()))
+	}
+
+	m = press(m, "g", "g")
+	if m.grouping != groupPrefix {
+		t.Fatalf("two presses of g group by %q, want %q", m.grouping, groupPrefix)
+	}
+	if got := m.groupingStatus(); !strings.Contains(m.View(), got) {
+		t.Errorf("the view does not say %q", got)
+	}
+	want := []string{"infra (1)", "infra-tools", "payments (2)", "payments-api", "payments-web", noPrefix + " (1)", "search"}
+	if got := names(m); len(got) != len(want) {
+		t.Fatalf("rows are %q, want %q", got, want)
+	}
+	for i, name := range names(m) {
+		if !strings.HasSuffix(name, want[i]) {
+			t.Errorf("row %d is %q, want %q", i, name, want[i])
+		}
+	}
+
+	// Enter and space on a header fold its section rather than open the detail pane
+	m = press(m, "enter")
+	if m.detailRepo != "" || len(m.Table.Rows()) != 6 || m.selectedSection() != "infra" {
+		t.Errorf("enter on infra left %d rows, %q selected and the detail pane at %q, want 6 rows, infra and none", len(m.Table.Rows()), m.selectedSection(), m.detailRepo)
+	}
+	m = press(m, "j", " ")
+	if len(m.Table.Rows()) != 4 || m.selectedSection() != "payments" {
+		t.Errorf("space on payments left %d rows with %q selected, want 4 with payments", len(m.Table.Rows()), m.selectedSection())
+	}
+	m = press(m, "enter")
+	if len(m.Table.Rows()) != 6 {
+		t.Errorf("enter on the folded payments left %d rows, want 6", len(m.Table.Rows()))
+	}
+
+	// Folds belong to their grouping, and g wraps around to none
+	m = press(m, "g", "g")
+	if m.grouping != groupNone || len(m.Table.Rows()) != 4 {
+		t.Errorf("wrapping around groups by %q with %d rows, want ungrouped with 4", m.grouping, len(m.Table.Rows()))
+	}
+}
+
+func TestGroupingByOwner(t *testing.T) {
+	m := newTestModel([]string{"globex", "acme"},
+		sync.Repository{Owner: "acme", Name: "api"},
+		sync.Repository{Owner: "globex", Name: "api"},
+		sync.Repository{Owner: "Globex", Name: "web"},
+	)
+	if m.grouping != groupOwner {
+		t.Fatalf("several owners start grouped by %q, want %q", m.grouping, groupOwner)
+	}
+	var titles []string
+	for _, s := range m.sections {
+		titles = append(titles, fmt.Sprintf("%s %d", s.Title, len(s.repos)))
+	}
+	if got, want := strings.Join(titles, ", "), "globex 2, acme 1"; got != want {
+		t.Errorf("sections are %s, want %s", got, want)
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	m := newTestModel([]string{"acme"},
+		sync.Repository{Owner: "acme", Name: "payments-api"},
+		sync.Repository{Owner: "acme", Name: "search"},
+	)
+	m = press(m, "/", "pay")
+	if !m.Filter.Focused() || len(m.Table.Rows()) != 1 {
+		t.Fatalf("typing a filter left %d rows, focused %v, want 1 row and focus", len(m.Table.Rows()), m.Filter.Focused())
+	}
+	// Keys go to the filter until enter, then to the table again
+	m = press(m, "enter", "g")
+	if m.Filter.Focused() || m.Filter.Value() != "pay" || m.grouping != groupStatus {
+		t.Errorf("after enter and g the filter is %q, focused %v, grouped by %q", m.Filter.Value(), m.Filter.Focused(), m.grouping)
+	}
+	m = press(m, "g", "g", "g", "esc")
+	if m.Filter.Value() != "" || len(m.Table.Rows()) != 2 {
+		t.Errorf("esc left the filter at %q with %d rows, want it cleared with 2", m.Filter.Value(), len(m.Table.Rows()))
+	}
+}
+
+func TestDetailPane(t *testing.T) {
+	m := newTestModel([]string{"acme"},
+		sync.Repository{Owner: "acme", Name: "api"},
+		sync.Repository{Owner: "acme", Name: "web", Err: errors.New("fatal: repository not found")},
+	)
+	m = press(m, "j", "enter")
+	if m.detailRepo != "acme/web" {
+		t.Fatalf("enter opened the detail pane of %q, want acme/web", m.detailRepo)
+	}
+	view := m.View()
+	for _, want := range []string{"acme/web", "fatal: repository not found", "to go back"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("the detail pane does not show %q", want)
+		}
+	}
+	// The table keys do not apply while the pane is open
+	m = press(m, "g", "esc")
+	if m.detailRepo != "" || m.grouping != groupNone {
+		t.Errorf("esc left the detail pane at %q, grouped by %q", m.detailRepo, m.grouping)
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	m := newTestModel([]string{"acme"}, sync.Repository{Owner: "acme", Name: "api"})
+	m = press(m, "?")
+	if !m.showHelp || !strings.Contains(m.View(), "list all keys") {
+		t.Fatal("? does not show the help")
+	}
+	m = press(m, "g", "l", "enter")
+	if !m.showHelp || m.grouping != groupNone || m.showLog || m.detailRepo != "" {
+		t.Error("keys other than those closing the help went through to the table")
+	}
+	if m = press(m, "?"); m.showHelp {
+		t.Error("? does not close the help")
+	}
+	if m = press(m, "?", "esc"); m.showHelp {
+		t.Error("esc does not close the help")
+	}
+}
+
+func TestLogPane(t *testing.T) {
+	m := newTestModel([]string{"acme"}, sync.Repository{Owner: "acme", Name: "api"})
+	height := m.Table.Height()
+
+	updated, cmd := m.Update(keyPress("l"))
+	m = updated.(Model)
+	if !m.showLog || cmd == nil || m.Table.Height() != height-logPaneHeight {
+		t.Fatalf("l left the log shown %v with a table of %d rows, want it shown, refreshed and %d rows", m.showLog, m.Table.Height(), height-logPaneHeight)
+	}
+	if !strings.Contains(m.View(), "Log. Press 'l' to hide it.") {
+		t.Error("the view does not show the log pane")
+	}
+
+	// Reopening before the pending refresh finds the pane closed starts no second one
+	updated, cmd = press(m, "l").Update(keyPress("l"))
+	m = updated.(Model)
+	if cmd != nil || !m.showLog {
+		t.Error("reopening the log pane started a second refresh")
+	}
+	m = press(m, "l")
+	updated, cmd = m.Update(logTickMsg{})
+	m = updated.(Model)
+	if cmd != nil || m.logTicking || m.Table.Height() != height {
+		t.Errorf("the refresh of the closed pane goes on %v, table of %d rows, want it stopped and %d rows", m.logTicking, m.Table.Height(), height)
+	}
+}
+
+func TestLookupKeys(t *testing.T) {
+	k, err := LookupKeys(map[string][]string{"log": {"o"}, "toggle_group": {" ", "tab"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keyOf(k.Toggle); got != "space" {
+		t.Errorf("toggle_group is shown as %q, want space", got)
+	}
+	m := newTestModel([]string{"acme"}, sync.Repository{Owner: "acme", Name: "api"})
+	m.keys = k
+	if m = press(m, "l"); m.showLog {
+		t.Error("l still opens the log")
+	}
+	if m = press(m, "o"); !m.showLog || !strings.Contains(m.View(), "Press 'o' to hide it.") {
+		t.Error("o does not open the log, or the hint names another key")
+	}
+
+	for _, config := range []map[string][]string{{"logs": {"o"}}, {"log": {}}, {"log": {""}}} {
+		if _, err := LookupKeys(config); err == nil {
+			t.Errorf("LookupKeys(%q) succeeded", config)
+		}
+	}
+}
+
+func TestExploreRules(t *testing.T) {
+	m := NewExploreModel(sync.Options{Owners: []string{"acme"}}, "")
+	updated, _ := m.Update(exploreFetchedMsg{Repositories: []sync.Repository{
+		{Owner: "acme", Name: "api", Language: "Go"},
+		{Owner: "acme", Name: "web", Language: "TypeScript"},
+		{Owner: "acme", Name: "worker", Language: "Go"},
+	}})
+	m = updated.(ExploreModel)
+
+	m = press(m, "l")
+	if got := strings.Join(m.Profile.Include, ","); got != "language:Go" || len(m.Table.Rows()) != 2 {
+		t.Fatalf("l included %q leaving %d rows, want language:Go and 2", got, len(m.Table.Rows()))
+	}
+	m = press(m, "x")
+	if got := strings.Join(m.Profile.Exclude, ","); got != "name:api" || len(m.Table.Rows()) != 1 {
+		t.Errorf("x excluded %q leaving %d rows, want name:api and 1", got, len(m.Table.Rows()))
+	}
+	m = press(m, "backspace", "backspace")
+	if !m.Profile.Empty() || len(m.Table.Rows()) != 3 {
+		t.Errorf("backspace left %+v and %d rows, want no rules and 3", m.Profile, len(m.Table.Rows()))
+	}
+
+	// Keys go to the input while it is open
+	m = press(m, "-", "name:w*", "enter")
+	if got := strings.Join(m.Profile.Exclude, ","); got != "name:w*" || len(m.Table.Rows()) != 1 {
+		t.Errorf("the exclude rule is %q leaving %d rows, want name:w* and 1", got, len(m.Table.Rows()))
+	}
+	m = press(m, "+", "owner:acme", "enter")
+	if len(m.Profile.Include) != 0 || !strings.Contains(m.Notice, "unknown field") {
+		t.Errorf("an invalid rule was added as %q with the notice %q", m.Profile.Include, m.Notice)
+	}
+	if m = press(m, "+", "x", "esc"); m.editing != "" || len(m.Profile.Include) != 0 {
+		t.Errorf("esc left the input %q open and the rules %q", m.editing, m.Profile.Include)
+	}
+}
+
+// viewBudget is how long View may take with 5,000 repositories. At 20 frames a second
+// it has 50ms per frame, and should take no more than a few milliseconds of it.
+const viewBudget = 5 * time.Millisecond
+
+// BenchmarkView renders a run of 5,000 repositories, a quarter each queued, syncing,
+// synchronized and failed, while it runs and on the completion screen, and fails when
+// a frame takes longer than viewBudget.
+func BenchmarkView(b *testing.B) {
+	m := NewModel(sync.Options{Owners: []string{"acme"}})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+	m = updated.(Model)
+
+	now := time.Now()
+	m.Repositories = make([]sync.Repository, 5000)
+	for i := range m.Repositories {
+		repo := sync.Repository{Owner: "acme", Name: fmt.Sprintf("repo-%04d", i), Size: int64(i) << 16}
+		switch i % 4 {
+		case 1:
+			repo.StartedAt = now
+			repo.Progress = 0.5
+			repo.TransferSpeed = "2.40 MiB/s"
+		case 2:
+			repo.StartedAt, repo.FinishedAt, repo.Done = now, now, true
+		case 3:
+			repo.StartedAt, repo.FinishedAt, repo.Done = now, now, true
+			repo.Err = errors.New("fatal: unable to access: Connection reset by peer")
+		}
+		m.Repositories[i] = repo
+	}
+	m.indexRepositories()
+	m.refreshTable()
+
+	for _, done := range []bool{false, true} {
+		m.Done = done
+		name := "running"
+		if done {
+			name = "done"
+			m.complete()
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = m.View()
+			}
+			if frame := b.Elapsed() / time.Duration(b.N); frame > viewBudget {
+				b.Errorf("View took %s per frame, over the budget of %s", frame, viewBudget)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jdmcgrath/orgsync/sync"
+)
+
+// maxRunHistory is how many earlier runs the completion screen lists
+const maxRunHistory = 5
+
+// RunSummary is the outcome of a finished run, kept when the program runs again
+type RunSummary struct {
+	RunID     string
+	StartedAt time.Time
+	Duration  time.Duration
+	Succeeded int
+	// Failed counts failed and conflicting repositories
+	Failed  int
+	Skipped int
+	// Errors counts run-level failures such as failed discovery
+	Errors int
+}
+
+// nextRunMsg starts the next run of watch mode, unless run was already superseded
+type nextRunMsg struct {
+	Run int
+}
+
+// summary condenses the run for the history shown by later runs
+func (m Model) summary() RunSummary {
+	report := m.Report()
+	t := report.Totals
+	return RunSummary{
+		RunID:     m.RunID,
+		StartedAt: m.StartedAt,
+		Duration:  m.FinishedAt.Sub(m.StartedAt),
+		Succeeded: t.Succeeded,
+		Failed:    t.Failed + t.Conflicts,
+		Skipped:   t.Skipped,
+		Errors:    len(report.Errors),
+	}
+}
+
+// finishRun returns the commands that follow a finished run: AfterRun, and in watch
+// mode the timer for the next run. It must only be called once the run is done.
+func (m *Model) finishRun() tea.Cmd {
+	cmds := []tea.Cmd{m.afterRun()}
+	if m.Options.Watch > 0 && m.NextRunAt.IsZero() {
+		m.NextRunAt = time.Now().Add(m.Options.Watch)
+		run := m.run
+		cmds = append(cmds, tea.Tick(m.Options.Watch, func(time.Time) tea.Msg {
+			return nextRunMsg{Run: run}
+		}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// afterRun returns the command calling AfterRun with the finished run, if it is set
+func (m Model) afterRun() tea.Cmd {
+	if m.AfterRun == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		if err := m.AfterRun(m); err != nil {
+			return noticeMsg{Text: "Error: " + err.Error()}
+		}
+		return nil
+	}
+}
+
+// quitAfterRun returns the command that quits once a drained run is finished. AfterRun
+// is called first, since the program is gone before its failure could be shown.
+func (m Model) quitAfterRun() tea.Cmd {
+	after := m.afterRun()
+	return func() tea.Msg {
+		if after != nil {
+			if notice, ok := after().(noticeMsg); ok {
+				sync.Logger().Error("failed to finish the run", "run_id", m.RunID, "error", notice.Text)
+			}
+		}
+		return tea.Quit()
+	}
+}
+
+// watchStatus renders when watch mode runs next and how the last few runs went
+func (m Model) watchStatus() string {
+	var b strings.Builder
+	if !m.NextRunAt.IsZero() {
+		wait := max(time.Until(m.NextRunAt), 0).Round(time.Second)
+		fmt.Fprintf(&b, "Next run in %s (at %s). Press '%s' to run now.\n", wait, m.NextRunAt.Format(time.TimeOnly), keyOf(m.keys.Rerun))
+	}
+	for i := len(m.History) - 1; i >= 0; i-- {
+		run := m.History[i]
+		line := fmt.Sprintf("%s  %s  %d succeeded, %d failed", run.StartedAt.Format(time.TimeOnly), run.Duration.Round(time.Second), run.Succeeded, run.Failed)
+		if run.Skipped > 0 {
+			line += fmt.Sprintf(", %d skipped", run.Skipped)
+		}
+		if run.Errors > 0 {
+			line += fmt.Sprintf(", %d errors", run.Errors)
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unpushedWork finds what only the clone at dir has: the number of commits reachable
+// from HEAD or a local branch that no remote branch contains, and the local branches
+// without a counterpart on any remote, because they were never pushed or their
+// upstream branch was deleted. What cannot be read counts as nothing, as in an empty
+// repository.
+func unpushedWork(ctx context.Context, dir string) (commits int, branches []string) {
+	// An empty repository or an unborn branch has no HEAD to start from
+	out, err := gitOutput(ctx, dir, "rev-list", "--count", "HEAD", "--branches", "--not", "--remotes")
+	if err != nil {
+		out, err = gitOutput(ctx, dir, "rev-list", "--count", "--branches", "--not", "--remotes")
+	}
+	if err == nil {
+		commits, _ = strconv.Atoi(out)
+	}
+
+	out, err = gitOutput(ctx, dir, "for-each-ref", "--format=%(refname:strip=3)", "refs/remotes")
+	if err != nil {
+		return commits, nil
+	}
+	remote := make(map[string]bool)
+	for _, name := range splitLines(out) {
+		remote[name] = true
+	}
+	out, err = gitOutput(ctx, dir, "for-each-ref", "--format=%(refname:short)%09%(upstream)%09%(upstream:track)", "refs/heads")
+	if err != nil {
+		return commits, nil
+	}
+	for _, line := range splitLines(out) {
+		name, rest, _ := strings.Cut(line, "\t")
+		upstream, track, _ := strings.Cut(rest, "\t")
+		if (upstream == "" || track == "[gone]") && !remote[name] {
+			branches = append(branches, name)
+		}
+	}
+	return commits, branches
+}
+
+// UnpushedText describes the unpushed work of repo, e.g. "3 commits, branch wip", or
+// is empty without any
+func UnpushedText(repo Repository) string {
+	var parts []string
+	switch repo.Unpushed {
+	case 0:
+	case 1:
+		parts = append(parts, "1 commit")
+	default:
+		parts = append(parts, fmt.Sprintf("%d commits", repo.Unpushed))
+	}
+	switch len(repo.LocalBranches) {
+	case 0:
+	case 1:
+		parts = append(parts, "branch "+repo.LocalBranches[0])
+	default:
+		parts = append(parts, "branches "+strings.Join(repo.LocalBranches, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
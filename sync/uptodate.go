@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// upToDate reports whether a clone already has every branch of its origin, so that a
+// fetch would find nothing to do. It asks origin with git ls-remote, which costs one
+// round trip instead of a fetch's negotiation. branches is where the clone keeps the
+// remote's branches, e.g. "refs/remotes/origin/". With tags the tags must match as
+// well, and with prune the clone must not have branches or tags origin has dropped.
+// Anything the comparison cannot account for reports false, falling back to a fetch.
+func upToDate(ctx context.Context, repoDir, branches string, tags, prune bool, progress *progressWriter) (bool, error) {
+	args := []string{"-C", repoDir, "ls-remote", "--heads"}
+	if tags {
+		args = append(args, "--tags")
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, "origin")...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := runCommand(cmd, progress); err != nil {
+		return false, fmt.Errorf("failed to list the refs of origin: %w", newCommandError(err, progress))
+	}
+
+	remote := make(map[string]string)
+	for _, line := range splitLines(out.String()) {
+		sha, ref, ok := strings.Cut(line, "\t")
+		switch {
+		case !ok || strings.HasSuffix(ref, "^{}"):
+			// Peeled tags repeat the commit an annotated tag points to
+		case strings.HasPrefix(ref, "refs/heads/"):
+			remote[branches+strings.TrimPrefix(ref, "refs/heads/")] = sha
+		case strings.HasPrefix(ref, "refs/tags/"):
+			remote[ref] = sha
+		}
+	}
+	if len(remote) == 0 {
+		// An empty repository, or one whose refs could not be read
+		return false, nil
+	}
+
+	patterns := []string{branches}
+	if tags {
+		patterns = append(patterns, "refs/tags/")
+	}
+	listing, err := gitOutput(ctx, repoDir, append([]string{"for-each-ref", "--format=%(objectname) %(refname)"}, patterns...)...)
+	if err != nil {
+		return false, nil
+	}
+	local := make(map[string]string)
+	for _, line := range splitLines(listing) {
+		sha, ref, _ := strings.Cut(line, " ")
+		if ref != branches+"HEAD" {
+			local[ref] = sha
+		}
+	}
+
+	for ref, sha := range remote {
+		if local[ref] != sha {
+			return false, nil
+		}
+	}
+	return !prune || len(local) == len(remote), nil
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrWorktreeProtected is returned for a git command that would modify a working tree
+// while --no-touch-worktree is in effect
+var ErrWorktreeProtected = errors.New("refusing to modify a working tree with --no-touch-worktree")
+
+// worktreeCommands are the git subcommands that change the files of a working tree
+var worktreeCommands = map[string]bool{
+	"am": true, "apply": true, "checkout": true, "cherry-pick": true, "clean": true, "merge": true,
+	"pull": true, "rebase": true, "reset": true, "restore": true, "revert": true, "stash": true, "switch": true,
+}
+
+// modifiesWorktree reports whether the command line args runs a git subcommand that
+// changes the files of an existing working tree
+func modifiesWorktree(args []string) bool {
+	if len(args) == 0 || filepath.Base(args[0]) != "git" {
+		return false
+	}
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C" || arg == "-c":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		case arg == "submodule":
+			return slices.Contains(args[i+1:], "update")
+		default:
+			return worktreeCommands[arg]
+		}
+	}
+	return false
+}
+
+// ErrDirty marks an existing clone that was skipped because its working tree has local
+// modifications that updating it could overwrite
+var ErrDirty = errors.New("dirty")
+
+// stashMessage labels the stash entries orgsync creates with --stash
+const stashMessage = "orgsync: local changes saved during sync"
+
+// updateWorktree runs the steps that change the files of a fetched clone: checking out
+// the default branch and updating submodules. Local modifications are never touched:
+// the repository is skipped with ErrDirty, or with opts.Stash the changes are stashed
+// for the duration and restored afterwards. opts.Force updates the working tree
+// around them instead, as far as git does without overwriting them.
+func updateWorktree(ctx context.Context, opts Options, repoDir string, repo Repository, progress *progressWriter) (err error) {
+	checkout := opts.Checkout && !repo.Gist
+	submodules := opts.Submodules && !repo.Gist
+	if opts.NoTouchWorktree || (!checkout && !submodules) {
+		return nil
+	}
+
+	changes, err := localChanges(ctx, repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to check the working tree of %s: %w", repo.Name, err)
+	}
+	switch {
+	case len(changes) == 0:
+	case opts.Stash:
+		if err := stashChanges(ctx, repoDir, repo.Name, progress); err != nil {
+			return err
+		}
+		defer func() {
+			if popErr := popStash(ctx, repoDir, repo.Name, progress); popErr != nil && err == nil {
+				err = popErr
+			}
+		}()
+	case !opts.Force:
+		return fmt.Errorf("%w: %d local changes, such as %q", ErrDirty, len(changes), changes[0])
+	}
+
+	if checkout {
+		if err := checkoutDefault(ctx, repoDir, repo, opts.pinnedBranch(repo), progress); err != nil {
+			return err
+		}
+	}
+	if submodules {
+		return updateSubmodules(ctx, repoDir, repo.Name, progress)
+	}
+	return nil
+}
+
+// localChanges lists the modified, staged and untracked paths of a working tree
+func localChanges(ctx context.Context, repoDir string) ([]string, error) {
+	out, err := gitOutput(ctx, repoDir, "status", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(out), nil
+}
+
+// stashChanges saves the local changes of a working tree, including untracked files
+func stashChanges(ctx context.Context, repoDir, repo string, progress *progressWriter) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", repoDir, "stash", "push", "--include-untracked", "--message", stashMessage)
+
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to stash local changes of %s: %w", repo, newCommandError(err, progress))
+	}
+	return nil
+}
+
+// popStash restores the changes saved by stashChanges. git keeps the stash entry when
+// they no longer apply cleanly, so nothing is lost either way.
+func popStash(ctx context.Context, repoDir, repo string, progress *progressWriter) error {
+	// Restore even when the sync was cancelled, so the changes do not linger in the stash
+	cmd := exec.CommandContext(context.WithoutCancel(ctx), "git", "-C", repoDir, "stash", "pop")
+
+	if err := runCommand(cmd, progress); err != nil {
+		return fmt.Errorf("failed to restore local changes of %s, they are kept in `git stash list`: %w", repo, newCommandError(err, progress))
+	}
+	return nil
+}
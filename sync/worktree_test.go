@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jdmcgrath/orgsync/internal/harness"
+)
+
+// workingTree reads every file of the working tree in dir outside .git, by path
+func workingTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read the working tree of %s: %v", dir, err)
+	}
+	return files
+}
+
+// behindClone clones the fixture repository name into the sync root and pushes
+// another commit upstream, then fetches it, so that checking out or merging origin
+// would change the working tree. The clone also holds an untracked file to stash.
+func behindClone(t *testing.T, f *harness.Fixture, name string) string {
+	t.Helper()
+	f.AddRepo(name, map[string]string{"README.md": "first\n"})
+	dir := filepath.Join(f.Root, name)
+	f.Git("", "clone", "--quiet", "https://"+harness.Host+"/"+f.Owner+"/"+name, dir)
+	f.Commit(name, map[string]string{"README.md": "second\n"})
+	f.Git(dir, "fetch", "--quiet", "origin")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("local work\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestModifiesWorktree(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"git", "-C", "api", "checkout", "main"}, true},
+		{[]string{"git", "-C", "api", "merge", "--ff-only", "origin/main"}, true},
+		{[]string{"git", "-C", "api", "stash", "push"}, true},
+		{[]string{"git", "-C", "api", "submodule", "update", "--init"}, true},
+		{[]string{"git", "-c", "core.hooksPath=/dev/null", "pull"}, true},
+		{[]string{"git", "--no-pager", "reset", "--hard"}, true},
+		{[]string{"/usr/bin/git", "-C", "api", "switch", "main"}, true},
+		{[]string{"git", "-C", "api", "fetch", "origin"}, false},
+		{[]string{"git", "-C", "api", "submodule", "status"}, false},
+		// The values of -C and -c are skipped rather than taken for the subcommand
+		{[]string{"git", "-C", "checkout", "fetch"}, false},
+		{[]string{"git", "-c", "stash", "status"}, false},
+		{[]string{"git", "-C", "api", "-c", "merge", "log"}, false},
+		{[]string{"git", "-C"}, false},
+		{[]string{"git"}, false},
+		{[]string{"gh", "repo", "clone", "acme/api", "api", "--", "--progress"}, false},
+	}
+	for _, tt := range tests {
+		if got := modifiesWorktree(tt.args); got != tt.want {
+			t.Errorf("modifiesWorktree(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNoTouchWorktreeRefusesCommands(t *testing.T) {
+	f := harness.New(t, "acme")
+	f.Setenv()
+	ctx := context.Background()
+	dir := behindClone(t, f, "api")
+	repo := Repository{Owner: "acme", Name: "api", DefaultBranch: "main"}
+
+	tests := []struct {
+		name string
+		run  func(progress *progressWriter) error
+	}{
+		{"checkout", func(progress *progressWriter) error {
+			return checkoutDefault(ctx, dir, repo, "", progress)
+		}},
+		{"merge", func(progress *progressWriter) error {
+			return runCommand(exec.CommandContext(ctx, "git", "-C", dir, "merge", "--ff-only", "--quiet", "origin/main"), progress)
+		}},
+		{"stash", func(progress *progressWriter) error {
+			return stashChanges(ctx, dir, repo.Name, progress)
+		}},
+		{"submodule update", func(progress *progressWriter) error {
+			return updateSubmodules(ctx, dir, repo.Name, progress)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := workingTree(t, dir)
+			err := tt.run(&progressWriter{protectWorktree: true})
+			if !errors.Is(err, ErrWorktreeProtected) {
+				t.Errorf("got error %v, want %v", err, ErrWorktreeProtected)
+			}
+			if after := workingTree(t, dir); !maps.Equal(before, after) {
+				t.Errorf("working tree changed from %q to %q", before, after)
+			}
+		})
+	}
+
+	// The same merge does change the working tree when it is not protected
+	if err := runCommand(exec.CommandContext(ctx, "git", "-C", dir, "merge", "--ff-only", "--quiet", "origin/main"), &progressWriter{}); err != nil {
+		t.Fatalf("unprotected merge failed: %v", err)
+	}
+	if got := workingTree(t, dir)["README.md"]; got != "second\n" {
+		t.Errorf("unprotected merge left README.md at %q", got)
+	}
+}
+
+func TestNoTouchWorktreeSkipsUpdate(t *testing.T) {
+	f := harness.New(t, "acme")
+	f.Setenv()
+	dir := behindClone(t, f, "api")
+	opts := Options{Checkout: true, Submodules: true, Stash: true, NoTouchWorktree: true}
+	repo := Repository{Owner: "acme", Name: "api", DefaultBranch: "main"}
+
+	before := workingTree(t, dir)
+	progress := &progressWriter{protectWorktree: true}
+	if err := updateWorktree(context.Background(), opts, dir, repo, progress); err != nil {
+		t.Fatalf("updateWorktree failed: %v", err)
+	}
+	if after := workingTree(t, dir); !maps.Equal(before, after) {
+		t.Errorf("working tree changed from %q to %q", before, after)
+	}
+	if len(progress.commands) > 0 {
+		t.Errorf("ran %q", progress.commands)
+	}
+}
+
+// checkOrigin rewrites origin with git directly rather than through runCommand, which
+// must not reach into the working tree either
+func TestCheckOriginAdoptKeepsWorktree(t *testing.T) {
+	tests := []struct {
+		name       string
+		dirty      bool
+		wantErr    error
+		wantOrigin string
+	}{
+		{"clean", false, nil, "https://github.com/acme/api.git"},
+		{"dirty", true, ErrDirty, "https://github.com/acme/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := harness.New(t, "acme")
+			f.Setenv()
+			f.AddRepo("api", map[string]string{"README.md": "first\n"})
+			dir := filepath.Join(f.Root, "api")
+			f.Git("", "clone", "--quiet", "https://github.com/acme/api", dir)
+			f.Git(dir, "remote", "set-url", "origin", "https://github.com/acme/other")
+			if tt.dirty {
+				if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("edited\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := Options{Host: harness.Host, OnConflict: ConflictAdopt, NoTouchWorktree: true}
+
+			before := workingTree(t, dir)
+			moved, err := checkOrigin(context.Background(), opts, Repository{Owner: "acme", Name: "api"}, dir)
+			if moved || !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkOrigin() = %v, %v, want false, %v", moved, err, tt.wantErr)
+			}
+			if after := workingTree(t, dir); !maps.Equal(before, after) {
+				t.Errorf("working tree changed from %q to %q", before, after)
+			}
+			if got := f.Git(dir, "config", "--get", "remote.origin.url"); got != tt.wantOrigin {
+				t.Errorf("origin is %s, want %s", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+// A clone of a renamed repository is moved and its origin rewritten outside
+// runCommand, leaving its files as they were
+func TestFollowRedirectsKeepsWorktree(t *testing.T) {
+	f := harness.New(t, "acme")
+	f.Setenv()
+	f.AddRepo("api", map[string]string{"README.md": "first\n"})
+	f.Git("", "clone", "--quiet", "https://github.com/acme/api", filepath.Join(f.Root, "api"))
+	if err := os.WriteFile(filepath.Join(f.Root, "api", "notes.txt"), []byte("local work\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.RenameRepo("api", "service")
+
+	before := workingTree(t, filepath.Join(f.Root, "api"))
+	upstream := []Repository{{Owner: "acme", Name: "service"}}
+	opts := Options{Owner: "acme", Host: harness.Host, NoTouchWorktree: true}
+	result := followRedirects(context.Background(), opts, upstream, upstream)
+
+	if from, ok := result.Moved["acme/service"]; !ok || from != "api" {
+		t.Fatalf("Moved = %v, want acme/service moved from api", result.Moved)
+	}
+	dir := filepath.Join(f.Root, "service")
+	if after := workingTree(t, dir); !maps.Equal(before, after) {
+		t.Errorf("working tree changed from %q to %q", before, after)
+	}
+	if got, want := f.Git(dir, "config", "--get", "remote.origin.url"), "https://github.com/acme/service.git"; got != want {
+		t.Errorf("origin is %s, want %s", got, want)
+	}
+}